@@ -1,33 +1,113 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
 	"github.com/dvo-dev/fibonacci-backend/pkg/server"
 )
 
+// Pause between attempts to run the server so a persistent failure, such as a
+// corrupt persisted state, does not spin
+const restartDelay = 5 * time.Second
+
 func main() {
-	log.Println("Starting server...")
+	cfg, err := server.LoadConfig(os.Args[1:], os.Getenv)
+	if flag.ErrHelp == err {
+		return
+	}
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "Could not load the configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	logger := logging.New(os.Stderr, cfg.LoggingOptions())
+	mainLogger := logger.Component("main")
+
+	logger.Component("config").Info("effective config", cfg.Fields()...)
+	mainLogger.Info("starting server")
 	for {
-		if err := run(); nil != err {
-			log.Printf("Error occurred while serving: %v\n", err)
+		err := run(cfg, logger)
+		if nil == err {
+			mainLogger.Info("server has shut down")
+			return
 		}
+
+		mainLogger.Error("error occurred while serving", "error", err, "restart_in", restartDelay)
+		time.Sleep(restartDelay)
 	}
 }
 
-func run() error {
-	var err error = nil
-	s := server.InitializeServer()
+// run -
+// This function serves until SIGTERM or SIGINT is received, then shuts down
+// gracefully and returns nil. An error is returned when the server cannot be
+// started or stops serving on its own, the state is flushed then as well.
+func run(cfg server.Config, logger *logging.Logger) error {
+	mainLogger := logger.Component("main")
+
+	s, err := server.InitializeServer(cfg, logger)
+	if nil != err {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
 
-	hostPort := os.Getenv("SERVING_HOST_PORT")
-	if 0 == len(hostPort) {
-		hostPort = "0.0.0.0:8080"
+	srv := &http.Server{
+		Addr:     cfg.HostPort,
+		Handler:  s.GetRouter(),
+		ErrorLog: logging.NewStdLogger(logger.Component("http"), logging.LevelError),
 	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+	mainLogger.Info("server has been initialized, now serving", "addr", cfg.HostPort)
 
-	log.Println("Server has been initialized, now serving...")
-	err = http.ListenAndServe(hostPort, s.GetRouter())
+	timeout := cfg.ShutdownTimeout
+	select {
+	case err = <-served:
+		flush(mainLogger, s, timeout)
+		return err
+	case sig := <-stop:
+		// A second signal kills the process rather than waiting on the drain
+		signal.Stop(stop)
+		mainLogger.Info("shutting down", "signal", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stops accepting connections and waits for the requests in flight,
+	// those still running at the deadline are cut off
+	if err := srv.Shutdown(ctx); nil != err {
+		mainLogger.Warn("requests did not drain in time", "timeout", timeout, "error", err)
+		srv.Close()
+	}
+
+	flush(mainLogger, s, timeout)
+	return nil
+}
+
+// flush -
+// This function writes the state of the server to the store, giving up after
+// timeout, and closes the store
+func flush(logger *logging.Logger, s *server.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); nil != err {
+		logger.Error("state may not have been fully persisted", "error", err)
+		return
+	}
 
-	return err
+	logger.Info("state has been persisted")
 }
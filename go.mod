@@ -3,6 +3,8 @@ module github.com/dvo-dev/fibonacci-backend
 go 1.15
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/go-redis/redis/v8 v8.4.4
 	github.com/julienschmidt/httprouter v1.3.0
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,231 @@
+package fibonacci
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// bitsPerIndex is log2 of the golden ratio, F(n) is roughly n * bitsPerIndex
+// bits long
+var bitsPerIndex = math.Log2(math.Phi)
+
+// Nth -
+// This function returns F(n) in O(log n) without touching any sequence state.
+// Terms up to F(93) are computed and returned as uint64s, larger terms as
+// big.Ints.
+func Nth(n uint64) Number {
+	if maxUint64Index >= n {
+		return NewNumber(uint64Nth(n))
+	}
+
+	fn, _ := bigPair(n)
+	return NewBigNumber(fn)
+}
+
+// IndexOf -
+// This function returns the index n for which F(n) == v, and false when v is
+// not a Fibonacci number. As 1 is both F(1) and F(2), 2 is returned for it.
+func IndexOf(v *big.Int) (uint64, bool) {
+	return fibIndex(v)
+}
+
+// IsFibonacci -
+// This function reports whether v is a Fibonacci number
+func IsFibonacci(v *big.Int) bool {
+	_, ok := fibIndex(v)
+	return ok
+}
+
+// ApproxIndex -
+// This function estimates, from its bit length alone, the index of the largest
+// Fibonacci number not above a non-negative v. The estimate is within one of
+// the exact index and cheap enough to bound a computation before starting it.
+func ApproxIndex(v *big.Int) uint64 {
+	if 0 >= v.Sign() {
+		return 0
+	}
+
+	estimate := estimateIndex(v)
+	if 0 > estimate {
+		return 0
+	}
+
+	return uint64(estimate)
+}
+
+// FloorIndex -
+// This function returns the largest n for which F(n) <= v, and false when v is
+// negative. As 1 is both F(1) and F(2), 2 is returned for it.
+func FloorIndex(v *big.Int) (uint64, bool) {
+	if 0 > v.Sign() {
+		return 0, false
+	}
+
+	n := ApproxIndex(v)
+	fn, fn1 := bigPair(n)
+	for 0 < fn.Cmp(v) {
+		fn, fn1 = new(big.Int).Sub(fn1, fn), fn
+		n--
+	}
+	for 0 >= fn1.Cmp(v) {
+		fn, fn1 = fn1, new(big.Int).Add(fn, fn1)
+		n++
+	}
+
+	return n, true
+}
+
+// Zeckendorf -
+// This function returns the Zeckendorf representation of a non-negative v,
+// the unique set of non-consecutive Fibonacci numbers from F(2) upwards that
+// sum to v, largest first. 0 is the empty sum.
+func Zeckendorf(v *big.Int) []Term {
+	terms := []Term{}
+
+	n, ok := FloorIndex(v)
+	if !ok || 0 == v.Sign() {
+		return terms
+	}
+
+	// Greedily take the largest term that fits, which never leaves room for
+	// the term just below it
+	rest := new(big.Int).Set(v)
+	fn, fn1 := bigPair(n)
+	for 2 <= n && 0 < rest.Sign() {
+		if 0 <= rest.Cmp(fn) {
+			rest.Sub(rest, fn)
+			terms = append(terms, Term{Index: n, Value: termNumber(n, fn)})
+		}
+
+		fn, fn1 = new(big.Int).Sub(fn1, fn), fn
+		n--
+	}
+
+	return terms
+}
+
+// termNumber -
+// This function wraps F(n) as a Number the way Nth would return it
+func termNumber(n uint64, fn *big.Int) Number {
+	if maxUint64Index >= n {
+		return NewNumber(fn.Uint64())
+	}
+
+	return NewBigNumber(fn)
+}
+
+// uint64Nth -
+// This function returns F(n) for n up to 93 using the same fast doubling
+// identities as bigPair. F(n+1) may overflow on the last step but is never
+// used.
+func uint64Nth(n uint64) uint64 {
+	var a, b uint64 = 0, 1
+
+	for i := bits.Len64(n) - 1; i >= 0; i-- {
+		c := a * (2*b - a)
+		d := a*a + b*b
+
+		if 1 == (n>>uint(i))&1 {
+			a, b = d, c+d
+		} else {
+			a, b = c, d
+		}
+	}
+
+	return a
+}
+
+// bigPair -
+// This function returns F(n) and F(n+1) using the fast doubling identities
+//
+//	F(2k)   = F(k) * (2F(k+1) - F(k))
+//	F(2k+1) = F(k)^2 + F(k+1)^2
+func bigPair(n uint64) (*big.Int, *big.Int) {
+	a, b := big.NewInt(0), big.NewInt(1)
+
+	for i := bits.Len64(n) - 1; i >= 0; i-- {
+		c := new(big.Int).Lsh(b, 1)
+		c.Sub(c, a).Mul(c, a)
+
+		d := new(big.Int).Mul(a, a)
+		d.Add(d, new(big.Int).Mul(b, b))
+
+		if 1 == (n>>uint(i))&1 {
+			a, b = d, c.Add(c, d)
+		} else {
+			a, b = c, d
+		}
+	}
+
+	return a, b
+}
+
+// fibIndex -
+// This function returns the index n for which F(n) == v, and false when v is
+// not a Fibonacci number. As 1 is both F(1) and F(2), 2 is returned for it.
+func fibIndex(v *big.Int) (uint64, bool) {
+	switch v.Sign() {
+	case -1:
+		return 0, false
+	case 0:
+		return 0, true
+	}
+
+	// Estimate n from log2(v), then confirm exactly
+	estimate := estimateIndex(v)
+	for n := estimate + 1; n >= estimate-1; n-- {
+		if 0 > n {
+			break
+		}
+
+		if fn, _ := bigPair(uint64(n)); 0 == fn.Cmp(v) {
+			return uint64(n), true
+		}
+	}
+
+	return 0, false
+}
+
+// estimateIndex -
+// This function estimates the index n of a positive v from log2(v), using its
+// top 53 bits and F(n) being roughly phi^n / sqrt(5)
+func estimateIndex(v *big.Int) int64 {
+	shift := v.BitLen() - 53
+	if 0 > shift {
+		shift = 0
+	}
+	top, _ := new(big.Float).SetInt(new(big.Int).Rsh(v, uint(shift))).Float64()
+	log2v := math.Log2(top) + float64(shift)
+
+	return int64(math.Round((log2v + math.Log2(math.Sqrt(5))) / bitsPerIndex))
+}
+
+// plausibleIndex -
+// This function cheaply rules out an index that could not belong to a value
+// of the given bit length, so corrupt input cannot trigger a huge computation
+func plausibleIndex(index uint64, bitLen int) bool {
+	expected := float64(index) * bitsPerIndex
+	return math.Abs(expected-float64(bitLen)) <= 3
+}
+
+// validTerms -
+// This function reports whether previous, current and next are exactly the
+// terms F(n-1), F(n) and F(n+1) of the sequence, with previous being 0 at the
+// start of the sequence
+func validTerms(n uint64, previous, current, next *big.Int) bool {
+	if !plausibleIndex(n, current.BitLen()) {
+		return false
+	}
+
+	fn, fn1 := bigPair(n)
+	if 0 != fn.Cmp(current) || 0 != fn1.Cmp(next) {
+		return false
+	}
+
+	if 0 == n {
+		return 0 == previous.Sign()
+	}
+
+	return 0 == new(big.Int).Sub(next, current).Cmp(previous)
+}
@@ -0,0 +1,176 @@
+package fibonacci
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func Test_bigPair(t *testing.T) {
+	// Walk the sequence by addition and compare every pair
+	a, b := big.NewInt(0), big.NewInt(1)
+	for n := uint64(0); n < 300; n++ {
+		fn, fn1 := bigPair(n)
+		if 0 != fn.Cmp(a) || 0 != fn1.Cmp(b) {
+			t.Fatalf("bigPair(%d) = (%v, %v), want (%v, %v)", n, fn, fn1, a, b)
+		}
+		a, b = b, new(big.Int).Add(a, b)
+	}
+}
+
+func TestNth(t *testing.T) {
+	f94, _ := bigPair(94)
+	f1000, _ := bigPair(1000)
+
+	tests := []struct {
+		name string
+		n    uint64
+		want Number
+	}{
+		{name: "zero", n: 0, want: NewNumber(0)},
+		{name: "one", n: 1, want: NewNumber(1)},
+		{name: "twelve", n: 12, want: NewNumber(144)},
+		{name: "last uint64", n: 93, want: NewNumber(12200160415121876738)},
+		{name: "first big", n: 94, want: NewBigNumber(f94)},
+		{name: "thousand", n: 1000, want: NewBigNumber(f1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Nth(tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Nth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_uint64Nth(t *testing.T) {
+	var a, b uint64 = 0, 1
+	for n := uint64(0); n <= maxUint64Index; n++ {
+		if got := uint64Nth(n); got != a {
+			t.Fatalf("uint64Nth(%d) = %v, want %v", n, got, a)
+		}
+		a, b = b, a+b
+	}
+}
+
+func Test_fibIndex(t *testing.T) {
+	f1000, _ := bigPair(1000)
+
+	tests := []struct {
+		name   string
+		value  *big.Int
+		want   uint64
+		wantOk bool
+	}{
+		{name: "zero", value: big.NewInt(0), want: 0, wantOk: true},
+		{name: "one", value: big.NewInt(1), want: 2, wantOk: true},
+		{name: "two", value: big.NewInt(2), want: 3, wantOk: true},
+		{name: "144", value: big.NewInt(144), want: 12, wantOk: true},
+		{name: "F(1000)", value: f1000, want: 1000, wantOk: true},
+		{name: "not fibonacci", value: big.NewInt(4), wantOk: false},
+		{name: "off by one", value: new(big.Int).Add(f1000, big.NewInt(1)), wantOk: false},
+		{name: "negative", value: big.NewInt(-1), wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := fibIndex(tt.value)
+			if ok != tt.wantOk {
+				t.Errorf("fibIndex() ok = %v, want %v", ok, tt.wantOk)
+				return
+			}
+			if ok && got != tt.want {
+				t.Errorf("fibIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloorIndex(t *testing.T) {
+	f100, f101 := bigPair(100)
+
+	tests := []struct {
+		name   string
+		v      *big.Int
+		want   uint64
+		wantOk bool
+	}{
+		{name: "negative", v: big.NewInt(-1), want: 0, wantOk: false},
+		{name: "zero", v: big.NewInt(0), want: 0, wantOk: true},
+		{name: "one", v: big.NewInt(1), want: 2, wantOk: true},
+		{name: "exact", v: big.NewInt(144), want: 12, wantOk: true},
+		{name: "between", v: big.NewInt(232), want: 12, wantOk: true},
+		{name: "max uint64", v: new(big.Int).SetUint64(^uint64(0)), want: 93, wantOk: true},
+		{name: "big exact", v: f100, want: 100, wantOk: true},
+		{name: "big between", v: new(big.Int).Sub(f101, big.NewInt(1)), want: 100, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FloorIndex(tt.v)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("FloorIndex() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestZeckendorf(t *testing.T) {
+	f100, _ := bigPair(100)
+	v := new(big.Int).Add(f100, big.NewInt(144+2))
+
+	want := []Term{
+		{Index: 100, Value: NewBigNumber(f100)},
+		{Index: 12, Value: NewNumber(144)},
+		{Index: 3, Value: NewNumber(2)},
+	}
+	if got := Zeckendorf(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("Zeckendorf(F(100) + 146) = %v, want %v", got, want)
+	}
+	if got := Zeckendorf(big.NewInt(0)); 0 != len(got) {
+		t.Errorf("Zeckendorf(0) = %v, want the empty sum", got)
+	}
+
+	// Every representation sums to its value and never uses consecutive terms
+	for i := int64(1); i < 2000; i++ {
+		terms := Zeckendorf(big.NewInt(i))
+
+		sum := uint64(0)
+		for j, term := range terms {
+			sum += term.Value.Uint64()
+			if term.Index < 2 || (0 < j && terms[j-1].Index <= term.Index+1) {
+				t.Fatalf("Zeckendorf(%d) = %v uses consecutive terms", i, terms)
+			}
+		}
+		if uint64(i) != sum {
+			t.Fatalf("Zeckendorf(%d) = %v sums to %v", i, terms, sum)
+		}
+	}
+}
+
+func Test_validTerms(t *testing.T) {
+	tests := []struct {
+		name                    string
+		n                       uint64
+		previous, current, next int64
+		want                    bool
+	}{
+		{name: "start", n: 0, previous: 0, current: 0, next: 1, want: true},
+		{name: "first one", n: 1, previous: 0, current: 1, next: 1, want: true},
+		{name: "second one", n: 2, previous: 1, current: 1, next: 2, want: true},
+		{name: "ones swapped", n: 1, previous: 1, current: 1, next: 2, want: false},
+		{name: "happy path", n: 12, previous: 89, current: 144, next: 233, want: true},
+		{name: "wrong index", n: 11, previous: 89, current: 144, next: 233, want: false},
+		{name: "wrong previous", n: 12, previous: 88, current: 144, next: 233, want: false},
+		{name: "wrong next", n: 12, previous: 89, current: 144, next: 234, want: false},
+		{name: "huge index", n: 1 << 62, previous: 89, current: 144, next: 233, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validTerms(
+				tt.n, big.NewInt(tt.previous), big.NewInt(tt.current), big.NewInt(tt.next),
+			)
+			if got != tt.want {
+				t.Errorf("validTerms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,122 +2,587 @@ package fibonacci
 
 import (
 	"context"
-	"log"
-	"math"
-	"strconv"
+	"errors"
+	"math/big"
+	"math/bits"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
 )
 
-const (
-	redisFibonacciKey = "fibonacci_current"
+// Index of F(93), the last Fibonacci number that fits in a uint64
+const maxUint64Index = 93
+
+var (
+	// ErrAtStart -
+	// Returned by Back when the sequence is already on F(0)
+	ErrAtStart = errors.New("sequence is already at its start")
+
+	// ErrIndexOutOfRange -
+	// Returned by Seek in uint64 mode for an index past F(93)
+	ErrIndexOutOfRange = errors.New("index does not fit in uint64 mode")
 )
 
-// RedisClient -
-// Wrapper interface for redis client Get and Set
-type RedisClient interface {
-	Get(ctx context.Context, key string) *redis.StringCmd
-	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+// Options -
+// Startup settings for a Fibonacci sequence
+type Options struct {
+	Mode           Mode
+	OverflowPolicy OverflowPolicy
+	// Key the state is persisted under, DefaultStateKey when empty
+	Key string
+	// Logger of the sequence and of its persistence, the default logger when
+	// nil
+	Logger *logging.Logger
+}
+
+// SequenceStats -
+// A snapshot of the position of a sequence and of the operations on it
+type SequenceStats struct {
+	Index uint64
+	// Terms advanced by GetNext and GetNextN, including wrapping around
+	Advances uint64
+	// Steps that stayed on the last term under OverflowSaturate
+	Saturated uint64
+
+	// Read and write locks taken, and the total time spent waiting for them
+	LockAcquisitions uint64
+	LockWait         time.Duration
+}
+
+// sequenceCounters -
+// Counts the operations on a sequence. advances is guarded by the write lock,
+// the lock counters are updated atomically as they are taken outside it.
+type sequenceCounters struct {
+	lockWaitNanos    uint64
+	lockAcquisitions uint64
+	advances         uint64
+	saturated        uint64
+}
+
+// waited -
+// This function counts a lock taken after waiting for d
+func (sc *sequenceCounters) waited(d time.Duration) {
+	atomic.AddUint64(&sc.lockWaitNanos, uint64(d))
+	atomic.AddUint64(&sc.lockAcquisitions, 1)
 }
 
 // Fibonacci - Simple wrapper for the state of a Fibonacci sequence
 type Fibonacci struct {
+	// First so its words stay 64 bit aligned for atomic access
+	counters sequenceCounters
+
+	mode     Mode
+	index    uint64
 	current  uint64
 	next     uint64
 	previous uint64
-	rwMutex  *sync.RWMutex
+
+	// Only used in ModeUint64, nextOverflowed marks that next did not fit and
+	// epoch counts the times OverflowWrap has restarted the sequence.
+	// saturated marks that the overflow was logged under OverflowSaturate, so
+	// it is logged once rather than on every step staying on the last term.
+	policy         OverflowPolicy
+	nextOverflowed bool
+	saturated      bool
+	epoch          uint64
+
+	// Incremented on every change that is persisted under key
+	key       string
+	version   uint64
+	persister *persister
+	logger    *logging.Logger
+
+	// Only used in ModeBig, these are replaced rather than modified in place so
+	// they can be handed out without copying
+	bigCurrent  *big.Int
+	bigNext     *big.Int
+	bigPrevious *big.Int
+
+	rwMutex *sync.RWMutex
 }
 
-// This function attempts to restore a fibonacci sequence state as saved in redis
-// using the "current" value
-func restoreFibonacci(rdb RedisClient) (*Fibonacci, error) {
-	current, err := rdb.Get(context.Background(), redisFibonacciKey).Result()
+// newFibonacci -
+// This function creates a sequence at its starting state for the given mode
+func newFibonacci(mode Mode, policy OverflowPolicy) *Fibonacci {
+	f := &Fibonacci{
+		mode:     mode,
+		policy:   policy,
+		current:  0,
+		next:     1,
+		previous: 0,
+		rwMutex:  &sync.RWMutex{},
+	}
+
+	if ModeBig == mode {
+		f.bigCurrent = big.NewInt(0)
+		f.bigNext = big.NewInt(1)
+		f.bigPrevious = big.NewInt(0)
+	}
+
+	return f
+}
+
+// This function attempts to restore a fibonacci sequence state as saved in
+// the state store. A repaired or migrated state is saved again.
+func restoreFibonacci(store StateStore, opts Options) (*Fibonacci, error) {
+	st, err := store.Load(context.Background(), stateKey(opts.Key))
 	if nil != err {
-		log.Printf("Error attempting to restore sequence from redis: %v", err)
 		return nil, err
 	}
 
-	currentUint, err := strconv.ParseUint(current, 10, 32)
+	return fibonacciFromState(store, st, opts)
+}
+
+// fibonacciFromState -
+// This function does the work of restoreFibonacci once the state is loaded
+func fibonacciFromState(store StateStore, st State, opts Options) (*Fibonacci, error) {
+	fib, repaired, err := decodeState(st, opts)
 	if nil != err {
 		return nil, err
 	}
+	fib.key = opts.Key
+	fib.logger = sequenceLogger(opts.Logger, opts.Key)
 
-	prev := float64(currentUint) / ((1 + math.Sqrt(5)) / 2.0)
-	roundedPrev := uint64(math.Round(prev))
+	if legacySchemaVersion == st.Schema {
+		fib.logger.Info("migrating legacy state", "current", st.Current, "index", fib.index)
+	}
 
-	return &Fibonacci{
-		current:  uint64(currentUint),
-		next:     uint64(currentUint) + roundedPrev,
-		previous: roundedPrev,
-		rwMutex:  &sync.RWMutex{},
-	}, nil
+	if repaired {
+		fib.save(store)
+	}
+
+	return fib, nil
 }
 
 // InitializeFibonacci -
-// This function initializes the Fibonacci wrapper to the start of the sequence.
-func InitializeFibonacci(rdb RedisClient) *Fibonacci {
-	if fib, err := restoreFibonacci(rdb); nil == err {
-		log.Printf("Successfully restoring sequence state from redis:\n\tcurrent: %v\n\tnext: %v\n\tprevious: %v\n\n", fib.current, fib.next, fib.previous)
-		return fib
+// This function initializes the Fibonacci wrapper, restoring the state saved in
+// the store or starting from the beginning of the sequence when there is none.
+// Changes are then persisted by a background worker until Close is called.
+// A saved state that is corrupt beyond repair, or too large for the mode, is
+// refused with an error instead of being discarded, and so is a store that
+// cannot be read: a fresh sequence would overwrite the state it holds.
+func InitializeFibonacci(store StateStore, opts Options) (*Fibonacci, error) {
+	logger := sequenceLogger(opts.Logger, opts.Key)
+
+	fib, err := restoreFibonacci(store, opts)
+	switch {
+	case nil == err:
+		logger.Info(
+			"restored state", "index", fib.index, "version", fib.version, "current", fib.currentNumber(),
+		)
+	case errors.Is(err, ErrStateNotFound):
+		logger.Info("starting a fresh sequence, no state stored", "mode", opts.Mode)
+		fib = newFibonacci(opts.Mode, opts.OverflowPolicy)
+		fib.key = opts.Key
+		fib.logger = logger
+	case errors.Is(err, ErrCorruptState) || errors.Is(err, ErrStateOutOfRange):
+		logger.Error("refusing to start from persisted state", "error", err)
+		return nil, err
+	default:
+		logger.Error("could not load persisted state", "error", err)
+		return nil, err
 	}
 
-	log.Println("Starting with a fresh sequence")
-	return &Fibonacci{
-		current:  0,
-		next:     1,
-		previous: 0,
-		rwMutex:  &sync.RWMutex{},
+	if ModeUint64 == opts.Mode {
+		logger.Info("overflow policy", "policy", opts.OverflowPolicy)
 	}
+
+	fib.persister = newPersister(store, defaultQueueSize, persisterLogger(opts.Logger, opts.Key))
+	return fib, nil
+}
+
+// sequenceLogger -
+// This function returns the logger of the sequence persisted under key
+func sequenceLogger(logger *logging.Logger, key string) *logging.Logger {
+	return logger.Component("sequence").With("sequence", sequenceName(key))
+}
+
+// persisterLogger -
+// This function returns the logger of the persistence of the sequence
+// persisted under key
+func persisterLogger(logger *logging.Logger, key string) *logging.Logger {
+	return logger.Component("persister").With("sequence", sequenceName(key))
+}
+
+// GetMode -
+// This function returns the mode the sequence was created with
+func (f *Fibonacci) GetMode() Mode {
+	return f.mode
+}
+
+// GetOverflowPolicy -
+// This function returns the overflow policy the sequence was created with
+func (f *Fibonacci) GetOverflowPolicy() OverflowPolicy {
+	return f.policy
+}
+
+// GetEpoch -
+// This function returns the number of times the sequence has wrapped around
+// under OverflowWrap
+func (f *Fibonacci) GetEpoch() uint64 {
+	f.rlock()
+	defer f.rwMutex.RUnlock()
+
+	return f.epoch
 }
 
 // GetCurrent -
-// This function will retrieve the value the sequence is currently on.
+// This function will retrieve the term the sequence is currently on.
 // It will also set a reading lock.
-func (f *Fibonacci) GetCurrent() uint64 {
-	f.rwMutex.RLock()
+func (f *Fibonacci) GetCurrent() Term {
+	f.rlock()
 	defer f.rwMutex.RUnlock()
 
-	return f.current
+	return f.currentTerm()
 }
 
 // GetNext -
 // This function will both retrieve the next value in the sequence and update
 // the previous and current values.
+// In uint64 mode an overflowing next value is handled by the overflow policy,
+// only OverflowReject returns an error (ErrOverflow).
 // This function is locked from starting while any other R/W operations are occuring
-func (f *Fibonacci) GetNext(rdb RedisClient) uint64 {
-	f.rwMutex.Lock()
+func (f *Fibonacci) GetNext() (Term, error) {
+	f.lock()
+	defer f.rwMutex.Unlock()
+
+	term, changed, err := f.step()
+	if nil != err {
+		return Term{}, err
+	}
+
+	if changed {
+		f.persist()
+	}
+
+	return term, nil
+}
+
+// GetNextN -
+// This function advances the sequence by k in a single critical section and
+// returns the k terms passed through, persisting the result once.
+// Overflows are handled by the overflow policy as in GetNext, except that
+// under OverflowReject the whole batch is refused with ErrOverflow, leaving the
+// sequence untouched, if it would overflow part way through.
+// This function is locked from starting while any other R/W operations are occuring
+func (f *Fibonacci) GetNextN(k int) ([]Term, error) {
+	f.lock()
+	defer f.rwMutex.Unlock()
+
+	if ModeUint64 == f.mode && OverflowReject == f.policy && uint64(k) > maxUint64Index-f.index {
+		return nil, ErrOverflow
+	}
+
+	terms := make([]Term, 0, k)
+	changed := false
+	for i := 0; i < k; i++ {
+		term, stepped, err := f.step()
+		if nil != err {
+			return nil, err
+		}
+
+		terms = append(terms, term)
+		changed = changed || stepped
+	}
+
+	if changed {
+		f.persist()
+	}
+
+	return terms, nil
+}
+
+// step -
+// This function moves the sequence forward by one, applying the overflow
+// policy, and reports whether the state changed. It does not persist, the
+// caller must hold the write lock.
+func (f *Fibonacci) step() (Term, bool, error) {
+	if ModeUint64 == f.mode && f.nextOverflowed {
+		if !f.saturated {
+			f.logger.Warn("sequence overflowed uint64", "index", f.index, "current", f.current, "policy", f.policy)
+		}
+
+		switch f.policy {
+		case OverflowWrap:
+			f.previous, f.current, f.next = 0, 0, 1
+			f.index = 0
+			f.nextOverflowed = false
+			f.epoch++
+			f.logger.Info("sequence wrapped around to 0", "epoch", f.epoch)
+		case OverflowSaturate:
+			f.saturated = true
+			f.counters.saturated++
+			return f.currentTerm(), false, nil
+		default:
+			return Term{}, false, ErrOverflow
+		}
+	} else {
+		f.advance()
+	}
+
+	f.counters.advances++
+	return f.currentTerm(), true, nil
+}
+
+// Back -
+// This function rewinds the sequence by one, the inverse of GetNext, and
+// returns the term it is now on. The rewind is persisted like an advance.
+// The sequence does not extend below F(0), rewinding from there returns
+// ErrAtStart, even after wrapping around under OverflowWrap.
+// This function is locked from starting while any other R/W operations are occuring
+func (f *Fibonacci) Back() (Term, error) {
+	f.lock()
 	defer f.rwMutex.Unlock()
 
-	oldNext := f.next
+	if 0 == f.index {
+		return Term{}, ErrAtStart
+	}
+
+	f.rewind()
+	f.persist()
+
+	return f.currentTerm(), nil
+}
+
+// Reset -
+// This function moves the sequence back to F(0) and clears the overflow epoch,
+// returning the term it was reset from along with that epoch. The reset is
+// persisted like an advance.
+// This function is locked from starting while any other R/W operations are occuring
+func (f *Fibonacci) Reset() (Term, uint64) {
+	f.lock()
+	defer f.rwMutex.Unlock()
+
+	from, epoch := f.currentTerm(), f.epoch
+
+	f.index, f.epoch = 0, 0
+	f.previous, f.current, f.next = 0, 0, 1
+	f.nextOverflowed, f.saturated = false, false
+	if ModeBig == f.mode {
+		f.bigPrevious, f.bigCurrent, f.bigNext = big.NewInt(0), big.NewInt(0), big.NewInt(1)
+	}
+
+	f.persist()
+
+	return from, epoch
+}
+
+// Seek -
+// This function moves the sequence to F(index), setting the previous, current
+// and next values at once, and returns the term it is now on. The move is
+// persisted like an advance, the overflow epoch is left as it is.
+// In uint64 mode an index past F(93) returns ErrIndexOutOfRange.
+// This function is locked from starting while any other R/W operations are occuring
+func (f *Fibonacci) Seek(index uint64) (Term, error) {
+	if ModeUint64 == f.mode && maxUint64Index < index {
+		return Term{}, ErrIndexOutOfRange
+	}
+
+	// Computed before locking, F(index) can take a while for a large index
+	current, next := bigPair(index)
+	previous := new(big.Int).Sub(next, current)
+	if 0 == index {
+		previous.SetUint64(0)
+	}
+
+	f.lock()
+	defer f.rwMutex.Unlock()
+
+	sought, err := fromTerms(
+		Options{Mode: f.mode, OverflowPolicy: f.policy}, index, f.epoch, previous, current, next,
+	)
+	if nil != err {
+		return Term{}, err
+	}
+
+	f.index = sought.index
+	f.previous, f.current, f.next = sought.previous, sought.current, sought.next
+	f.nextOverflowed, f.saturated = sought.nextOverflowed, false
+	f.bigPrevious, f.bigCurrent, f.bigNext = sought.bigPrevious, sought.bigCurrent, sought.bigNext
+
+	f.persist()
+
+	return f.currentTerm(), nil
+}
+
+// persist -
+// This function marks a change of state and queues it to be stored in the
+// state store in the background, the caller must hold the write lock
+func (f *Fibonacci) persist() {
+	f.version++
+
+	// Store in cache to restore from in case container goes boom
+	f.persister.enqueue(stateKey(f.key), f.state())
+}
+
+// save -
+// This function marks a change of state and stores it in the state store,
+// waiting for it to be written, the caller must hold the write lock
+func (f *Fibonacci) save(store StateStore) {
+	f.version++
+
+	if _, err := writeState(store, stateKey(f.key), f.state(), f.logger); nil != err {
+		f.logger.Error("could not write state", "version", f.version, "error", err)
+	}
+}
+
+// GetSequenceStats -
+// This function reports the position of the sequence and counts the
+// operations on it since it was created or restored
+func (f *Fibonacci) GetSequenceStats() SequenceStats {
+	f.rlock()
+	index, advances, saturated := f.index, f.counters.advances, f.counters.saturated
+	f.rwMutex.RUnlock()
+
+	return SequenceStats{
+		Index:            index,
+		Advances:         advances,
+		Saturated:        saturated,
+		LockAcquisitions: atomic.LoadUint64(&f.counters.lockAcquisitions),
+		LockWait:         time.Duration(atomic.LoadUint64(&f.counters.lockWaitNanos)),
+	}
+}
+
+// GetPersistenceStats -
+// This function reports on the write-behind pipeline persisting the sequence
+func (f *Fibonacci) GetPersistenceStats() PersistenceStats {
+	return f.persister.getStats()
+}
+
+// Close -
+// This function writes every change still waiting to be persisted and stops
+// the persistence worker
+func (f *Fibonacci) Close() {
+	f.persister.close()
+}
+
+// Flush -
+// This function closes the sequence, then writes its latest state to the
+// store synchronously, returning the error of that write. The state already
+// stored is left as it is when it is as new.
+func (f *Fibonacci) Flush(ctx context.Context) error {
+	f.Close()
+
+	f.rlock()
+	st := f.state()
+	f.rwMutex.RUnlock()
+
+	return f.persister.write(ctx, stateKey(f.key), st)
+}
+
+// advance -
+// This function moves the sequence forward by one and returns the new current
+// value, the caller must hold the write lock.
+// In uint64 mode the caller must first check that next has not overflowed.
+func (f *Fibonacci) advance() Number {
+	if ModeBig == f.mode {
+		sum := new(big.Int).Add(f.bigNext, f.bigCurrent)
+		f.bigPrevious = f.bigCurrent
+		f.bigCurrent = f.bigNext
+		f.bigNext = sum
+		f.index++
+
+		return NewBigNumber(f.bigCurrent)
+	}
+
+	var carry uint64
 	f.previous = f.current
 	f.current = f.next
-	f.next = f.current + f.previous
+	f.next, carry = bits.Add64(f.current, f.previous, 0)
+	f.nextOverflowed = 0 != carry
+	f.index++
 
-	// Store in cache to restore from in case container goes boom
-	go func(previous, current, next uint64) {
-		log.Printf(
-			"Updating redis with state:\n\tprevious: %v\n\tcurrent: %v\n\tnext: %v\n\n",
-			previous, current, next,
-		)
+	return NewNumber(f.current)
+}
 
-		if err := rdb.Set(
-			context.Background(), redisFibonacciKey, current, 0,
-		).Err(); nil != err {
-			log.Printf("Error updating redis state: %v", err)
+// rewind -
+// This function moves the sequence back by one, the caller must hold the
+// write lock and check that the sequence is not at F(0)
+func (f *Fibonacci) rewind() {
+	f.index--
+
+	if ModeBig == f.mode {
+		f.bigNext = f.bigCurrent
+		f.bigCurrent = f.bigPrevious
+		if 0 == f.index {
+			f.bigPrevious = new(big.Int)
+		} else {
+			f.bigPrevious = new(big.Int).Sub(f.bigNext, f.bigCurrent)
 		}
-	}(f.previous, f.current, f.next)
+		return
+	}
 
-	return oldNext
+	f.next = f.current
+	f.current = f.previous
+	if 0 == f.index {
+		f.previous = 0
+	} else {
+		f.previous = f.next - f.current
+	}
+	f.nextOverflowed, f.saturated = false, false
 }
 
 // GetPrevious -
-// This function will retrieve the previous value in the sequence
+// This function will retrieve the previous term in the sequence, which is 0
+// at index 0 at the start of the sequence
 // It will also set a reading lock
-func (f *Fibonacci) GetPrevious() uint64 {
-	f.rwMutex.RLock()
+func (f *Fibonacci) GetPrevious() Term {
+	f.rlock()
 	defer f.rwMutex.RUnlock()
 
-	return f.previous
+	term := Term{Value: f.previousNumber()}
+	if 0 < f.index {
+		term.Index = f.index - 1
+	}
+
+	return term
+}
+
+// lock -
+// This function takes the write lock, counting the time spent waiting for it
+func (f *Fibonacci) lock() {
+	start := time.Now()
+	f.rwMutex.Lock()
+	f.counters.waited(time.Since(start))
+}
+
+// rlock -
+// This function takes a read lock, counting the time spent waiting for it
+func (f *Fibonacci) rlock() {
+	start := time.Now()
+	f.rwMutex.RLock()
+	f.counters.waited(time.Since(start))
+}
+
+// The following helpers read the state without locking, the caller must hold
+// at least a read lock
+
+func (f *Fibonacci) currentTerm() Term {
+	return Term{Index: f.index, Value: f.currentNumber()}
+}
+
+func (f *Fibonacci) currentNumber() Number {
+	if ModeBig == f.mode {
+		return NewBigNumber(f.bigCurrent)
+	}
+
+	return NewNumber(f.current)
+}
+
+func (f *Fibonacci) nextNumber() Number {
+	if ModeBig == f.mode {
+		return NewBigNumber(f.bigNext)
+	}
+
+	return NewNumber(f.next)
+}
+
+func (f *Fibonacci) previousNumber() Number {
+	if ModeBig == f.mode {
+		return NewBigNumber(f.bigPrevious)
+	}
+
+	return NewNumber(f.previous)
 }
@@ -0,0 +1,107 @@
+package fibonacci
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+// Both modes are rewound every restartEvery steps so the uint64 path never
+// overflows and the big path is measured on comparably sized values
+const restartEvery = 90
+
+// Keeps the compiler from discarding benchmarked results
+var benchSink string
+
+func BenchmarkFibonacci_advance(b *testing.B) {
+	for _, mode := range []Mode{ModeUint64, ModeBig} {
+		b.Run(mode.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < b.N; i++ {
+				if 0 == i%restartEvery {
+					f = newFibonacci(mode, OverflowReject)
+				}
+				f.advance()
+			}
+		})
+	}
+}
+
+// The big path gets slower as the values grow, this measures a step taken
+// deep into the sequence
+func BenchmarkFibonacci_advanceDeep(b *testing.B) {
+	for _, depth := range []struct {
+		name  string
+		steps int
+	}{
+		{name: "F(1000)", steps: 1000},
+		{name: "F(10000)", steps: 10000},
+		{name: "F(100000)", steps: 100000},
+	} {
+		b.Run(depth.name, func(b *testing.B) {
+			start := newFibonacci(ModeBig, OverflowReject)
+			for i := 0; i < depth.steps; i++ {
+				start.advance()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			f := *start
+			for i := 0; i < b.N; i++ {
+				if 0 == i%restartEvery {
+					f = *start
+				}
+				f.advance()
+			}
+		})
+	}
+}
+
+func BenchmarkFibonacci_GetNext(b *testing.B) {
+	for _, mode := range []Mode{ModeUint64, ModeBig} {
+		b.Run(mode.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			p := newPersister(NewMemoryStore(), defaultQueueSize, logging.Discard())
+			defer p.close()
+
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < b.N; i++ {
+				if 0 == i%restartEvery {
+					f = newFibonacci(mode, OverflowReject)
+					f.persister = p
+				}
+				f.GetNext()
+			}
+		})
+	}
+}
+
+func BenchmarkFibonacci_GetCurrent(b *testing.B) {
+	for _, mode := range []Mode{ModeUint64, ModeBig} {
+		b.Run(mode.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < restartEvery; i++ {
+				f.advance()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchSink = f.GetCurrent().Value.String()
+			}
+		})
+	}
+}
+
+func BenchmarkNth(b *testing.B) {
+	for _, n := range []uint64{90, 1000, 10000} {
+		b.Run(strconv.FormatUint(n, 10), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				benchSink = Nth(n).String()
+			}
+		})
+	}
+}
@@ -16,8 +16,10 @@ func TestFibonacci_threadSafe(t *testing.T) {
 	for i := 0; i < 1000; i++ {
 		go func() {
 			f.GetCurrent()
-			f.GetNext(mockRdb{})
+			f.GetNext()
 			f.GetPrevious()
+			f.Back()
+			f.GetNextN(3)
 		}()
 	}
 }
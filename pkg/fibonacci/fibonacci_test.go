@@ -1,29 +1,71 @@
 package fibonacci
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"math/big"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
 	"github.com/go-redis/redis/v8"
 )
 
+// mockRdb -
+// value is returned for the legacy key and state for the state key, an empty
+// string behaves like a missing key
 type mockRdb struct {
 	value string
+	state string
 	err   error
 }
 
 func (mr mockRdb) Get(ctx context.Context, key string) *redis.StringCmd {
-	return redis.NewStringResult(mr.value, mr.err)
+	if nil != mr.err {
+		return redis.NewStringResult("", mr.err)
+	}
+
+	value := mr.value
+	if DefaultStateKey == key {
+		value = mr.state
+	}
+	if "" == value {
+		return redis.NewStringResult("", redis.Nil)
+	}
+
+	return redis.NewStringResult(value, nil)
 }
 
 func (mr mockRdb) Set(
 	ctx context.Context, key string, value interface{}, expiration time.Duration,
 ) *redis.StatusCmd {
-	return redis.NewStatusResult(mr.value, mr.err)
+	return redis.NewStatusResult("OK", mr.err)
+}
+
+func (mr mockRdb) Eval(
+	ctx context.Context, script string, keys []string, args ...interface{},
+) *redis.Cmd {
+	return redis.NewCmdResult(int64(1), mr.err)
+}
+
+func (mr mockRdb) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	return redis.NewIntResult(int64(len(keys)), mr.err)
+}
+
+func (mr mockRdb) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redis.NewScanCmdResult(nil, 0, mr.err)
+}
+
+func (mr mockRdb) Ping(ctx context.Context) *redis.StatusCmd {
+	return redis.NewStatusResult("PONG", mr.err)
+}
+
+func (mr mockRdb) Close() error {
+	return nil
 }
 
 func Test_restoreFibonacci(t *testing.T) {
@@ -46,6 +88,8 @@ func Test_restoreFibonacci(t *testing.T) {
 				},
 			},
 			want: &Fibonacci{
+				index:    5,
+				version:  1,
 				current:  5,
 				next:     8,
 				previous: 3,
@@ -78,7 +122,7 @@ func Test_restoreFibonacci(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := restoreFibonacci(tt.args.rdb)
+			got, err := restoreFibonacci(NewRedisStore(tt.args.rdb), Options{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("restoreFibonacci() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -95,17 +139,15 @@ func TestInitializeFibonacci(t *testing.T) {
 		rdb RedisClient
 	}
 	tests := []struct {
-		name string
-		args args
-		want *Fibonacci
+		name    string
+		args    args
+		want    *Fibonacci
+		wantErr bool
 	}{
 		{
-			name: "no restore",
+			name: "nothing stored",
 			args: args{
-				rdb: mockRdb{
-					value: "",
-					err:   errors.New("mock error"),
-				},
+				rdb: mockRdb{},
 			},
 			want: &Fibonacci{
 				current:  0,
@@ -114,6 +156,17 @@ func TestInitializeFibonacci(t *testing.T) {
 				rwMutex:  &sync.RWMutex{},
 			},
 		},
+		{
+			name: "store unreachable",
+			args: args{
+				rdb: mockRdb{
+					value: "",
+					err:   errors.New("mock error"),
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "with restore",
 			args: args{
@@ -123,6 +176,8 @@ func TestInitializeFibonacci(t *testing.T) {
 				},
 			},
 			want: &Fibonacci{
+				index:    5,
+				version:  1,
 				current:  5,
 				next:     8,
 				previous: 3,
@@ -132,7 +187,18 @@ func TestInitializeFibonacci(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := InitializeFibonacci(tt.args.rdb); !reflect.DeepEqual(got, tt.want) {
+			got, err := InitializeFibonacci(NewRedisStore(tt.args.rdb), Options{})
+			if (nil != err) != tt.wantErr {
+				t.Fatalf("InitializeFibonacci() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if nil != err {
+				return
+			}
+
+			// The persistence worker is covered by its own tests
+			got.Close()
+			got.persister = nil
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("InitializeFibonacci() = %v, want %v", got, tt.want)
 			}
 		})
@@ -141,6 +207,7 @@ func TestInitializeFibonacci(t *testing.T) {
 
 func TestFibonacci_GetCurrent(t *testing.T) {
 	type fields struct {
+		index    uint64
 		current  uint64
 		next     uint64
 		previous uint64
@@ -149,22 +216,24 @@ func TestFibonacci_GetCurrent(t *testing.T) {
 	tests := []struct {
 		name   string
 		fields fields
-		want   uint64
+		want   Term
 	}{
 		{
 			name: "happy path",
 			fields: fields{
+				index:    5,
 				current:  5,
 				next:     8,
 				previous: 3,
 				rwMutex:  &sync.RWMutex{},
 			},
-			want: 5,
+			want: Term{Index: 5, Value: NewNumber(5)},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := &Fibonacci{
+				index:    tt.fields.index,
 				current:  tt.fields.current,
 				next:     tt.fields.next,
 				previous: tt.fields.previous,
@@ -179,56 +248,61 @@ func TestFibonacci_GetCurrent(t *testing.T) {
 
 func TestFibonacci_GetNext(t *testing.T) {
 	type fields struct {
+		index    uint64
 		current  uint64
 		next     uint64
 		previous uint64
 		rwMutex  *sync.RWMutex
 	}
-	type args struct {
-		rdb RedisClient
-	}
 	tests := []struct {
 		name   string
 		fields fields
-		args   args
-		want   uint64
+		want   Term
 	}{
 		{
 			name: "happy path",
 			fields: fields{
+				index:    5,
 				current:  5,
 				next:     8,
 				previous: 3,
 				rwMutex:  &sync.RWMutex{},
 			},
-			args: args{
-				rdb: mockRdb{
-					value: "",
-					err:   nil,
-				},
-			},
-			want: 8,
+			want: Term{Index: 6, Value: NewNumber(8)},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := &Fibonacci{
+				index:    tt.fields.index,
 				current:  tt.fields.current,
 				next:     tt.fields.next,
 				previous: tt.fields.previous,
 				rwMutex:  tt.fields.rwMutex,
 			}
-			if got := f.GetNext(tt.args.rdb); got != tt.want {
+			got, err := f.GetNext()
+			if nil != err {
+				t.Fatalf("Fibonacci.GetNext() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("Fibonacci.GetNext() = %v, want %v", got, tt.want)
 			}
 
 			updated := &Fibonacci{
+				index:    tt.fields.index + 1,
+				version:  1,
 				current:  tt.fields.next,
 				next:     tt.fields.current + tt.fields.next,
 				previous: tt.fields.current,
 				rwMutex:  &sync.RWMutex{},
 			}
 
+			if stats := f.GetSequenceStats(); 1 != stats.Advances || 2 != stats.LockAcquisitions {
+				t.Errorf("Fibonacci.GetSequenceStats() = %+v, want 1 advance and 2 locks", stats)
+			}
+
+			// The counters are covered above
+			f.counters = sequenceCounters{}
 			if !reflect.DeepEqual(f, updated) {
 				t.Errorf("Failed to update state properly to: %v, got: %v instead", updated, f)
 			}
@@ -238,6 +312,7 @@ func TestFibonacci_GetNext(t *testing.T) {
 
 func TestFibonacci_GetPrevious(t *testing.T) {
 	type fields struct {
+		index    uint64
 		current  uint64
 		next     uint64
 		previous uint64
@@ -246,22 +321,35 @@ func TestFibonacci_GetPrevious(t *testing.T) {
 	tests := []struct {
 		name   string
 		fields fields
-		want   uint64
+		want   Term
 	}{
 		{
 			name: "happy path",
 			fields: fields{
+				index:    5,
 				current:  5,
 				next:     8,
 				previous: 3,
 				rwMutex:  &sync.RWMutex{},
 			},
-			want: 3,
+			want: Term{Index: 4, Value: NewNumber(3)},
+		},
+		{
+			name: "start",
+			fields: fields{
+				index:    0,
+				current:  0,
+				next:     1,
+				previous: 0,
+				rwMutex:  &sync.RWMutex{},
+			},
+			want: Term{Index: 0, Value: NewNumber(0)},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := &Fibonacci{
+				index:    tt.fields.index,
 				current:  tt.fields.current,
 				next:     tt.fields.next,
 				previous: tt.fields.previous,
@@ -273,3 +361,436 @@ func TestFibonacci_GetPrevious(t *testing.T) {
 		})
 	}
 }
+
+func TestFibonacci_GetNextN(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      Mode
+		policy    OverflowPolicy
+		start     int
+		k         int
+		wantFirst Term
+		wantLast  Term
+		wantErr   error
+	}{
+		{
+			name:      "happy path",
+			k:         12,
+			wantFirst: Term{Index: 1, Value: NewNumber(1)},
+			wantLast:  Term{Index: 12, Value: NewNumber(144)},
+		},
+		{
+			name:      "up to last uint64",
+			start:     90,
+			k:         3,
+			wantFirst: Term{Index: 91, Value: NewNumber(4660046610375530309)},
+			wantLast:  Term{Index: 93, Value: NewNumber(12200160415121876738)},
+		},
+		{
+			name:    "reject past last uint64",
+			start:   90,
+			k:       4,
+			wantErr: ErrOverflow,
+		},
+		{
+			name:      "wrap",
+			policy:    OverflowWrap,
+			start:     92,
+			k:         3,
+			wantFirst: Term{Index: 93, Value: NewNumber(12200160415121876738)},
+			wantLast:  Term{Index: 1, Value: NewNumber(1)},
+		},
+		{
+			name:      "saturate",
+			policy:    OverflowSaturate,
+			start:     92,
+			k:         3,
+			wantFirst: Term{Index: 93, Value: NewNumber(12200160415121876738)},
+			wantLast:  Term{Index: 93, Value: NewNumber(12200160415121876738)},
+		},
+		{
+			name:      "big",
+			mode:      ModeBig,
+			start:     90,
+			k:         10,
+			wantFirst: Term{Index: 91, Value: Nth(91)},
+			wantLast:  Term{Index: 100, Value: Nth(100)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := InitializeFibonacci(NewMemoryStore(), Options{Mode: tt.mode, OverflowPolicy: tt.policy})
+			if nil != err {
+				t.Fatalf("InitializeFibonacci() error = %v", err)
+			}
+			defer f.Close()
+			for i := 0; i < tt.start; i++ {
+				f.GetNext()
+			}
+			version := f.version
+
+			got, err := f.GetNextN(tt.k)
+			if err != tt.wantErr {
+				t.Fatalf("Fibonacci.GetNextN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if nil != err {
+				if uint64(tt.start) != f.GetCurrent().Index || version != f.version {
+					t.Errorf("Fibonacci.GetNextN() changed the sequence despite failing")
+				}
+				return
+			}
+
+			if tt.k != len(got) {
+				t.Fatalf("Fibonacci.GetNextN() returned %v terms, want %v", len(got), tt.k)
+			}
+			if first := got[0]; first.Index != tt.wantFirst.Index || first.Value.String() != tt.wantFirst.Value.String() {
+				t.Errorf("Fibonacci.GetNextN() first = %v, want %v", first, tt.wantFirst)
+			}
+			if last := got[len(got)-1]; last.Index != tt.wantLast.Index || last.Value.String() != tt.wantLast.Value.String() {
+				t.Errorf("Fibonacci.GetNextN() last = %v, want %v", last, tt.wantLast)
+			}
+			if version+1 != f.version {
+				t.Errorf("Fibonacci.GetNextN() persisted %v times, want once", f.version-version)
+			}
+		})
+	}
+}
+
+func TestFibonacci_Back(t *testing.T) {
+	tests := []struct {
+		name    string
+		big     bool
+		steps   int
+		want    Term
+		wantErr error
+	}{
+		{name: "at start", steps: 0, want: Term{}, wantErr: ErrAtStart},
+		{name: "to start", steps: 1, want: Term{Index: 0, Value: NewNumber(0)}},
+		{name: "happy path", steps: 12, want: Term{Index: 11, Value: NewNumber(89)}},
+		{name: "from last uint64", steps: 93, want: Term{Index: 92, Value: NewNumber(7540113804746346429)}},
+		{name: "big", big: true, steps: 12, want: Term{Index: 11, Value: NewBigNumber(big.NewInt(89))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := ModeUint64
+			if tt.big {
+				mode = ModeBig
+			}
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < tt.steps; i++ {
+				f.GetNext()
+			}
+
+			got, err := f.Back()
+			if err != tt.wantErr {
+				t.Fatalf("Fibonacci.Back() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fibonacci.Back() = %v, want %v", got, tt.want)
+			}
+
+			// The rewound state must be exactly F(n-1), F(n) and F(n+1)
+			if !validTerms(f.index, f.previousNumber().Big(), f.currentNumber().Big(), f.nextNumber().Big()) ||
+				f.nextOverflowed {
+				t.Errorf("Fibonacci.Back() left an invalid state at %v", f.index)
+			}
+		})
+	}
+}
+
+func TestFibonacci_Back_persisted(t *testing.T) {
+	store := NewMemoryStore()
+	f, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		f.GetNext()
+	}
+	f.Back()
+	f.Close()
+
+	restored, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	defer restored.Close()
+
+	if got, want := restored.GetCurrent(), (Term{Index: 4, Value: NewNumber(3)}); got != want {
+		t.Errorf("Restored current = %v, want %v", got, want)
+	}
+}
+
+func TestFibonacci_Reset(t *testing.T) {
+	for _, mode := range []Mode{ModeUint64, ModeBig} {
+		t.Run(mode.String(), func(t *testing.T) {
+			store := NewMemoryStore()
+			f, err := InitializeFibonacci(store, Options{Mode: mode, OverflowPolicy: OverflowWrap})
+			if nil != err {
+				t.Fatalf("InitializeFibonacci() error = %v", err)
+			}
+			f.epoch = 2
+			for i := 0; i < 12; i++ {
+				f.GetNext()
+			}
+
+			from, epoch := f.Reset()
+			if 12 != from.Index || 2 != epoch {
+				t.Errorf("Fibonacci.Reset() = %v, %v, want index 12 in epoch 2", from, epoch)
+			}
+			f.Close()
+
+			restored, err := InitializeFibonacci(store, Options{Mode: mode, OverflowPolicy: OverflowWrap})
+			if nil != err {
+				t.Fatalf("InitializeFibonacci() error = %v", err)
+			}
+			defer restored.Close()
+
+			fresh := newFibonacci(mode, OverflowWrap)
+			if !reflect.DeepEqual(restored.GetCurrent(), fresh.GetCurrent()) ||
+				!reflect.DeepEqual(restored.GetPrevious(), fresh.GetPrevious()) || 0 != restored.GetEpoch() {
+				t.Errorf("Restored state after reset = %v in epoch %v, want the start", restored.GetCurrent(), restored.GetEpoch())
+			}
+			if got, _ := restored.GetNext(); 1 != got.Index || "1" != got.Value.String() {
+				t.Errorf("Fibonacci.GetNext() after reset = %v, want F(1)", got)
+			}
+		})
+	}
+}
+
+func TestFibonacci_Seek(t *testing.T) {
+	f200, _ := bigPair(200)
+
+	tests := []struct {
+		name    string
+		big     bool
+		index   uint64
+		want    Term
+		wantErr error
+	}{
+		{name: "start", index: 0, want: Term{Index: 0, Value: NewNumber(0)}},
+		{name: "happy path", index: 12, want: Term{Index: 12, Value: NewNumber(144)}},
+		{name: "last uint64", index: 93, want: Term{Index: 93, Value: NewNumber(12200160415121876738)}},
+		{name: "past uint64", index: 94, want: Term{}, wantErr: ErrIndexOutOfRange},
+		{name: "big", big: true, index: 200, want: Term{Index: 200, Value: NewBigNumber(f200)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := ModeUint64
+			if tt.big {
+				mode = ModeBig
+			}
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < 5; i++ {
+				f.GetNext()
+			}
+
+			got, err := f.Seek(tt.index)
+			if err != tt.wantErr {
+				t.Fatalf("Fibonacci.Seek() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fibonacci.Seek() = %v, want %v", got, tt.want)
+			}
+
+			if nil == err && !validTerms(f.index, f.previousNumber().Big(), f.currentNumber().Big(), f.nextNumber().Big()) &&
+				!f.nextOverflowed {
+				t.Errorf("Fibonacci.Seek() left an invalid state at %v", f.index)
+			}
+		})
+	}
+}
+
+func TestFibonacci_Seek_persisted(t *testing.T) {
+	store := NewMemoryStore()
+	f, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	f.Seek(93)
+	f.Close()
+
+	restored, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	defer restored.Close()
+
+	if got, want := restored.GetCurrent(), (Term{Index: 93, Value: NewNumber(12200160415121876738)}); got != want {
+		t.Errorf("Restored current = %v, want %v", got, want)
+	}
+	if _, err := restored.GetNext(); ErrOverflow != err {
+		t.Errorf("Fibonacci.GetNext() error = %v after seeking to F(93), want %v", err, ErrOverflow)
+	}
+}
+
+func Test_restoreFibonacci_big(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantCurrent  string
+		wantNext     string
+		wantPrevious string
+		wantErr      bool
+	}{
+		{
+			name:         "small value",
+			value:        "5",
+			wantCurrent:  "5",
+			wantNext:     "8",
+			wantPrevious: "3",
+		},
+		{
+			name:         "beyond uint64",
+			value:        "354224848179261915075",
+			wantCurrent:  "354224848179261915075",
+			wantNext:     "573147844013817084101",
+			wantPrevious: "218922995834555169026",
+		},
+		{
+			name:    "bad number",
+			value:   "five",
+			wantErr: true,
+		},
+		{
+			name:    "negative number",
+			value:   "-5",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restoreFibonacci(NewRedisStore(mockRdb{value: tt.value}), Options{Mode: ModeBig})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("restoreFibonacci() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.GetCurrent().Value.String() != tt.wantCurrent ||
+				got.nextNumber().String() != tt.wantNext ||
+				got.GetPrevious().Value.String() != tt.wantPrevious {
+				t.Errorf(
+					"restoreFibonacci() = (%v, %v, %v), want (%v, %v, %v)",
+					got.GetPrevious().Value, got.GetCurrent().Value, got.nextNumber(),
+					tt.wantPrevious, tt.wantCurrent, tt.wantNext,
+				)
+			}
+		})
+	}
+}
+
+func TestFibonacci_GetNext_big(t *testing.T) {
+	f := newFibonacci(ModeBig, OverflowReject)
+
+	var got Term
+	for i := 0; i < 100; i++ {
+		var err error
+		if got, err = f.GetNext(); nil != err {
+			t.Fatalf("Fibonacci.GetNext() error = %v", err)
+		}
+	}
+
+	// F(100) is well past what a uint64 can hold
+	if want := "354224848179261915075"; got.Value.String() != want || 100 != got.Index {
+		t.Errorf("Fibonacci.GetNext() = %v, want F(100) = %v", got, want)
+	}
+	if !got.Value.IsBig() {
+		t.Errorf("Fibonacci.GetNext() returned a uint64 value in big mode")
+	}
+	if want := "218922995834555169026"; f.GetPrevious().Value.String() != want {
+		t.Errorf("Fibonacci.GetPrevious().Value = %v, want %v", f.GetPrevious().Value, want)
+	}
+}
+
+func TestFibonacci_GetNext_overflow(t *testing.T) {
+	const (
+		// F(92) and F(93), the largest Fibonacci number that fits in a uint64
+		f92 uint64 = 7540113804746346429
+		f93 uint64 = 12200160415121876738
+	)
+
+	tests := []struct {
+		name      string
+		policy    OverflowPolicy
+		want      Term
+		wantErr   error
+		wantEpoch uint64
+	}{
+		{
+			name:    "reject",
+			policy:  OverflowReject,
+			want:    Term{},
+			wantErr: ErrOverflow,
+		},
+		{
+			name:      "wrap",
+			policy:    OverflowWrap,
+			want:      Term{Index: 0, Value: NewNumber(0)},
+			wantEpoch: 1,
+		},
+		{
+			name:   "saturate",
+			policy: OverflowSaturate,
+			want:   Term{Index: 93, Value: NewNumber(f93)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFibonacci(ModeUint64, tt.policy)
+			for i := 0; i < 93; i++ {
+				if _, err := f.GetNext(); nil != err {
+					t.Fatalf("Fibonacci.GetNext() error = %v before overflowing", err)
+				}
+			}
+			if f.GetCurrent().Value != NewNumber(f93) || f.GetPrevious().Value != NewNumber(f92) {
+				t.Fatalf("Sequence did not reach F(93), got %v", f.GetCurrent().Value)
+			}
+
+			got, err := f.GetNext()
+			if err != tt.wantErr {
+				t.Errorf("Fibonacci.GetNext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Fibonacci.GetNext() = %v, want %v", got, tt.want)
+			}
+			if f.GetEpoch() != tt.wantEpoch {
+				t.Errorf("Fibonacci.GetEpoch() = %v, want %v", f.GetEpoch(), tt.wantEpoch)
+			}
+		})
+	}
+}
+
+func TestFibonacci_GetNext_saturated(t *testing.T) {
+	var buf bytes.Buffer
+	f := newFibonacci(ModeUint64, OverflowSaturate)
+	f.logger = logging.New(&buf, logging.Options{})
+
+	warnings := func() int {
+		return strings.Count(buf.String(), "sequence overflowed uint64")
+	}
+
+	if _, err := f.Seek(93); nil != err {
+		t.Fatalf("Fibonacci.Seek() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		f.GetNext()
+	}
+	if 1 != warnings() {
+		t.Errorf("Incorrect overflow warnings, wanted: 1 but got: %v", warnings())
+	}
+	if saturated := f.GetSequenceStats().Saturated; 3 != saturated {
+		t.Errorf("Incorrect saturated steps, wanted: 3 but got: %v", saturated)
+	}
+
+	// Saturating again after moving away is logged again
+	f.Back()
+	f.GetNext()
+	f.GetNext()
+	if 2 != warnings() {
+		t.Errorf("Incorrect overflow warnings, wanted: 2 but got: %v", warnings())
+	}
+}
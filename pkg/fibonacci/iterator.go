@@ -0,0 +1,61 @@
+package fibonacci
+
+import "math/big"
+
+// Iterator -
+// Walks the sequence term by term from any index without touching any
+// sequence state. Terms up to F(93) are produced as uint64s, larger terms as
+// big.Ints, matching Nth.
+type Iterator struct {
+	index uint64
+
+	// Terms index and index+1 while index is within uint64 range
+	small, smallNext uint64
+	// Terms index and index+1 once past it, replaced and never mutated
+	big, bigNext *big.Int
+}
+
+// NewIterator -
+// This function returns an Iterator positioned on F(from), found by fast
+// doubling so starting far into the sequence is O(log from)
+func NewIterator(from uint64) *Iterator {
+	it := &Iterator{index: from}
+
+	if maxUint64Index > from {
+		it.small, it.smallNext = uint64Nth(from), uint64Nth(from+1)
+	} else if maxUint64Index == from {
+		it.small = uint64Nth(from)
+	} else {
+		it.big, it.bigNext = bigPair(from)
+	}
+
+	return it
+}
+
+// Next -
+// This method returns the index and value of the current term, then moves
+// the Iterator on to the following one
+func (it *Iterator) Next() (uint64, Number) {
+	index := it.index
+	it.index++
+
+	if nil != it.big {
+		n := NewBigNumber(it.big)
+		it.big, it.bigNext = it.bigNext, new(big.Int).Add(it.big, it.bigNext)
+		return index, n
+	}
+
+	n := NewNumber(it.small)
+	switch {
+	case maxUint64Index == index:
+		// F(94) no longer fits, continue from here on in big.Ints
+		it.big, it.bigNext = bigPair(it.index)
+	case maxUint64Index == it.index:
+		// smallNext is F(93), the last term that fits
+		it.small = it.smallNext
+	default:
+		it.small, it.smallNext = it.smallNext, it.small+it.smallNext
+	}
+
+	return index, n
+}
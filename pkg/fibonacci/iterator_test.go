@@ -0,0 +1,35 @@
+package fibonacci
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  uint64
+		count int
+	}{
+		{name: "start", from: 0, count: 20},
+		{name: "across uint64 limit", from: 85, count: 20},
+		{name: "from last uint64", from: 93, count: 3},
+		{name: "from first big", from: 94, count: 3},
+		{name: "far in", from: 5000, count: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := NewIterator(tt.from)
+			for i := 0; i < tt.count; i++ {
+				want := tt.from + uint64(i)
+				index, got := it.Next()
+				if index != want {
+					t.Fatalf("Iterator.Next() index = %v, want %v", index, want)
+				}
+				if !reflect.DeepEqual(got, Nth(want)) {
+					t.Fatalf("Iterator.Next() at %v = %v, want %v", want, got, Nth(want))
+				}
+			}
+		})
+	}
+}
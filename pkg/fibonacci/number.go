@@ -0,0 +1,121 @@
+package fibonacci
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Mode -
+// Selects the integer representation used to hold the sequence state
+type Mode int
+
+const (
+	// ModeUint64 stores the sequence in uint64s, which is fast but limited to
+	// F(93) before the values no longer fit
+	ModeUint64 Mode = iota
+	// ModeBig stores the sequence in math/big.Ints, which grow without limit
+	ModeBig
+)
+
+// ParseMode -
+// This function converts a mode name ("uint64" or "big") into a Mode.
+// An empty string selects the default uint64 mode.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "uint64":
+		return ModeUint64, nil
+	case "big":
+		return ModeBig, nil
+	}
+
+	return ModeUint64, fmt.Errorf("unknown sequence mode: %q", s)
+}
+
+// String -
+// This method returns the name of the mode as accepted by ParseMode
+func (m Mode) String() string {
+	if ModeBig == m {
+		return "big"
+	}
+
+	return "uint64"
+}
+
+// Number -
+// A single value of the sequence, held either as a uint64 or as a big.Int
+// depending on the mode of the sequence it came from.
+// The big.Int of a Number is never modified once the Number is created.
+type Number struct {
+	small uint64
+	big   *big.Int
+}
+
+// NewNumber -
+// This function wraps a uint64 value as a Number
+func NewNumber(v uint64) Number {
+	return Number{small: v}
+}
+
+// NewBigNumber -
+// This function wraps a big.Int value as a Number, the caller must not modify
+// v afterwards
+func NewBigNumber(v *big.Int) Number {
+	return Number{big: v}
+}
+
+// IsBig -
+// This method reports whether the Number is held as a big.Int
+func (n Number) IsBig() bool {
+	return nil != n.big
+}
+
+// Uint64 -
+// This method returns the value as a uint64, the result is only meaningful
+// when IsBig is false
+func (n Number) Uint64() uint64 {
+	if n.IsBig() {
+		return n.big.Uint64()
+	}
+
+	return n.small
+}
+
+// Big -
+// This method returns a copy of the value as a big.Int regardless of mode
+func (n Number) Big() *big.Int {
+	if n.IsBig() {
+		return new(big.Int).Set(n.big)
+	}
+
+	return new(big.Int).SetUint64(n.small)
+}
+
+// String -
+// This method returns the decimal representation of the value
+func (n Number) String() string {
+	if n.IsBig() {
+		return n.big.String()
+	}
+
+	return strconv.FormatUint(n.small, 10)
+}
+
+// MarshalJSON -
+// uint64 values are written as JSON numbers, big values as JSON strings since
+// most JSON decoders cannot hold integers of arbitrary size
+func (n Number) MarshalJSON() ([]byte, error) {
+	if n.IsBig() {
+		return []byte(strconv.Quote(n.big.String())), nil
+	}
+
+	return []byte(strconv.FormatUint(n.small, 10)), nil
+}
+
+// Term -
+// A value of the sequence together with its position, Value is F(Index)
+type Term struct {
+	Index uint64
+	Value Number
+}
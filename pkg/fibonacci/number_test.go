@@ -0,0 +1,64 @@
+package fibonacci
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Mode
+		wantErr bool
+	}{
+		{name: "default", input: "", want: ModeUint64},
+		{name: "uint64", input: "uint64", want: ModeUint64},
+		{name: "big", input: "BIG", want: ModeBig},
+		{name: "unknown", input: "float", want: ModeUint64, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumber_MarshalJSON(t *testing.T) {
+	huge, _ := new(big.Int).SetString("354224848179261915075", 10)
+
+	tests := []struct {
+		name   string
+		number Number
+		want   string
+	}{
+		{
+			name:   "uint64",
+			number: NewNumber(144),
+			want:   `144`,
+		},
+		{
+			name:   "big",
+			number: NewBigNumber(huge),
+			want:   `"354224848179261915075"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.number.MarshalJSON()
+			if nil != err {
+				t.Fatalf("Number.MarshalJSON() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Number.MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package fibonacci
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrOverflow -
+// Returned by GetNext under OverflowReject when the next value does not fit in
+// a uint64
+var ErrOverflow = errors.New("next value in the sequence overflows uint64")
+
+// OverflowPolicy -
+// Decides what a uint64 mode sequence does once the next value no longer fits
+type OverflowPolicy int
+
+const (
+	// OverflowReject refuses to advance and returns ErrOverflow
+	OverflowReject OverflowPolicy = iota
+	// OverflowWrap restarts the sequence at F(0) and increments its epoch
+	OverflowWrap
+	// OverflowSaturate stays on the last value that fits
+	OverflowSaturate
+)
+
+// ParseOverflowPolicy -
+// This function converts a policy name ("reject", "wrap" or "saturate") into an
+// OverflowPolicy. An empty string selects the default reject policy.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "reject":
+		return OverflowReject, nil
+	case "wrap":
+		return OverflowWrap, nil
+	case "saturate":
+		return OverflowSaturate, nil
+	}
+
+	return OverflowReject, fmt.Errorf("unknown overflow policy: %q", s)
+}
+
+// String -
+// This method returns the name of the policy as accepted by ParseOverflowPolicy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowWrap:
+		return "wrap"
+	case OverflowSaturate:
+		return "saturate"
+	}
+
+	return "reject"
+}
@@ -0,0 +1,30 @@
+package fibonacci
+
+import "testing"
+
+func TestParseOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    OverflowPolicy
+		wantErr bool
+	}{
+		{name: "default", input: "", want: OverflowReject},
+		{name: "reject", input: "reject", want: OverflowReject},
+		{name: "wrap", input: "Wrap", want: OverflowWrap},
+		{name: "saturate", input: "saturate", want: OverflowSaturate},
+		{name: "unknown", input: "explode", want: OverflowReject, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOverflowPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOverflowPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseOverflowPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package fibonacci
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+const (
+	// Maximum number of distinct keys waiting to be written before enqueueing
+	// blocks, updates to a key already waiting never count against it
+	defaultQueueSize = 1024
+
+	// Pause before retrying a state that failed to be written
+	persistRetryDelay = 500 * time.Millisecond
+
+	// Deadline for a single write to the state store
+	persistTimeout = 5 * time.Second
+)
+
+// PersistenceStats -
+// A snapshot of the write-behind pipeline persisting the sequence
+type PersistenceStats struct {
+	// Number of states waiting to be written
+	QueueDepth int
+	// Age of the oldest change not yet written, zero when the queue is empty
+	Lag time.Duration
+	// Version of the last state written, and when it was written
+	LastVersion uint64
+	LastWritten time.Time
+
+	Written   uint64
+	Coalesced uint64
+	Stale     uint64
+	Failed    uint64
+}
+
+// pendingState -
+// The latest state waiting to be written for a key, queuedAt is kept from the
+// first update so coalescing does not hide how long the key has waited
+type pendingState struct {
+	state    State
+	queuedAt time.Time
+}
+
+// persister -
+// A single background worker writing states to the state store in order.
+// Updates to a key that is already waiting are coalesced into the latest
+// state, and every write is versioned so the store never goes backwards.
+type persister struct {
+	store    StateStore
+	capacity int
+	logger   *logging.Logger
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending map[string]*pendingState
+	order   []string
+	closed  bool
+	stats   PersistenceStats
+	done    chan struct{}
+}
+
+// newPersister -
+// This function starts a persister writing to store
+func newPersister(store StateStore, capacity int, logger *logging.Logger) *persister {
+	p := &persister{
+		store:    store,
+		capacity: capacity,
+		logger:   logger,
+		pending:  map[string]*pendingState{},
+		done:     make(chan struct{}),
+	}
+	p.cond = sync.NewCond(&p.mutex)
+
+	go p.run()
+	return p
+}
+
+// enqueue -
+// This function queues a state to be written, replacing any older state still
+// waiting for the same key. It blocks while the queue is full.
+// A nil persister discards the state.
+func (p *persister) enqueue(key string, st State) {
+	if nil == p {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if existing, ok := p.pending[key]; ok {
+		if st.Version > existing.state.Version {
+			existing.state = st
+		}
+		p.stats.Coalesced++
+		return
+	}
+
+	for len(p.pending) >= p.capacity && !p.closed {
+		p.cond.Wait()
+	}
+
+	if p.closed {
+		p.logger.Warn("dropping state, persistence is closed", "key", key, "version", st.Version)
+		return
+	}
+
+	p.pending[key] = &pendingState{state: st, queuedAt: time.Now()}
+	p.order = append(p.order, key)
+	p.cond.Broadcast()
+}
+
+// run -
+// This function is the worker loop, it exits once closed and drained
+func (p *persister) run() {
+	defer close(p.done)
+
+	for {
+		p.mutex.Lock()
+		for 0 == len(p.order) && !p.closed {
+			p.cond.Wait()
+		}
+
+		if 0 == len(p.order) {
+			p.mutex.Unlock()
+			return
+		}
+
+		key := p.order[0]
+		p.order = p.order[1:]
+		item := p.pending[key]
+		delete(p.pending, key)
+		closed := p.closed
+		p.cond.Broadcast()
+		p.mutex.Unlock()
+
+		written, err := writeState(p.store, key, item.state, p.logger)
+
+		p.mutex.Lock()
+		switch {
+		case nil != err:
+			p.logger.Error("could not write state", "key", key, "version", item.state.Version, "error", err)
+			p.stats.Failed++
+
+			// Retry unless a newer state has been queued in the meantime
+			if _, newer := p.pending[key]; !newer && !closed {
+				p.pending[key] = item
+				p.order = append(p.order, key)
+			}
+		case written:
+			p.stats.Written++
+			p.stats.LastVersion = item.state.Version
+			p.stats.LastWritten = time.Now()
+		default:
+			p.stats.Stale++
+		}
+		p.mutex.Unlock()
+
+		if nil != err && !closed {
+			time.Sleep(persistRetryDelay)
+		}
+	}
+}
+
+// getStats -
+// This function returns a snapshot of the pipeline, a nil persister reports
+// an empty one
+func (p *persister) getStats() PersistenceStats {
+	if nil == p {
+		return PersistenceStats{}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.stats
+	stats.QueueDepth = len(p.order)
+	if 0 < len(p.order) {
+		stats.Lag = time.Since(p.pending[p.order[0]].queuedAt)
+	}
+
+	return stats
+}
+
+// close -
+// This function writes every state still waiting and stops the worker
+func (p *persister) close() {
+	if nil == p {
+		return
+	}
+
+	p.mutex.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+
+	<-p.done
+}
+
+// write -
+// This function writes the state itself, once the worker has been closed, so
+// the store holds it even when the last write of the worker failed. A nil
+// persister writes nothing.
+func (p *persister) write(ctx context.Context, key string, st State) error {
+	if nil == p {
+		return nil
+	}
+
+	written, err := p.store.CompareAndSwap(ctx, key, st)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch {
+	case nil != err:
+		p.stats.Failed++
+	case written:
+		p.stats.Written++
+		p.stats.LastVersion = st.Version
+		p.stats.LastWritten = time.Now()
+	default:
+		p.stats.Stale++
+	}
+
+	return err
+}
+
+// writeState -
+// This function writes the state through the store's versioned
+// compare-and-swap, reporting false when the state stored is already as new
+func writeState(store StateStore, key string, st State, logger *logging.Logger) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+
+	logger.Debug("writing state", "key", key, "version", st.Version, "index", st.Index, "epoch", st.Epoch)
+
+	return store.CompareAndSwap(ctx, key, st)
+}
@@ -0,0 +1,256 @@
+package fibonacci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis -
+// This function starts a miniredis server and a client connected to it, both
+// are shut down when the test ends
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if nil != err {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		mr.Close()
+	})
+
+	return mr, rdb
+}
+
+// storedState -
+// This function decodes the state held by miniredis under key
+func storedState(t *testing.T, mr *miniredis.Miniredis, key string) State {
+	t.Helper()
+
+	raw, err := mr.Get(key)
+	if nil != err {
+		t.Fatalf("Failed to read %v from miniredis: %v", key, err)
+	}
+
+	var st State
+	if err := json.Unmarshal([]byte(raw), &st); nil != err {
+		t.Fatalf("Failed to decode state %q: %v", raw, err)
+	}
+
+	return st
+}
+
+func Test_persister_latestWins(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	p := newPersister(NewRedisStore(rdb), defaultQueueSize, nil)
+
+	for v := uint64(1); v <= 500; v++ {
+		p.enqueue(DefaultStateKey, State{Schema: stateSchemaVersion, Version: v, Index: v})
+	}
+	p.close()
+
+	if got := storedState(t, mr, DefaultStateKey); 500 != got.Version || 500 != got.Index {
+		t.Errorf("Stored state = %+v, want version and index 500", got)
+	}
+
+	stats := p.getStats()
+	if 0 != stats.QueueDepth || 0 != stats.Lag {
+		t.Errorf("Queue not drained after close: %+v", stats)
+	}
+	if 500 != stats.Written+stats.Coalesced+stats.Stale {
+		t.Errorf("Not every update was accounted for: %+v", stats)
+	}
+	if 500 != stats.LastVersion {
+		t.Errorf("LastVersion = %v, want 500", stats.LastVersion)
+	}
+	if stats.LastWritten.IsZero() || time.Since(stats.LastWritten) > time.Minute {
+		t.Errorf("LastWritten = %v, want the time of the last write", stats.LastWritten)
+	}
+}
+
+func Test_persister_staleWrite(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Set(DefaultStateKey, `{"schema":1,"version":10,"index":10}`)
+
+	p := newPersister(NewRedisStore(rdb), defaultQueueSize, nil)
+	p.enqueue(DefaultStateKey, State{Schema: stateSchemaVersion, Version: 5, Index: 5})
+	p.close()
+
+	if got := storedState(t, mr, DefaultStateKey); 10 != got.Version {
+		t.Errorf("Stale state replaced the stored one: %+v", got)
+	}
+	if stats := p.getStats(); 1 != stats.Stale || 0 != stats.Written {
+		t.Errorf("Stale write not reported: %+v", stats)
+	}
+}
+
+func Test_persister_stats(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Close()
+
+	p := newPersister(NewRedisStore(rdb), defaultQueueSize, nil)
+	p.enqueue("first", State{Version: 1})
+	p.enqueue("second", State{Version: 1})
+	p.enqueue("second", State{Version: 2})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for 0 == p.getStats().Failed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := p.getStats()
+	if 0 == stats.Failed {
+		t.Fatalf("Expected writes to fail with redis down: %+v", stats)
+	}
+	if 2 != stats.QueueDepth {
+		t.Errorf("QueueDepth = %v, want 2", stats.QueueDepth)
+	}
+	if 0 >= stats.Lag {
+		t.Errorf("Lag = %v, want it to grow while writes fail", stats.Lag)
+	}
+	if 1 != stats.Coalesced {
+		t.Errorf("Coalesced = %v, want 1", stats.Coalesced)
+	}
+	if !stats.LastWritten.IsZero() {
+		t.Errorf("LastWritten = %v, want none while writes fail", stats.LastWritten)
+	}
+
+	p.close()
+}
+
+func Test_persister_boundedQueue(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Close()
+
+	p := newPersister(NewRedisStore(rdb), 1, nil)
+	p.enqueue("first", State{Version: 1})
+
+	// Wait for the worker to pick up the first key so the queue holds one
+	deadline := time.Now().Add(5 * time.Second)
+	for 0 == p.getStats().Failed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	enqueued := make(chan struct{})
+	go func() {
+		p.enqueue("second", State{Version: 1})
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+		t.Errorf("enqueue() did not block on a full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.close()
+	<-enqueued
+}
+
+func TestFibonacci_persistence(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+
+	f, err := InitializeFibonacci(NewRedisStore(rdb), Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.GetNext()
+		}()
+	}
+	wg.Wait()
+	f.Close()
+
+	if got := storedState(t, mr, DefaultStateKey); 50 != got.Index || 50 != got.Version {
+		t.Errorf("Stored state = %+v, want index and version 50", got)
+	}
+
+	restored, err := InitializeFibonacci(NewRedisStore(rdb), Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	defer restored.Close()
+
+	if restored.GetCurrent().Value != f.GetCurrent().Value || restored.GetPrevious().Value != f.GetPrevious().Value {
+		t.Errorf(
+			"Restored (%v, %v), want (%v, %v)",
+			restored.GetPrevious().Value, restored.GetCurrent().Value, f.GetPrevious().Value, f.GetCurrent().Value,
+		)
+	}
+}
+
+// flakyStore -
+// A MemoryStore whose first writes fail
+type flakyStore struct {
+	*MemoryStore
+
+	mutex    sync.Mutex
+	failures int
+}
+
+func (fs *flakyStore) CompareAndSwap(ctx context.Context, key string, st State) (bool, error) {
+	fs.mutex.Lock()
+	if 0 < fs.failures {
+		fs.failures--
+		fs.mutex.Unlock()
+		return false, errors.New("store unavailable")
+	}
+	fs.mutex.Unlock()
+
+	return fs.MemoryStore.CompareAndSwap(ctx, key, st)
+}
+
+func TestFibonacci_Flush(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wantErr  bool
+	}{
+		{name: "written by the worker", failures: 0},
+		{name: "written by the flush", failures: 1},
+		{name: "store down", failures: 1000, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &flakyStore{MemoryStore: NewMemoryStore(), failures: tt.failures}
+			f, err := InitializeFibonacci(store, Options{})
+			if nil != err {
+				t.Fatalf("InitializeFibonacci() error = %v", err)
+			}
+			f.GetNextN(10)
+
+			if err := f.Flush(context.Background()); (nil != err) != tt.wantErr {
+				t.Fatalf("Fibonacci.Flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			st, err := store.Load(context.Background(), DefaultStateKey)
+			if nil != err {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if 10 != st.Index {
+				t.Errorf("Flushed index = %v, want 10", st.Index)
+			}
+			if stats := f.GetPersistenceStats(); 0 != stats.QueueDepth || 1 != stats.LastVersion {
+				t.Errorf("Stats after Flush() = %+v, want an empty queue and version 1", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package fibonacci
+
+import (
+	"container/list"
+	"errors"
+	"math/bits"
+	"sync"
+)
+
+// ErrInvalidModulus -
+// Returned by PisanoPeriod for a modulus of 0
+var ErrInvalidModulus = errors.New("modulus must be at least 1")
+
+// PisanoPeriod -
+// This function returns the period with which F(n) mod m repeats, found by
+// stepping through the sequence mod m until it is back on 0, 1. The period is
+// at most 6m, so this takes time linear in m.
+func PisanoPeriod(m uint64) (uint64, error) {
+	if 0 == m {
+		return 0, ErrInvalidModulus
+	}
+
+	first, second := uint64(0), 1%m
+	a, b := first, second
+	for period := uint64(1); ; period++ {
+		// a and b are below m so their sum overflows at most once
+		sum, carry := bits.Add64(a, b, 0)
+		if 0 != carry || sum >= m {
+			sum -= m
+		}
+
+		a, b = b, sum
+		if first == a && second == b {
+			return period, nil
+		}
+	}
+}
+
+// PisanoCache -
+// Pisano periods already computed, keeping the most recently used ones up to
+// a fixed number of moduli
+type PisanoCache struct {
+	size int
+
+	mutex   sync.Mutex
+	order   *list.List
+	periods map[uint64]*list.Element
+}
+
+// pisanoEntry -
+// A cached period, as held in the order list of a PisanoCache
+type pisanoEntry struct {
+	modulus uint64
+	period  uint64
+}
+
+// NewPisanoCache -
+// This function creates a cache holding the periods of up to size moduli
+func NewPisanoCache(size int) *PisanoCache {
+	return &PisanoCache{
+		size:    size,
+		order:   list.New(),
+		periods: map[uint64]*list.Element{},
+	}
+}
+
+// Period -
+// This function returns the Pisano period of m, computing it unless it is
+// cached. The lock is not held while computing, so concurrent requests for a
+// new modulus may each compute it.
+func (pc *PisanoCache) Period(m uint64) (uint64, error) {
+	pc.mutex.Lock()
+	if el, ok := pc.periods[m]; ok {
+		pc.order.MoveToFront(el)
+		pc.mutex.Unlock()
+		return el.Value.(pisanoEntry).period, nil
+	}
+	pc.mutex.Unlock()
+
+	period, err := PisanoPeriod(m)
+	if nil != err {
+		return 0, err
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if _, ok := pc.periods[m]; !ok && 0 < pc.size {
+		pc.periods[m] = pc.order.PushFront(pisanoEntry{modulus: m, period: period})
+		if pc.order.Len() > pc.size {
+			oldest := pc.order.Remove(pc.order.Back()).(pisanoEntry)
+			delete(pc.periods, oldest.modulus)
+		}
+	}
+
+	return period, nil
+}
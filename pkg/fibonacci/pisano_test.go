@@ -0,0 +1,55 @@
+package fibonacci
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPisanoPeriod(t *testing.T) {
+	tests := []struct {
+		m       uint64
+		want    uint64
+		wantErr error
+	}{
+		{m: 0, want: 0, wantErr: ErrInvalidModulus},
+		{m: 1, want: 1},
+		{m: 2, want: 3},
+		{m: 3, want: 8},
+		{m: 10, want: 60},
+		{m: 100, want: 300},
+		{m: 1000, want: 1500},
+		{m: 1000000, want: 1500000},
+	}
+	for _, tt := range tests {
+		got, err := PisanoPeriod(tt.m)
+		if err != tt.wantErr || got != tt.want {
+			t.Errorf("PisanoPeriod(%v) = %v, %v, want %v, %v", tt.m, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPisanoCache_Period(t *testing.T) {
+	pc := NewPisanoCache(2)
+
+	for _, m := range []uint64{10, 3, 10, 5} {
+		if _, err := pc.Period(m); nil != err {
+			t.Fatalf("PisanoCache.Period(%v) error = %v", m, err)
+		}
+	}
+
+	// 3 was the least recently used when 5 was added
+	cached := []uint64{}
+	for el := pc.order.Front(); nil != el; el = el.Next() {
+		cached = append(cached, el.Value.(pisanoEntry).modulus)
+	}
+	if want := []uint64{5, 10}; !reflect.DeepEqual(cached, want) || 2 != len(pc.periods) {
+		t.Errorf("PisanoCache holds %v, want %v", cached, want)
+	}
+
+	if got, err := pc.Period(10); 60 != got || nil != err {
+		t.Errorf("Cached PisanoCache.Period(10) = %v, %v, want 60", got, err)
+	}
+	if _, err := pc.Period(0); ErrInvalidModulus != err {
+		t.Errorf("PisanoCache.Period(0) error = %v, want %v", err, ErrInvalidModulus)
+	}
+}
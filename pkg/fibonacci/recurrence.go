@@ -0,0 +1,367 @@
+package fibonacci
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+// Recurrence -
+// A sequence defined by an order-k linear recurrence, stepped through like
+// Fibonacci. In uint64 mode every term handed out must fit in a uint64, one
+// that is negative or too large is handled by the overflow policy, with
+// OverflowWrap restarting the sequence from its seeds.
+type Recurrence struct {
+	// First so its words stay 64 bit aligned, like those of Fibonacci
+	counters sequenceCounters
+
+	mode   Mode
+	policy OverflowPolicy
+	spec   RecurrenceSpec
+
+	index uint64
+	epoch uint64
+	// The term before the current one, 0 at index 0
+	previous *big.Int
+	// The k terms from the current one onwards, window[0] is the current term.
+	// Like the terms of Fibonacci in ModeBig the window and its values are
+	// replaced rather than modified in place.
+	window []*big.Int
+	// Set once a term that does not fit is logged under OverflowSaturate, so it
+	// is not logged again on every step staying on the last term
+	saturated bool
+
+	key       string
+	version   uint64
+	persister *persister
+	logger    *logging.Logger
+
+	rwMutex *sync.RWMutex
+}
+
+// newRecurrence -
+// This function creates a recurrence at its seeds, refusing a spec that does
+// not define a sequence or whose first seed does not fit the mode
+func newRecurrence(spec RecurrenceSpec, mode Mode, policy OverflowPolicy) (*Recurrence, error) {
+	if err := spec.Validate(); nil != err {
+		return nil, err
+	}
+	if ModeUint64 == mode && 0 == spec.Modulus && !fitsUint64(spec.Seeds[0]) {
+		return nil, fmt.Errorf("%w: first seed %v does not fit in uint64 mode", ErrInvalidRecurrence, spec.Seeds[0])
+	}
+
+	r := &Recurrence{
+		mode:    mode,
+		policy:  policy,
+		spec:    copySpec(spec),
+		rwMutex: &sync.RWMutex{},
+	}
+	for i, seed := range r.spec.Seeds {
+		r.spec.Seeds[i] = r.reduce(seed)
+	}
+	r.restart()
+
+	return r, nil
+}
+
+// GetSpec -
+// This function returns the coefficients and seeds defining the sequence
+func (r *Recurrence) GetSpec() RecurrenceSpec {
+	return copySpec(r.spec)
+}
+
+// GetMode -
+// This function returns the mode the sequence was created with
+func (r *Recurrence) GetMode() Mode {
+	return r.mode
+}
+
+// GetOverflowPolicy -
+// This function returns the overflow policy the sequence was created with
+func (r *Recurrence) GetOverflowPolicy() OverflowPolicy {
+	return r.policy
+}
+
+// GetEpoch -
+// This function returns the number of times the sequence has wrapped around
+// under OverflowWrap
+func (r *Recurrence) GetEpoch() uint64 {
+	r.rwMutex.RLock()
+	defer r.rwMutex.RUnlock()
+
+	return r.epoch
+}
+
+// GetCurrent -
+// This function will retrieve the term the sequence is currently on.
+// It will also set a reading lock.
+func (r *Recurrence) GetCurrent() Term {
+	r.rwMutex.RLock()
+	defer r.rwMutex.RUnlock()
+
+	return r.currentTerm()
+}
+
+// GetPrevious -
+// This function will retrieve the previous term in the sequence, which is 0
+// at index 0 at the start of the sequence
+// It will also set a reading lock
+func (r *Recurrence) GetPrevious() Term {
+	r.rwMutex.RLock()
+	defer r.rwMutex.RUnlock()
+
+	term := Term{Value: r.number(r.previous)}
+	if 0 < r.index {
+		term.Index = r.index - 1
+	}
+
+	return term
+}
+
+// GetNext -
+// This function advances the sequence by one and returns the term it is now
+// on. In uint64 mode a term that does not fit is handled by the overflow
+// policy, only OverflowReject returns an error (ErrOverflow).
+// This function is locked from starting while any other R/W operations are occuring
+func (r *Recurrence) GetNext() (Term, error) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+
+	term, changed, err := r.step()
+	if nil != err {
+		return Term{}, err
+	}
+
+	if changed {
+		r.persist()
+	}
+
+	return term, nil
+}
+
+// GetNextN -
+// This function advances the sequence by k in a single critical section and
+// returns the k terms passed through, persisting the result once.
+// Under OverflowReject the whole batch is refused with ErrOverflow, leaving the
+// sequence untouched, if it would overflow part way through.
+// This function is locked from starting while any other R/W operations are occuring
+func (r *Recurrence) GetNextN(k int) ([]Term, error) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+
+	// The terms are never modified in place so the old ones can be put back
+	index, epoch, previous, window := r.index, r.epoch, r.previous, r.window
+	saturated, counters := r.saturated, r.counters
+
+	terms := make([]Term, 0, k)
+	changed := false
+	for i := 0; i < k; i++ {
+		term, stepped, err := r.step()
+		if nil != err {
+			r.index, r.epoch, r.previous, r.window = index, epoch, previous, window
+			r.saturated, r.counters = saturated, counters
+			return nil, err
+		}
+
+		terms = append(terms, term)
+		changed = changed || stepped
+	}
+
+	if changed {
+		r.persist()
+	}
+
+	return terms, nil
+}
+
+// step -
+// This function moves the sequence forward by one, applying the overflow
+// policy, and reports whether the state changed. It does not persist, the
+// caller must hold the write lock.
+func (r *Recurrence) step() (Term, bool, error) {
+	next := r.nextValue()
+	if ModeUint64 == r.mode && !fitsUint64(next) {
+		if !r.saturated {
+			r.logger.Warn("sequence term does not fit in uint64", "index", r.index+1, "term", next, "policy", r.policy)
+		}
+
+		switch r.policy {
+		case OverflowWrap:
+			r.restart()
+			r.epoch++
+			r.logger.Info("sequence wrapped around to its seeds", "epoch", r.epoch)
+			r.counters.advances++
+			return r.currentTerm(), true, nil
+		case OverflowSaturate:
+			r.saturated = true
+			r.counters.saturated++
+			return r.currentTerm(), false, nil
+		default:
+			return Term{}, false, ErrOverflow
+		}
+	}
+
+	window := make([]*big.Int, 0, len(r.window))
+	window = append(window, r.window[1:]...)
+	window = append(window, r.following())
+
+	r.previous = r.window[0]
+	r.window = window
+	r.index++
+
+	r.counters.advances++
+	return r.currentTerm(), true, nil
+}
+
+// GetSequenceStats -
+// This function reports the position of the sequence and counts the terms it
+// advanced by and the steps it saturated on since it was created or restored.
+// Unlike Fibonacci it does not time its lock.
+func (r *Recurrence) GetSequenceStats() SequenceStats {
+	r.rwMutex.RLock()
+	defer r.rwMutex.RUnlock()
+
+	return SequenceStats{
+		Index:     r.index,
+		Advances:  r.counters.advances,
+		Saturated: r.counters.saturated,
+	}
+}
+
+// Reset -
+// This function moves the sequence back to its seeds and clears the overflow
+// epoch, returning the term it was reset from along with that epoch
+// This function is locked from starting while any other R/W operations are occuring
+func (r *Recurrence) Reset() (Term, uint64) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+
+	from, epoch := r.currentTerm(), r.epoch
+
+	r.restart()
+	r.epoch = 0
+	r.persist()
+
+	return from, epoch
+}
+
+// persist -
+// This function marks a change of state and queues it to be stored in the
+// state store in the background, the caller must hold the write lock
+func (r *Recurrence) persist() {
+	r.version++
+
+	r.persister.enqueue(stateKey(r.key), r.state())
+}
+
+// save -
+// This function marks a change of state and stores it in the state store,
+// waiting for it to be written, the caller must hold the write lock
+func (r *Recurrence) save(store StateStore) {
+	r.version++
+
+	if _, err := writeState(store, stateKey(r.key), r.state(), r.logger); nil != err {
+		r.logger.Error("could not write state", "version", r.version, "error", err)
+	}
+}
+
+// Close -
+// This function writes every change still waiting to be persisted and stops
+// the persistence worker
+func (r *Recurrence) Close() {
+	r.persister.close()
+}
+
+// Flush -
+// This function closes the sequence, then writes its latest state to the
+// store synchronously, see Fibonacci.Flush
+func (r *Recurrence) Flush(ctx context.Context) error {
+	r.Close()
+
+	r.rwMutex.RLock()
+	st := r.state()
+	r.rwMutex.RUnlock()
+
+	return r.persister.write(ctx, stateKey(r.key), st)
+}
+
+// restart -
+// This function moves the sequence back to its seeds, leaving the epoch as it
+// is, the caller must hold the write lock
+func (r *Recurrence) restart() {
+	r.index = 0
+	r.previous = new(big.Int)
+	r.window = append([]*big.Int(nil), r.spec.Seeds...)
+	r.saturated = false
+}
+
+// following -
+// This function computes the term after the window,
+// c1*window[k-1] + c2*window[k-2] + ... + ck*window[0], reduced by the modulus
+func (r *Recurrence) following() *big.Int {
+	k := len(r.window)
+	sum, product := new(big.Int), new(big.Int)
+	for i, c := range r.spec.Coefficients {
+		product.Mul(big.NewInt(c), r.window[k-1-i])
+		sum.Add(sum, product)
+	}
+
+	return r.reduce(sum)
+}
+
+// reduce -
+// This function reduces a term mod the modulus of the sequence, if it has one
+func (r *Recurrence) reduce(v *big.Int) *big.Int {
+	if 0 == r.spec.Modulus {
+		return v
+	}
+
+	return new(big.Int).Mod(v, new(big.Int).SetUint64(r.spec.Modulus))
+}
+
+// The following helpers read the state without locking, the caller must hold
+// at least a read lock
+
+func (r *Recurrence) nextValue() *big.Int {
+	if 1 == len(r.window) {
+		return r.following()
+	}
+
+	return r.window[1]
+}
+
+func (r *Recurrence) currentTerm() Term {
+	return Term{Index: r.index, Value: r.number(r.window[0])}
+}
+
+func (r *Recurrence) number(v *big.Int) Number {
+	if ModeBig == r.mode {
+		return NewBigNumber(v)
+	}
+
+	return NewNumber(v.Uint64())
+}
+
+// fitsUint64 -
+// This function reports whether a term can be held in uint64 mode
+func fitsUint64(v *big.Int) bool {
+	return 0 <= v.Sign() && v.IsUint64()
+}
+
+// copySpec -
+// This function deep copies a spec so neither side can modify the other's
+func copySpec(spec RecurrenceSpec) RecurrenceSpec {
+	seeds := make([]*big.Int, len(spec.Seeds))
+	for i, seed := range spec.Seeds {
+		seeds[i] = new(big.Int).Set(seed)
+	}
+
+	return RecurrenceSpec{
+		Coefficients: append([]int64(nil), spec.Coefficients...),
+		Seeds:        seeds,
+		Modulus:      spec.Modulus,
+	}
+}
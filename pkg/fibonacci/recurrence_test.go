@@ -0,0 +1,372 @@
+package fibonacci
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// Specs beyond the presets, a(n) = 2a(n-1) reaches 2^64 at index 64 and
+// a(n) = a(n-1) - a(n-2) goes negative at index 4
+var (
+	doublingSpec    = newSpec([]int64{2}, 1)
+	alternatingSpec = newSpec([]int64{1, -1}, 0, 1)
+)
+
+// withModulus returns a copy of spec reduced mod m
+func withModulus(spec RecurrenceSpec, m uint64) RecurrenceSpec {
+	spec.Modulus = m
+	return spec
+}
+
+func newTestRecurrence(t *testing.T, spec RecurrenceSpec, mode Mode, policy OverflowPolicy) *Recurrence {
+	t.Helper()
+
+	r, err := newRecurrence(spec, mode, policy)
+	if nil != err {
+		t.Fatalf("newRecurrence() error = %v", err)
+	}
+	r.persister = newPersister(NewMemoryStore(), defaultQueueSize, nil)
+
+	return r
+}
+
+func TestPreset(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   RecurrenceSpec
+		wantOk bool
+	}{
+		{name: "fibonacci", want: FibonacciSpec, wantOk: true},
+		{name: " Lucas ", want: LucasSpec, wantOk: true},
+		{name: "pell", want: PellSpec, wantOk: true},
+		{name: "TRIBONACCI", want: TribonacciSpec, wantOk: true},
+		{name: "padovan", want: RecurrenceSpec{}, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Preset(tt.name)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preset() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRecurrenceSpec_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    RecurrenceSpec
+		wantErr bool
+	}{
+		{name: "preset", spec: TribonacciSpec, wantErr: false},
+		{name: "empty", spec: RecurrenceSpec{}, wantErr: true},
+		{name: "too few seeds", spec: newSpec([]int64{1, 1}, 0), wantErr: true},
+		{name: "too many seeds", spec: newSpec([]int64{1}, 0, 1), wantErr: true},
+		{name: "nil seed", spec: RecurrenceSpec{Coefficients: []int64{1}, Seeds: []*big.Int{nil}}, wantErr: true},
+		{name: "order too high", spec: newSpec(make([]int64, 17), make([]int64, 17)...), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if (nil != err) != tt.wantErr {
+				t.Fatalf("RecurrenceSpec.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if nil != err && !errors.Is(err, ErrInvalidRecurrence) {
+				t.Errorf("RecurrenceSpec.Validate() error = %v, want %v", err, ErrInvalidRecurrence)
+			}
+		})
+	}
+}
+
+func TestRecurrenceSpec_IsFibonacci(t *testing.T) {
+	if !FibonacciSpec.IsFibonacci() {
+		t.Errorf("FibonacciSpec.IsFibonacci() = false, want true")
+	}
+	for _, spec := range []RecurrenceSpec{LucasSpec, PellSpec, TribonacciSpec, doublingSpec} {
+		if spec.IsFibonacci() {
+			t.Errorf("RecurrenceSpec.IsFibonacci() of %v = true, want false", spec)
+		}
+	}
+}
+
+func Test_newRecurrence(t *testing.T) {
+	// A first seed outside uint64 can only be served in big mode
+	spec := newSpec([]int64{1}, -1)
+	if _, err := newRecurrence(spec, ModeUint64, OverflowReject); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("newRecurrence() error = %v, want %v", err, ErrInvalidRecurrence)
+	}
+	if _, err := newRecurrence(spec, ModeBig, OverflowReject); nil != err {
+		t.Errorf("newRecurrence() in big mode error = %v", err)
+	}
+
+	// Seeds are reduced by the modulus, even one that would not fit otherwise
+	if r, err := newRecurrence(withModulus(spec, 7), ModeUint64, OverflowReject); nil != err || NewNumber(6) != r.GetCurrent().Value {
+		t.Errorf("newRecurrence() with a modulus = %v, %v, want 6", r, err)
+	}
+
+	// The spec is copied, changing the caller's seeds does not change the sequence
+	seeds := newSpec([]int64{1, 1}, 2, 1)
+	r, err := newRecurrence(seeds, ModeUint64, OverflowReject)
+	if nil != err {
+		t.Fatalf("newRecurrence() error = %v", err)
+	}
+	seeds.Seeds[0].SetInt64(5)
+	if got := r.GetCurrent(); NewNumber(2) != got.Value {
+		t.Errorf("Recurrence.GetCurrent() = %v after changing the seeds, want 2", got)
+	}
+}
+
+func TestRecurrence_GetNextN(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      RecurrenceSpec
+		mode      Mode
+		policy    OverflowPolicy
+		k         int
+		wantFirst Term
+		wantLast  Term
+		wantEpoch uint64
+		// Steps that stayed on the last term
+		wantSaturated uint64
+		wantErr       error
+	}{
+		{
+			name:      "lucas",
+			spec:      LucasSpec,
+			k:         10,
+			wantFirst: Term{Index: 1, Value: NewNumber(1)},
+			wantLast:  Term{Index: 10, Value: NewNumber(123)},
+		},
+		{
+			name:      "pell",
+			spec:      PellSpec,
+			k:         10,
+			wantFirst: Term{Index: 1, Value: NewNumber(1)},
+			wantLast:  Term{Index: 10, Value: NewNumber(2378)},
+		},
+		{
+			name:      "tribonacci",
+			spec:      TribonacciSpec,
+			k:         10,
+			wantFirst: Term{Index: 1, Value: NewNumber(0)},
+			wantLast:  Term{Index: 10, Value: NewNumber(81)},
+		},
+		{
+			name:      "order one up to last uint64",
+			spec:      doublingSpec,
+			k:         63,
+			wantFirst: Term{Index: 1, Value: NewNumber(2)},
+			wantLast:  Term{Index: 63, Value: NewNumber(1 << 63)},
+		},
+		{
+			name:    "reject past last uint64",
+			spec:    doublingSpec,
+			k:       64,
+			wantErr: ErrOverflow,
+		},
+		{
+			name:      "wrap",
+			spec:      doublingSpec,
+			policy:    OverflowWrap,
+			k:         65,
+			wantFirst: Term{Index: 1, Value: NewNumber(2)},
+			wantLast:  Term{Index: 1, Value: NewNumber(2)},
+			wantEpoch: 1,
+		},
+		{
+			name:          "saturate",
+			spec:          doublingSpec,
+			policy:        OverflowSaturate,
+			k:             70,
+			wantFirst:     Term{Index: 1, Value: NewNumber(2)},
+			wantLast:      Term{Index: 63, Value: NewNumber(1 << 63)},
+			wantSaturated: 7,
+		},
+		{
+			name:      "big",
+			spec:      doublingSpec,
+			mode:      ModeBig,
+			k:         64,
+			wantFirst: Term{Index: 1, Value: NewBigNumber(big.NewInt(2))},
+			wantLast:  Term{Index: 64, Value: NewBigNumber(new(big.Int).Lsh(big.NewInt(1), 64))},
+		},
+		{
+			name:    "reject negative",
+			spec:    alternatingSpec,
+			k:       4,
+			wantErr: ErrOverflow,
+		},
+		{
+			name:      "fibonacci mod 10 over a Pisano period",
+			spec:      withModulus(FibonacciSpec, 10),
+			k:         61,
+			wantFirst: Term{Index: 1, Value: NewNumber(1)},
+			wantLast:  Term{Index: 61, Value: NewNumber(1)},
+		},
+		{
+			name:      "mod never overflows",
+			spec:      withModulus(doublingSpec, 1000),
+			k:         100,
+			wantFirst: Term{Index: 1, Value: NewNumber(2)},
+			wantLast:  Term{Index: 100, Value: NewNumber(376)},
+		},
+		{
+			name:      "mod reduces negative terms",
+			spec:      withModulus(alternatingSpec, 5),
+			k:         4,
+			wantFirst: Term{Index: 1, Value: NewNumber(1)},
+			wantLast:  Term{Index: 4, Value: NewNumber(4)},
+		},
+		{
+			name:      "big negative",
+			spec:      alternatingSpec,
+			mode:      ModeBig,
+			k:         4,
+			wantFirst: Term{Index: 1, Value: NewBigNumber(big.NewInt(1))},
+			wantLast:  Term{Index: 4, Value: NewBigNumber(big.NewInt(-1))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRecurrence(t, tt.spec, tt.mode, tt.policy)
+			defer r.Close()
+
+			got, err := r.GetNextN(tt.k)
+			if err != tt.wantErr {
+				t.Fatalf("Recurrence.GetNextN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if nil != err {
+				if 0 != r.GetCurrent().Index || 0 != r.version || (SequenceStats{}) != r.GetSequenceStats() {
+					t.Errorf("Recurrence.GetNextN() changed the sequence despite failing")
+				}
+				return
+			}
+
+			if tt.k != len(got) {
+				t.Fatalf("Recurrence.GetNextN() returned %v terms, want %v", len(got), tt.k)
+			}
+			if first := got[0]; !reflect.DeepEqual(first, tt.wantFirst) {
+				t.Errorf("Recurrence.GetNextN() first = %v, want %v", first, tt.wantFirst)
+			}
+			if last := got[len(got)-1]; !reflect.DeepEqual(last, tt.wantLast) {
+				t.Errorf("Recurrence.GetNextN() last = %v, want %v", last, tt.wantLast)
+			}
+			if epoch := r.GetEpoch(); epoch != tt.wantEpoch {
+				t.Errorf("Recurrence.GetEpoch() = %v, want %v", epoch, tt.wantEpoch)
+			}
+			if stats := r.GetSequenceStats(); stats.Saturated != tt.wantSaturated || stats.Advances != uint64(tt.k)-tt.wantSaturated {
+				t.Errorf("Recurrence.GetSequenceStats() = %+v, want %v saturated of %v", stats, tt.wantSaturated, tt.k)
+			}
+		})
+	}
+}
+
+func TestRecurrence_GetPrevious(t *testing.T) {
+	r := newTestRecurrence(t, LucasSpec, ModeUint64, OverflowReject)
+	defer r.Close()
+
+	if got, want := r.GetPrevious(), (Term{Index: 0, Value: NewNumber(0)}); got != want {
+		t.Errorf("Recurrence.GetPrevious() at the start = %v, want %v", got, want)
+	}
+
+	r.GetNextN(5)
+	if got, want := r.GetPrevious(), (Term{Index: 4, Value: NewNumber(7)}); got != want {
+		t.Errorf("Recurrence.GetPrevious() = %v, want %v", got, want)
+	}
+}
+
+func TestRecurrence_Reset(t *testing.T) {
+	r := newTestRecurrence(t, doublingSpec, ModeUint64, OverflowWrap)
+	defer r.Close()
+
+	r.GetNextN(70)
+	from, epoch := r.Reset()
+	if want := (Term{Index: 6, Value: NewNumber(64)}); from != want || 1 != epoch {
+		t.Errorf("Recurrence.Reset() = %v, %v, want %v, 1", from, epoch, want)
+	}
+	if got, want := r.GetCurrent(), (Term{Index: 0, Value: NewNumber(1)}); got != want || 0 != r.GetEpoch() {
+		t.Errorf("Recurrence after Reset() = %v in epoch %v, want %v in epoch 0", got, r.GetEpoch(), want)
+	}
+}
+
+func Test_decodeRecurrence(t *testing.T) {
+	r := newTestRecurrence(t, TribonacciSpec, ModeBig, OverflowReject)
+	defer r.Close()
+	r.GetNextN(10)
+
+	saved := r.state()
+	if want := "81"; saved.Current != want || "149" != saved.Next {
+		t.Errorf("Recurrence.state() = %+v, want current %v and next 149", saved, want)
+	}
+
+	restored, err := decodeRecurrence(saved, Options{Mode: ModeBig})
+	if nil != err {
+		t.Fatalf("decodeRecurrence() error = %v", err)
+	}
+	if got, want := restored.GetCurrent(), r.GetCurrent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Restored Recurrence.GetCurrent() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(restored.GetSpec(), TribonacciSpec) {
+		t.Errorf("Restored Recurrence.GetSpec() = %v, want %v", restored.GetSpec(), TribonacciSpec)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(st *State)
+		mode    Mode
+		wantErr error
+	}{
+		{
+			name:    "short window",
+			modify:  func(st *State) { st.Recurrence.Window = st.Recurrence.Window[1:] },
+			mode:    ModeBig,
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:    "not a number",
+			modify:  func(st *State) { st.Recurrence.Seeds = []string{"0", "x", "1"} },
+			mode:    ModeBig,
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:    "no coefficients",
+			modify:  func(st *State) { st.Recurrence.Coefficients = nil },
+			mode:    ModeBig,
+			wantErr: ErrCorruptState,
+		},
+		{
+			name: "not reduced",
+			modify: func(st *State) {
+				st.Recurrence.Modulus = 50
+				st.Recurrence.Window = []string{"24", "44", "81"}
+			},
+			mode:    ModeBig,
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:    "unsupported schema",
+			modify:  func(st *State) { st.Schema = 42 },
+			mode:    ModeBig,
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:    "too large for uint64",
+			modify:  func(st *State) { st.Recurrence.Window = []string{"18446744073709551616", "1", "1"} },
+			mode:    ModeUint64,
+			wantErr: ErrStateOutOfRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := r.state()
+			tt.modify(&st)
+
+			if _, err := decodeRecurrence(st, Options{Mode: tt.mode}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("decodeRecurrence() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
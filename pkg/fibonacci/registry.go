@@ -0,0 +1,404 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+)
+
+// Prefix of the keys named sequences are persisted under
+const sequenceKeyPrefix = "sequence:"
+
+var (
+	// ErrInvalidName -
+	// Returned by Registry for a name that is not 1 to 64 letters, digits,
+	// dashes or underscores
+	ErrInvalidName = errors.New("sequence names must be 1 to 64 letters, digits, dashes or underscores")
+
+	// ErrSequenceExists -
+	// Returned by Registry.Create for a name already in use
+	ErrSequenceExists = errors.New("sequence already exists")
+
+	// ErrSequenceNotFound -
+	// Returned by Registry for a name that has never been created
+	ErrSequenceNotFound = errors.New("sequence does not exist")
+
+	// ErrTooManySequences -
+	// Returned by Registry once its limit of sequences is reached
+	ErrTooManySequences = errors.New("too many sequences")
+)
+
+var sequenceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Registry -
+// Independent named sequences sharing a state store, each either the Fibonacci
+// numbers or another linear recurrence. Every sequence has its own lock and
+// persistence worker and is persisted under its own key.
+// Sequences are restored from the store the first time they are used. The
+// limit counts the sequences persisted in the store, so it holds across
+// restarts and only refuses creating a sequence, never restoring one.
+// The store is never accessed under the lock of the Registry, so a slow store
+// only holds up the requests for the sequence being created, restored or
+// deleted.
+type Registry struct {
+	store StateStore
+	opts  Options
+	limit int
+
+	mutex     sync.RWMutex
+	sequences map[string]*registryEntry
+}
+
+// registryEntry -
+// A sequence of a Registry, or the placeholder of one being created, restored
+// or deleted. ready is closed once seq, or err when there is no sequence, is
+// set, placeholders left without a sequence are then removed. creating marks
+// the entries of sequences created by this Registry, so they count towards the
+// limit before they are persisted.
+type registryEntry struct {
+	ready    chan struct{}
+	seq      Sequence
+	err      error
+	creating bool
+}
+
+// NewRegistry -
+// This function creates a Registry holding at most limit sequences, all in
+// the mode and with the overflow policy of opts
+func NewRegistry(store StateStore, opts Options, limit int) *Registry {
+	return &Registry{
+		store:     store,
+		opts:      opts,
+		limit:     limit,
+		sequences: map[string]*registryEntry{},
+	}
+}
+
+// lookup -
+// This method returns the entry under name, once it is no longer a
+// placeholder. Entries removed while waiting are looked up again, so nil is
+// only returned when there is no entry.
+func (r *Registry) lookup(name string) *registryEntry {
+	for {
+		r.mutex.RLock()
+		e, ok := r.sequences[name]
+		r.mutex.RUnlock()
+		if !ok {
+			return nil
+		}
+
+		<-e.ready
+		if nil == e.err {
+			return e
+		}
+	}
+}
+
+// reserve -
+// This method adds a placeholder under name, failing when there is already an
+// entry under it
+func (r *Registry) reserve(name string, creating bool) (*registryEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.sequences[name]; ok {
+		return nil, ErrSequenceExists
+	}
+
+	e := &registryEntry{ready: make(chan struct{}), creating: creating}
+	r.sequences[name] = e
+	return e, nil
+}
+
+// settle -
+// This method completes a placeholder with the sequence, or removes it along
+// with the error when there is none, and wakes those waiting on it
+func (r *Registry) settle(name string, e *registryEntry, seq Sequence, err error) {
+	r.mutex.Lock()
+	if nil == seq {
+		delete(r.sequences, name)
+		if nil == err {
+			err = ErrSequenceNotFound
+		}
+	}
+	e.seq, e.err = seq, err
+	r.mutex.Unlock()
+
+	close(e.ready)
+}
+
+// Create -
+// This function starts a new sequence under name and persists it. A spec
+// without coefficients or seeds stands for the Fibonacci numbers. Without a
+// modulus they are a Fibonacci sequence at F(0), any other spec creates a
+// Recurrence at its seeds.
+func (r *Registry) Create(name string, spec RecurrenceSpec) (Sequence, error) {
+	if !sequenceNamePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	if 0 == spec.Order() && 0 == len(spec.Seeds) {
+		spec.Coefficients, spec.Seeds = FibonacciSpec.Coefficients, FibonacciSpec.Seeds
+	}
+
+	var rec *Recurrence
+	if !spec.IsFibonacci() {
+		var err error
+		if rec, err = newRecurrence(spec, r.opts.Mode, r.opts.OverflowPolicy); nil != err {
+			return nil, err
+		}
+	}
+
+	e, err := r.reserveNew(name)
+	if nil != err {
+		return nil, err
+	}
+
+	seq, err := r.create(sequenceKeyPrefix+name, rec)
+	r.settle(name, e, seq, err)
+	return seq, err
+}
+
+// reserveNew -
+// This method adds the placeholder of a sequence about to be created, waiting
+// for the one under name, if any, to settle first
+func (r *Registry) reserveNew(name string) (*registryEntry, error) {
+	for {
+		e, err := r.reserve(name, true)
+		if ErrSequenceExists != err {
+			return e, err
+		}
+		if nil != r.lookup(name) {
+			return nil, ErrSequenceExists
+		}
+	}
+}
+
+// create -
+// This method persists a new sequence under key, the Fibonacci numbers when
+// rec is nil, and starts its persistence worker
+func (r *Registry) create(key string, rec *Recurrence) (Sequence, error) {
+	_, err := r.store.Load(context.Background(), key)
+	switch {
+	case nil == err:
+		return nil, ErrSequenceExists
+	case !errors.Is(err, ErrStateNotFound):
+		return nil, err
+	}
+
+	if err := r.checkLimit(key); nil != err {
+		return nil, err
+	}
+
+	if nil == rec {
+		fib := newFibonacci(r.opts.Mode, r.opts.OverflowPolicy)
+		fib.key = key
+		fib.logger = sequenceLogger(r.opts.Logger, key)
+		fib.save(r.store)
+		fib.persister = newPersister(r.store, defaultQueueSize, persisterLogger(r.opts.Logger, key))
+		return fib, nil
+	}
+
+	rec.key = key
+	rec.logger = sequenceLogger(r.opts.Logger, key)
+	rec.save(r.store)
+	rec.persister = newPersister(r.store, defaultQueueSize, persisterLogger(r.opts.Logger, key))
+	return rec, nil
+}
+
+// checkLimit -
+// This method fails with ErrTooManySequences when the sequences persisted in
+// the store, along with those still being created, leave no room for the one
+// under key. The pending creations are read before the store is listed, so a
+// creation settling in between is still counted.
+func (r *Registry) checkLimit(key string) error {
+	keys := map[string]bool{}
+	r.mutex.RLock()
+	for name, e := range r.sequences {
+		if e.creating {
+			keys[sequenceKeyPrefix+name] = true
+		}
+	}
+	r.mutex.RUnlock()
+
+	stored, err := r.store.Keys(context.Background(), sequenceKeyPrefix)
+	if nil != err {
+		return err
+	}
+	for _, k := range stored {
+		keys[k] = true
+	}
+
+	delete(keys, key)
+	if len(keys) >= r.limit {
+		return ErrTooManySequences
+	}
+
+	return nil
+}
+
+// Get -
+// This function returns the sequence under name, restoring it from the store
+// if it has not been used since the Registry started
+func (r *Registry) Get(name string) (Sequence, error) {
+	if !sequenceNamePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	for {
+		if e := r.lookup(name); nil != e {
+			return e.seq, nil
+		}
+
+		e, err := r.reserve(name, false)
+		if ErrSequenceExists == err {
+			// Created or restored by another request meanwhile
+			continue
+		}
+		if nil != err {
+			return nil, err
+		}
+
+		opts := r.opts
+		opts.Key = sequenceKeyPrefix + name
+		seq, err := r.restore(opts)
+		if errors.Is(err, ErrStateNotFound) {
+			err = ErrSequenceNotFound
+		}
+		r.settle(name, e, seq, err)
+		return seq, err
+	}
+}
+
+// restore -
+// This function restores the sequence persisted under opts.Key and starts its
+// persistence worker
+func (r *Registry) restore(opts Options) (Sequence, error) {
+	st, err := r.store.Load(context.Background(), opts.Key)
+	if nil != err {
+		return nil, err
+	}
+
+	if nil != st.Recurrence {
+		rec, err := decodeRecurrence(st, opts)
+		if nil != err {
+			return nil, err
+		}
+		rec.persister = newPersister(r.store, defaultQueueSize, persisterLogger(opts.Logger, opts.Key))
+		return rec, nil
+	}
+
+	fib, err := fibonacciFromState(r.store, st, opts)
+	if nil != err {
+		return nil, err
+	}
+	fib.persister = newPersister(r.store, defaultQueueSize, persisterLogger(opts.Logger, opts.Key))
+	return fib, nil
+}
+
+// Delete -
+// This function stops the sequence under name, once its pending changes are
+// written, and removes it from the store
+func (r *Registry) Delete(name string) error {
+	if !sequenceNamePattern.MatchString(name) {
+		return ErrInvalidName
+	}
+
+	// The sequence, if loaded, is swapped for a placeholder so it is not
+	// restored again while it is being deleted
+	var seq Sequence
+	var e *registryEntry
+	for nil == e {
+		if loaded := r.lookup(name); nil != loaded {
+			r.mutex.Lock()
+			if loaded == r.sequences[name] {
+				seq = loaded.seq
+				e = &registryEntry{ready: make(chan struct{})}
+				r.sequences[name] = e
+			}
+			r.mutex.Unlock()
+			continue
+		}
+
+		var err error
+		if e, err = r.reserve(name, false); nil != err && ErrSequenceExists != err {
+			return err
+		}
+	}
+
+	err := r.remove(sequenceKeyPrefix+name, seq)
+	r.settle(name, e, nil, nil)
+	return err
+}
+
+// remove -
+// This method stops seq, when it is loaded, and removes the state under key
+// from the store
+func (r *Registry) remove(key string, seq Sequence) error {
+	if nil != seq {
+		seq.Close()
+	} else if _, err := r.store.Load(context.Background(), key); errors.Is(err, ErrStateNotFound) {
+		return ErrSequenceNotFound
+	}
+
+	return r.store.Delete(context.Background(), key)
+}
+
+// loaded -
+// This method returns the sequences that are loaded, leaving out placeholders
+func (r *Registry) loaded() map[string]Sequence {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sequences := map[string]Sequence{}
+	for name, e := range r.sequences {
+		select {
+		case <-e.ready:
+			if nil != e.seq {
+				sequences[name] = e.seq
+			}
+		default:
+		}
+	}
+
+	return sequences
+}
+
+// Stats -
+// This function reports the stats of every sequence loaded since the Registry
+// started, by name
+func (r *Registry) Stats() map[string]SequenceStats {
+	stats := map[string]SequenceStats{}
+	for name, seq := range r.loaded() {
+		stats[name] = seq.GetSequenceStats()
+	}
+
+	return stats
+}
+
+// Close -
+// This function writes every change still waiting to be persisted and stops
+// the persistence workers of all sequences
+func (r *Registry) Close() {
+	for _, seq := range r.loaded() {
+		seq.Close()
+	}
+}
+
+// Flush -
+// This function flushes every sequence, see Fibonacci.Flush, returning the
+// first error
+func (r *Registry) Flush(ctx context.Context) error {
+	var first error
+	for name, seq := range r.loaded() {
+		if err := seq.Flush(ctx); nil != err {
+			r.opts.Logger.Component("registry").Error("could not flush sequence", "sequence", name, "error", err)
+			if nil == first {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,277 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRegistry(store, Options{}, 2)
+
+	alpha, err := r.Create("alpha", RecurrenceSpec{})
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	beta, err := r.Create("beta", RecurrenceSpec{})
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+
+	for i := 0; i < 12; i++ {
+		alpha.GetNext()
+	}
+	beta.GetNext()
+
+	// Sequences are independent of each other and of the default sequence
+	if got := alpha.GetCurrent(); 12 != got.Index {
+		t.Errorf("alpha is on index %v, want 12", got.Index)
+	}
+	if got := beta.GetCurrent(); 1 != got.Index {
+		t.Errorf("beta is on index %v, want 1", got.Index)
+	}
+	if _, err := store.Load(context.Background(), DefaultStateKey); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Named sequences wrote to the default key, Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name:    "invalid name",
+			call:    func() error { _, err := r.Create("no spaces", RecurrenceSpec{}); return err },
+			wantErr: ErrInvalidName,
+		},
+		{
+			name:    "duplicate",
+			call:    func() error { _, err := r.Create("alpha", RecurrenceSpec{}); return err },
+			wantErr: ErrSequenceExists,
+		},
+		{
+			name:    "over limit",
+			call:    func() error { _, err := r.Create("gamma", RecurrenceSpec{}); return err },
+			wantErr: ErrTooManySequences,
+		},
+		{
+			name:    "get",
+			call:    func() error { _, err := r.Get("alpha"); return err },
+			wantErr: nil,
+		},
+		{
+			name:    "delete missing",
+			call:    func() error { return r.Delete("gamma") },
+			wantErr: ErrSequenceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != tt.wantErr {
+				t.Errorf("Registry error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := r.Delete("beta"); nil != err {
+		t.Fatalf("Registry.Delete() error = %v", err)
+	}
+	if _, err := r.Get("beta"); ErrSequenceNotFound != err {
+		t.Errorf("Registry.Get() of a deleted sequence error = %v, want %v", err, ErrSequenceNotFound)
+	}
+	if _, err := store.Load(context.Background(), sequenceKeyPrefix+"beta"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Deleted sequence is still stored, Load() error = %v", err)
+	}
+	r.Close()
+
+	// A new Registry restores sequences lazily from the store
+	restarted := NewRegistry(store, Options{}, 2)
+	defer restarted.Close()
+
+	restored, err := restarted.Get("alpha")
+	if nil != err {
+		t.Fatalf("Registry.Get() after restart error = %v", err)
+	}
+	if got := restored.GetCurrent(); 12 != got.Index || NewNumber(144) != got.Value {
+		t.Errorf("Restored alpha = %v, want F(12)", got)
+	}
+	if _, err := restarted.Create("alpha", RecurrenceSpec{}); ErrSequenceExists != err {
+		t.Errorf("Registry.Create() of a persisted sequence error = %v, want %v", err, ErrSequenceExists)
+	}
+}
+
+func TestRegistry_limitAcrossRestarts(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRegistry(store, Options{}, 2)
+	for _, name := range []string{"a", "b"} {
+		if _, err := r.Create(name, RecurrenceSpec{}); nil != err {
+			t.Fatalf("Registry.Create() error = %v", err)
+		}
+	}
+	r.Close()
+
+	// The limit counts the persisted sequences, not those used since starting
+	restarted := NewRegistry(store, Options{}, 2)
+	defer restarted.Close()
+
+	if _, err := restarted.Create("c", RecurrenceSpec{}); ErrTooManySequences != err {
+		t.Errorf("Registry.Create() after restart error = %v, want %v", err, ErrTooManySequences)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := restarted.Get(name); nil != err {
+			t.Errorf("Registry.Get() of persisted %v error = %v", name, err)
+		}
+	}
+
+	if err := restarted.Delete("a"); nil != err {
+		t.Fatalf("Registry.Delete() error = %v", err)
+	}
+	if _, err := restarted.Create("c", RecurrenceSpec{}); nil != err {
+		t.Errorf("Registry.Create() after a deletion error = %v", err)
+	}
+}
+
+func TestRegistry_recurrence(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRegistry(store, Options{}, 4)
+
+	lucas, err := r.Create("lucas", LucasSpec)
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	if _, ok := lucas.(*Recurrence); !ok {
+		t.Errorf("Registry.Create() of the Lucas numbers = %T, want *Recurrence", lucas)
+	}
+	lucas.GetNextN(10)
+
+	// The Fibonacci preset is served by the dedicated type
+	fib, err := r.Create("fib", FibonacciSpec)
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	if _, ok := fib.(*Fibonacci); !ok {
+		t.Errorf("Registry.Create() of the Fibonacci numbers = %T, want *Fibonacci", fib)
+	}
+
+	// A modulus alone stands for the Fibonacci numbers mod m
+	mod, err := r.Create("mod", RecurrenceSpec{Modulus: 10})
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	if got, _ := mod.GetNextN(15); NewNumber(0) != got[14].Value {
+		t.Errorf("F(15) mod 10 = %v, want 0", got[14].Value)
+	}
+
+	if _, err := r.Create("broken", newSpec([]int64{1, 1}, 0)); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("Registry.Create() of an invalid spec error = %v, want %v", err, ErrInvalidRecurrence)
+	}
+	if _, err := r.Get("broken"); ErrSequenceNotFound != err {
+		t.Errorf("Registry.Get() of an invalid spec error = %v, want %v", err, ErrSequenceNotFound)
+	}
+	r.Close()
+
+	restarted := NewRegistry(store, Options{}, 4)
+	defer restarted.Close()
+
+	restored, err := restarted.Get("lucas")
+	if nil != err {
+		t.Fatalf("Registry.Get() after restart error = %v", err)
+	}
+	if got, want := restored.GetCurrent(), (Term{Index: 10, Value: NewNumber(123)}); got != want {
+		t.Errorf("Restored lucas = %v, want %v", got, want)
+	}
+	if next, _ := restored.GetNext(); NewNumber(199) != next.Value {
+		t.Errorf("Restored lucas stepped to %v, want 199", next)
+	}
+}
+
+func TestRegistry_Flush(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRegistry(store, Options{}, 4)
+
+	for name, spec := range map[string]RecurrenceSpec{"fib": FibonacciSpec, "lucas": LucasSpec} {
+		seq, err := r.Create(name, spec)
+		if nil != err {
+			t.Fatalf("Registry.Create() error = %v", err)
+		}
+		seq.GetNextN(5)
+	}
+
+	if err := r.Flush(context.Background()); nil != err {
+		t.Fatalf("Registry.Flush() error = %v", err)
+	}
+
+	for _, name := range []string{"fib", "lucas"} {
+		st, err := store.Load(context.Background(), sequenceKeyPrefix+name)
+		if nil != err {
+			t.Fatalf("Load() of %v error = %v", name, err)
+		}
+		if 5 != st.Index {
+			t.Errorf("Flushed index of %v = %v, want 5", name, st.Index)
+		}
+	}
+}
+
+// blockingStore -
+// A MemoryStore whose loads of one key wait until release is closed
+type blockingStore struct {
+	*MemoryStore
+
+	key     string
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (bs *blockingStore) Load(ctx context.Context, key string) (State, error) {
+	if bs.key == key {
+		close(bs.loading)
+		<-bs.release
+	}
+
+	return bs.MemoryStore.Load(ctx, key)
+}
+
+func TestRegistry_slowStore(t *testing.T) {
+	store := &blockingStore{
+		MemoryStore: NewMemoryStore(),
+		key:         sequenceKeyPrefix + "slow",
+		loading:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	r := NewRegistry(store, Options{}, 4)
+
+	if _, err := r.Create("fast", FibonacciSpec); nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+
+	created := make(chan error)
+	go func() {
+		_, err := r.Create("slow", FibonacciSpec)
+		created <- err
+	}()
+	<-store.loading
+
+	got := make(chan error)
+	go func() {
+		_, err := r.Get("fast")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if nil != err {
+			t.Errorf("Registry.Get() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Registry.Get() blocked behind the load of another sequence")
+	}
+
+	close(store.release)
+	if err := <-created; nil != err {
+		t.Errorf("Registry.Create() error = %v", err)
+	}
+	if _, err := r.Get("slow"); nil != err {
+		t.Errorf("Registry.Get() error = %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Highest order of recurrence a sequence may be defined with
+const maxRecurrenceOrder = 16
+
+// ErrInvalidRecurrence -
+// Returned for a recurrence whose coefficients and seeds do not define a
+// sequence
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// Sequence -
+// A sequence that can be stepped through and persisted, implemented by
+// Fibonacci for the Fibonacci numbers and by Recurrence for any other linear
+// recurrence
+type Sequence interface {
+	GetCurrent() Term
+	GetNext() (Term, error)
+	GetNextN(k int) ([]Term, error)
+	GetPrevious() Term
+	GetOverflowPolicy() OverflowPolicy
+	GetEpoch() uint64
+	GetSequenceStats() SequenceStats
+	Close()
+	Flush(ctx context.Context) error
+}
+
+// RecurrenceSpec -
+// Defines the order-k linear recurrence
+//
+//	a(n) = c1*a(n-1) + c2*a(n-2) + ... + ck*a(n-k)
+//
+// by its coefficients c1..ck and its seeds a(0)..a(k-1). A non-zero Modulus
+// reduces every term mod m, into [0, m), so the sequence never overflows.
+type RecurrenceSpec struct {
+	Coefficients []int64
+	Seeds        []*big.Int
+	Modulus      uint64
+}
+
+var (
+	// FibonacciSpec defines F(n) = F(n-1) + F(n-2) from 0, 1
+	FibonacciSpec = newSpec([]int64{1, 1}, 0, 1)
+	// LucasSpec defines L(n) = L(n-1) + L(n-2) from 2, 1
+	LucasSpec = newSpec([]int64{1, 1}, 2, 1)
+	// PellSpec defines P(n) = 2P(n-1) + P(n-2) from 0, 1
+	PellSpec = newSpec([]int64{2, 1}, 0, 1)
+	// TribonacciSpec defines T(n) = T(n-1) + T(n-2) + T(n-3) from 0, 0, 1
+	TribonacciSpec = newSpec([]int64{1, 1, 1}, 0, 0, 1)
+)
+
+func newSpec(coefficients []int64, seeds ...int64) RecurrenceSpec {
+	spec := RecurrenceSpec{Coefficients: coefficients}
+	for _, seed := range seeds {
+		spec.Seeds = append(spec.Seeds, big.NewInt(seed))
+	}
+
+	return spec
+}
+
+// Preset -
+// This function returns the spec of a named preset: "fibonacci", "lucas",
+// "pell" or "tribonacci"
+func Preset(name string) (RecurrenceSpec, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "fibonacci":
+		return FibonacciSpec, true
+	case "lucas":
+		return LucasSpec, true
+	case "pell":
+		return PellSpec, true
+	case "tribonacci":
+		return TribonacciSpec, true
+	}
+
+	return RecurrenceSpec{}, false
+}
+
+// Order -
+// This method returns k, the number of previous terms each term depends on
+func (rs RecurrenceSpec) Order() int {
+	return len(rs.Coefficients)
+}
+
+// Validate -
+// This method checks that the spec defines a sequence, with one seed per
+// coefficient and an order between 1 and 16
+func (rs RecurrenceSpec) Validate() error {
+	if 0 == rs.Order() || maxRecurrenceOrder < rs.Order() {
+		return fmt.Errorf("%w: order must be between 1 and %v", ErrInvalidRecurrence, maxRecurrenceOrder)
+	}
+	if len(rs.Seeds) != rs.Order() {
+		return fmt.Errorf("%w: %v coefficients need as many seeds, got %v", ErrInvalidRecurrence, rs.Order(), len(rs.Seeds))
+	}
+	for _, seed := range rs.Seeds {
+		if nil == seed {
+			return fmt.Errorf("%w: missing seed", ErrInvalidRecurrence)
+		}
+	}
+
+	return nil
+}
+
+// IsFibonacci -
+// This method reports whether the spec defines the Fibonacci numbers without a
+// modulus, which are served by the dedicated Fibonacci type
+func (rs RecurrenceSpec) IsFibonacci() bool {
+	if 2 != rs.Order() || 2 != len(rs.Seeds) || 0 != rs.Modulus {
+		return false
+	}
+
+	return 1 == rs.Coefficients[0] && 1 == rs.Coefficients[1] &&
+		0 == rs.Seeds[0].Sign() && 0 == rs.Seeds[1].Cmp(big.NewInt(1))
+}
@@ -0,0 +1,331 @@
+package fibonacci
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+const (
+	// Current version of the State schema, bumped on incompatible changes
+	stateSchemaVersion = 1
+
+	// Schema of a state saved by earlier versions, holding only Current
+	legacySchemaVersion = 0
+
+	// Upper bound on the index trusted when rebuilding a corrupt big mode
+	// state from its index alone
+	maxRepairIndex = 1 << 22
+)
+
+var (
+	// ErrCorruptState -
+	// Returned when the persisted state is unreadable or does not describe
+	// consecutive Fibonacci terms and cannot be repaired
+	ErrCorruptState = errors.New("persisted sequence state is corrupt")
+
+	// ErrStateOutOfRange -
+	// Returned when the persisted state is valid but too large for the mode
+	// the sequence is being restored in
+	ErrStateOutOfRange = errors.New("persisted sequence state does not fit in uint64 mode")
+)
+
+// State -
+// The persisted form of a sequence. The numbers are decimal strings so both
+// modes share the same schema and no JSON decoder rounds them.
+type State struct {
+	Schema int `json:"schema"`
+	// Incremented on every change, redis only accepts a state newer than the
+	// one it holds
+	Version  uint64 `json:"version"`
+	Index    uint64 `json:"index"`
+	Epoch    uint64 `json:"epoch"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Next     string `json:"next"`
+	// Only saved by a Recurrence, a Fibonacci sequence leaves it out
+	Recurrence *RecurrenceState `json:"recurrence,omitempty"`
+}
+
+// RecurrenceState -
+// The persisted definition and position of a Recurrence. Window holds the k
+// terms from the current one onwards, the numbers are decimal strings.
+type RecurrenceState struct {
+	Coefficients []int64  `json:"coefficients"`
+	Seeds        []string `json:"seeds"`
+	Window       []string `json:"window"`
+	Modulus      uint64   `json:"modulus,omitempty"`
+}
+
+// state -
+// This function captures the state of the sequence for persisting, the caller
+// must hold at least a read lock
+func (f *Fibonacci) state() State {
+	next := f.nextNumber().String()
+	if ModeUint64 == f.mode && f.nextOverflowed {
+		next = new(big.Int).Add(
+			new(big.Int).SetUint64(f.current), new(big.Int).SetUint64(f.previous),
+		).String()
+	}
+
+	return State{
+		Schema:   stateSchemaVersion,
+		Version:  f.version,
+		Index:    f.index,
+		Epoch:    f.epoch,
+		Previous: f.previousNumber().String(),
+		Current:  f.currentNumber().String(),
+		Next:     next,
+	}
+}
+
+// decodeState -
+// This function rebuilds the sequence from a persisted state, repairing it
+// when possible. The returned bool reports whether a repair or migration was
+// needed, in which case the state should be persisted again.
+func decodeState(st State, opts Options) (*Fibonacci, bool, error) {
+	if legacySchemaVersion == st.Schema {
+		f, err := decodeLegacyState(st.Current, opts)
+		return f, nil == err, err
+	}
+
+	f, repaired, err := decodeStateTerms(st, opts)
+	if nil != f {
+		f.version = st.Version
+	}
+
+	return f, repaired, err
+}
+
+// decodeStateTerms -
+// This function does the work of decodeState for the current schema
+func decodeStateTerms(st State, opts Options) (*Fibonacci, bool, error) {
+	if stateSchemaVersion != st.Schema {
+		return nil, false, fmt.Errorf(
+			"%w: unsupported schema version %d", ErrCorruptState, st.Schema,
+		)
+	}
+
+	previous, okPrev := parseTerm(st.Previous)
+	current, okCur := parseTerm(st.Current)
+	next, okNext := parseTerm(st.Next)
+
+	if okPrev && okCur && okNext && validTerms(st.Index, previous, current, next) {
+		f, err := fromTerms(opts, st.Index, st.Epoch, previous, current, next)
+		return f, false, err
+	}
+
+	// The values may still agree with each other with only the index damaged
+	if okPrev && okCur && okNext {
+		for _, n := range candidateIndexes(current) {
+			if validTerms(n, previous, current, next) {
+				sequenceLogger(opts.Logger, opts.Key).Warn("repairing persisted state", "index", st.Index, "repaired_index", n)
+				f, err := fromTerms(opts, n, st.Epoch, previous, current, next)
+				return f, true, err
+			}
+		}
+	}
+
+	// Otherwise fall back to trusting the index and rebuilding every value
+	limit := uint64(maxRepairIndex)
+	if ModeUint64 == opts.Mode {
+		limit = maxUint64Index
+	}
+	if st.Index <= limit {
+		sequenceLogger(opts.Logger, opts.Key).Warn("repairing persisted state, rebuilding values", "index", st.Index)
+		current, next := bigPair(st.Index)
+		previous := new(big.Int).Sub(next, current)
+		if 0 == st.Index {
+			previous.SetInt64(0)
+		}
+
+		f, err := fromTerms(opts, st.Index, st.Epoch, previous, current, next)
+		return f, true, err
+	}
+
+	return nil, false, fmt.Errorf(
+		"%w: values do not form consecutive Fibonacci terms and index %d is out of range",
+		ErrCorruptState, st.Index,
+	)
+}
+
+// decodeLegacyState -
+// This function migrates the state saved before the State schema existed,
+// which held only the "current" value
+func decodeLegacyState(raw string, opts Options) (*Fibonacci, error) {
+	current, ok := parseTerm(raw)
+	if !ok {
+		return nil, fmt.Errorf("%w: legacy value %q is not a number", ErrCorruptState, raw)
+	}
+
+	index, ok := fibIndex(current)
+	if !ok {
+		return nil, fmt.Errorf("%w: legacy value %v is not a Fibonacci number", ErrCorruptState, current)
+	}
+
+	_, next := bigPair(index)
+	previous := new(big.Int).Sub(next, current)
+	if 0 == index {
+		previous.SetInt64(0)
+	}
+
+	return fromTerms(opts, index, 0, previous, current, next)
+}
+
+// candidateIndexes -
+// This function lists the indexes a value could be at, which is two for 1
+func candidateIndexes(current *big.Int) []uint64 {
+	index, ok := fibIndex(current)
+	if !ok {
+		return nil
+	}
+
+	if 2 == index {
+		return []uint64{2, 1}
+	}
+
+	return []uint64{index}
+}
+
+// parseTerm -
+// This function parses a non-negative decimal value of the sequence
+func parseTerm(s string) (*big.Int, bool) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || 0 > v.Sign() {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// fromTerms -
+// This function builds a sequence from terms already known to be valid
+func fromTerms(
+	opts Options, index, epoch uint64, previous, current, next *big.Int,
+) (*Fibonacci, error) {
+	f := &Fibonacci{
+		mode:    opts.Mode,
+		policy:  opts.OverflowPolicy,
+		index:   index,
+		epoch:   epoch,
+		rwMutex: &sync.RWMutex{},
+	}
+
+	if ModeBig == opts.Mode {
+		f.bigPrevious, f.bigCurrent, f.bigNext = previous, current, next
+		return f, nil
+	}
+
+	if !current.IsUint64() {
+		return nil, fmt.Errorf("%w: current value %v at index %d", ErrStateOutOfRange, current, index)
+	}
+
+	f.previous = previous.Uint64()
+	f.current = current.Uint64()
+	f.next = next.Uint64()
+	f.nextOverflowed = !next.IsUint64()
+
+	return f, nil
+}
+
+// state -
+// This function captures the state of the recurrence for persisting, the
+// caller must hold at least a read lock
+func (r *Recurrence) state() State {
+	rec := &RecurrenceState{
+		Coefficients: append([]int64(nil), r.spec.Coefficients...),
+		Seeds:        make([]string, 0, len(r.spec.Seeds)),
+		Window:       make([]string, 0, len(r.window)),
+		Modulus:      r.spec.Modulus,
+	}
+	for _, seed := range r.spec.Seeds {
+		rec.Seeds = append(rec.Seeds, seed.String())
+	}
+	for _, term := range r.window {
+		rec.Window = append(rec.Window, term.String())
+	}
+
+	return State{
+		Schema:     stateSchemaVersion,
+		Version:    r.version,
+		Index:      r.index,
+		Epoch:      r.epoch,
+		Previous:   r.previous.String(),
+		Current:    r.window[0].String(),
+		Next:       r.nextValue().String(),
+		Recurrence: rec,
+	}
+}
+
+// decodeRecurrence -
+// This function rebuilds a recurrence from a persisted state. Unlike the
+// Fibonacci numbers a recurrence cannot be repaired, a state that is
+// unreadable is refused with ErrCorruptState.
+func decodeRecurrence(st State, opts Options) (*Recurrence, error) {
+	if stateSchemaVersion != st.Schema {
+		return nil, fmt.Errorf(
+			"%w: unsupported schema version %d", ErrCorruptState, st.Schema,
+		)
+	}
+
+	rec := st.Recurrence
+	spec := RecurrenceSpec{Coefficients: rec.Coefficients, Modulus: rec.Modulus}
+	seeds, okSeeds := parseSignedTerms(rec.Seeds)
+	window, okWindow := parseSignedTerms(rec.Window)
+	previous, okPrev := parseSignedTerms([]string{st.Previous})
+	if !okSeeds || !okWindow || !okPrev {
+		return nil, fmt.Errorf("%w: recurrence terms are not numbers", ErrCorruptState)
+	}
+	spec.Seeds = seeds
+
+	if err := spec.Validate(); nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptState, err)
+	}
+	if len(window) != spec.Order() {
+		return nil, fmt.Errorf(
+			"%w: recurrence of order %d has %d terms", ErrCorruptState, spec.Order(), len(window),
+		)
+	}
+	if 0 != spec.Modulus {
+		m := new(big.Int).SetUint64(spec.Modulus)
+		for _, term := range append([]*big.Int{previous[0]}, window...) {
+			if 0 > term.Sign() || 0 <= term.Cmp(m) {
+				return nil, fmt.Errorf("%w: term %v is not reduced mod %d", ErrCorruptState, term, spec.Modulus)
+			}
+		}
+	}
+	if ModeUint64 == opts.Mode && !fitsUint64(window[0]) {
+		return nil, fmt.Errorf("%w: current value %v at index %d", ErrStateOutOfRange, window[0], st.Index)
+	}
+
+	return &Recurrence{
+		mode:     opts.Mode,
+		policy:   opts.OverflowPolicy,
+		spec:     spec,
+		index:    st.Index,
+		epoch:    st.Epoch,
+		previous: previous[0],
+		window:   window,
+		key:      opts.Key,
+		version:  st.Version,
+		logger:   sequenceLogger(opts.Logger, opts.Key),
+		rwMutex:  &sync.RWMutex{},
+	}, nil
+}
+
+// parseSignedTerms -
+// This function parses decimal terms of a recurrence, which unlike the
+// Fibonacci numbers may be negative
+func parseSignedTerms(raw []string) ([]*big.Int, bool) {
+	terms := make([]*big.Int, 0, len(raw))
+	for _, s := range raw {
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, false
+		}
+		terms = append(terms, v)
+	}
+
+	return terms, true
+}
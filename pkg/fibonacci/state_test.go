@@ -0,0 +1,216 @@
+package fibonacci
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func encodeTestState(t *testing.T, st State) string {
+	t.Helper()
+
+	payload, err := json.Marshal(st)
+	if nil != err {
+		t.Fatalf("Failed to encode state: %v", err)
+	}
+
+	return string(payload)
+}
+
+func TestFibonacci_state(t *testing.T) {
+	f := newFibonacci(ModeUint64, OverflowReject)
+	for i := 0; i < 12; i++ {
+		f.advance()
+	}
+
+	want := State{
+		Schema:   stateSchemaVersion,
+		Index:    12,
+		Previous: "89",
+		Current:  "144",
+		Next:     "233",
+	}
+	if got := f.state(); got != want {
+		t.Errorf("Fibonacci.state() = %+v, want %+v", got, want)
+	}
+
+	// The next value is saved exactly even once it no longer fits in a uint64
+	for i := 12; i < maxUint64Index; i++ {
+		f.advance()
+	}
+	if got, want := f.state().Next, "19740274219868223167"; got != want {
+		t.Errorf("Fibonacci.state().Next = %v, want %v", got, want)
+	}
+}
+
+func Test_decodeState(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        State
+		mode         Mode
+		wantIndex    uint64
+		wantCurrent  string
+		wantPrevious string
+		wantRepaired bool
+		wantErr      error
+	}{
+		{
+			name:        "start",
+			state:       State{Schema: 1, Index: 0, Previous: "0", Current: "0", Next: "1"},
+			wantIndex:   0,
+			wantCurrent: "0",
+		},
+		{
+			name:         "first one",
+			state:        State{Schema: 1, Index: 1, Previous: "0", Current: "1", Next: "1"},
+			wantIndex:    1,
+			wantCurrent:  "1",
+			wantPrevious: "0",
+		},
+		{
+			name:         "second one",
+			state:        State{Schema: 1, Index: 2, Previous: "1", Current: "1", Next: "2"},
+			wantIndex:    2,
+			wantCurrent:  "1",
+			wantPrevious: "1",
+		},
+		{
+			name:         "beyond 2^32",
+			state:        State{Schema: 1, Index: 50, Previous: "7778742049", Current: "12586269025", Next: "20365011074"},
+			wantIndex:    50,
+			wantCurrent:  "12586269025",
+			wantPrevious: "7778742049",
+		},
+		{
+			name:         "F(93) in uint64 mode",
+			state:        State{Schema: 1, Index: 93, Previous: "7540113804746346429", Current: "12200160415121876738", Next: "19740274219868223167"},
+			wantIndex:    93,
+			wantCurrent:  "12200160415121876738",
+			wantPrevious: "7540113804746346429",
+		},
+		{
+			name:         "beyond uint64 in big mode",
+			state:        State{Schema: 1, Index: 100, Previous: "218922995834555169026", Current: "354224848179261915075", Next: "573147844013817084101"},
+			mode:         ModeBig,
+			wantIndex:    100,
+			wantCurrent:  "354224848179261915075",
+			wantPrevious: "218922995834555169026",
+		},
+		{
+			name:    "beyond uint64 in uint64 mode",
+			state:   State{Schema: 1, Index: 100, Previous: "218922995834555169026", Current: "354224848179261915075", Next: "573147844013817084101"},
+			wantErr: ErrStateOutOfRange,
+		},
+		{
+			name:         "wrong index repaired from values",
+			state:        State{Schema: 1, Index: 7, Previous: "89", Current: "144", Next: "233"},
+			wantIndex:    12,
+			wantCurrent:  "144",
+			wantPrevious: "89",
+			wantRepaired: true,
+		},
+		{
+			name:         "wrong values repaired from index",
+			state:        State{Schema: 1, Index: 12, Previous: "89", Current: "145", Next: "233"},
+			wantIndex:    12,
+			wantCurrent:  "144",
+			wantPrevious: "89",
+			wantRepaired: true,
+		},
+		{
+			name:    "unrepairable",
+			state:   State{Schema: 1, Index: 1000, Previous: "4", Current: "6", Next: "10"},
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:    "unsupported schema",
+			state:   State{Schema: 42, Index: 0, Previous: "0", Current: "0", Next: "1"},
+			wantErr: ErrCorruptState,
+		},
+		{
+			name:         "legacy",
+			state:        State{Current: "144"},
+			wantIndex:    12,
+			wantCurrent:  "144",
+			wantPrevious: "89",
+			wantRepaired: true,
+		},
+		{
+			name:    "legacy not fibonacci",
+			state:   State{Current: "145"},
+			wantErr: ErrCorruptState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repaired, err := decodeState(tt.state, Options{Mode: tt.mode})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if nil != err {
+				return
+			}
+
+			if repaired != tt.wantRepaired {
+				t.Errorf("decodeState() repaired = %v, want %v", repaired, tt.wantRepaired)
+			}
+			if got.index != tt.wantIndex {
+				t.Errorf("decodeState() index = %v, want %v", got.index, tt.wantIndex)
+			}
+			if got.currentNumber().String() != tt.wantCurrent {
+				t.Errorf("decodeState() current = %v, want %v", got.currentNumber(), tt.wantCurrent)
+			}
+			if "" != tt.wantPrevious && got.previousNumber().String() != tt.wantPrevious {
+				t.Errorf("decodeState() previous = %v, want %v", got.previousNumber(), tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func Test_decodeState_roundTrip(t *testing.T) {
+	for _, mode := range []Mode{ModeUint64, ModeBig} {
+		t.Run(mode.String(), func(t *testing.T) {
+			f := newFibonacci(mode, OverflowReject)
+			for i := 0; i < maxUint64Index; i++ {
+				f.advance()
+
+				got, repaired, err := decodeState(f.state(), Options{Mode: mode})
+				if nil != err || repaired {
+					t.Fatalf("decodeState() at index %d error = %v, repaired = %v", f.index, err, repaired)
+				}
+				if got.state() != f.state() {
+					t.Fatalf("decodeState() = %+v, want %+v", got.state(), f.state())
+				}
+			}
+		})
+	}
+}
+
+func TestInitializeFibonacci_state(t *testing.T) {
+	t.Run("restores exact state", func(t *testing.T) {
+		rdb := mockRdb{
+			value: "5",
+			state: encodeTestState(t, State{Schema: 1, Index: 2, Epoch: 3, Previous: "1", Current: "1", Next: "2"}),
+		}
+
+		got, err := InitializeFibonacci(NewRedisStore(rdb), Options{})
+		if nil != err {
+			t.Fatalf("InitializeFibonacci() error = %v", err)
+		}
+		if 2 != got.index || 3 != got.epoch || 1 != got.previous {
+			t.Errorf("InitializeFibonacci() restored %+v", got.state())
+		}
+	})
+
+	t.Run("refuses corrupt state", func(t *testing.T) {
+		rdb := mockRdb{state: "not a state"}
+
+		got, err := InitializeFibonacci(NewRedisStore(rdb), Options{})
+		if !errors.Is(err, ErrCorruptState) {
+			t.Errorf("InitializeFibonacci() error = %v, want %v", err, ErrCorruptState)
+		}
+		if nil != got {
+			t.Errorf("InitializeFibonacci() = %+v, want nil", got)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultStateKey -
+// Key the sequence state is stored under
+const DefaultStateKey = "fibonacci_state"
+
+// stateKey -
+// This function returns the key a state is stored under, DefaultStateKey for
+// an empty key
+func stateKey(key string) string {
+	if 0 == len(key) {
+		return DefaultStateKey
+	}
+
+	return key
+}
+
+// sequenceName -
+// This function returns the name of the sequence persisted under key, as
+// written in the logs, "fibonacci" for the default key
+func sequenceName(key string) string {
+	if 0 == len(key) || DefaultStateKey == key {
+		return "fibonacci"
+	}
+
+	return strings.TrimPrefix(key, sequenceKeyPrefix)
+}
+
+// ErrStateNotFound -
+// Returned by StateStore.Load when nothing is stored under the key
+var ErrStateNotFound = errors.New("no state stored")
+
+// StateStore -
+// Backend neutral storage for sequence states.
+// Implementations must be safe for concurrent use.
+type StateStore interface {
+	// Load returns the state stored under key, ErrStateNotFound when there is
+	// none, or an error wrapping ErrCorruptState when it cannot be decoded
+	Load(ctx context.Context, key string) (State, error)
+
+	// Save stores the state under key unconditionally
+	Save(ctx context.Context, key string, st State) error
+
+	// CompareAndSwap stores the state under key only when st.Version is newer
+	// than the version of the state already stored, reporting whether it did.
+	// A stored state that cannot be decoded is always replaced.
+	CompareAndSwap(ctx context.Context, key string, st State) (bool, error)
+
+	// Delete removes the state stored under key, deleting a key that does not
+	// exist is not an error
+	Delete(ctx context.Context, key string) error
+
+	// Keys returns the keys starting with prefix that hold a state, in no
+	// particular order
+	Keys(ctx context.Context, prefix string) ([]string, error)
+
+	// Ping reports whether the store can be reached, an error means states
+	// cannot currently be loaded or saved
+	Ping(ctx context.Context) error
+
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// StoreKind -
+// Names a StateStore implementation
+type StoreKind int
+
+const (
+	// StoreRedis keeps states in redis
+	StoreRedis StoreKind = iota
+	// StoreMemory keeps states in memory, they are lost on restart
+	StoreMemory
+	// StoreFile keeps states in files on the local disk
+	StoreFile
+	// StoreWAL keeps states in a write-ahead log on the local disk
+	StoreWAL
+)
+
+// ParseStoreKind -
+// This function converts a store name ("redis", "memory", "file" or "wal")
+// into a StoreKind. An empty string selects the default redis store.
+func ParseStoreKind(s string) (StoreKind, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "redis":
+		return StoreRedis, nil
+	case "memory":
+		return StoreMemory, nil
+	case "file":
+		return StoreFile, nil
+	case "wal":
+		return StoreWAL, nil
+	}
+
+	return StoreRedis, fmt.Errorf("unknown state store: %q", s)
+}
+
+// String -
+// This method returns the name of the store as accepted by ParseStoreKind
+func (k StoreKind) String() string {
+	switch k {
+	case StoreMemory:
+		return "memory"
+	case StoreFile:
+		return "file"
+	case StoreWAL:
+		return "wal"
+	}
+
+	return "redis"
+}
+
+// MemoryStore -
+// StateStore keeping states in memory, mostly useful for tests and for running
+// without any persistence
+type MemoryStore struct {
+	mutex  sync.Mutex
+	states map[string]State
+}
+
+// NewMemoryStore -
+// This function creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: map[string]State{},
+	}
+}
+
+// Load -
+// This method returns the state stored under key
+func (ms *MemoryStore) Load(ctx context.Context, key string) (State, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	st, ok := ms.states[key]
+	if !ok {
+		return State{}, ErrStateNotFound
+	}
+
+	return st, nil
+}
+
+// Save -
+// This method stores the state under key
+func (ms *MemoryStore) Save(ctx context.Context, key string, st State) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.states[key] = st
+	return nil
+}
+
+// CompareAndSwap -
+// This method stores the state under key if it is newer than the stored one
+func (ms *MemoryStore) CompareAndSwap(ctx context.Context, key string, st State) (bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if stored, ok := ms.states[key]; ok && stored.Version >= st.Version {
+		return false, nil
+	}
+
+	ms.states[key] = st
+	return true, nil
+}
+
+// Delete -
+// This method removes the state stored under key
+func (ms *MemoryStore) Delete(ctx context.Context, key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	delete(ms.states, key)
+	return nil
+}
+
+// Keys -
+// This method returns the keys starting with prefix
+func (ms *MemoryStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	keys := []string{}
+	for key := range ms.states {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// Ping -
+// This method always succeeds for MemoryStore
+func (ms *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close -
+// This method is a no-op for MemoryStore
+func (ms *MemoryStore) Close() error {
+	return nil
+}
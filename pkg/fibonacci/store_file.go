@@ -0,0 +1,211 @@
+package fibonacci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStore -
+// StateStore keeping each state as a JSON file in a directory on the local
+// disk. Files are replaced atomically by renaming a synced temporary file, so
+// a crash leaves either the old or the new state.
+// Only one process may use a directory at a time.
+type FileStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewFileStore -
+// This function creates a FileStore in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+// path -
+// This method returns the file holding the state for key
+func (fs *FileStore) path(key string) string {
+	return filepath.Join(fs.dir, url.PathEscape(key)+".json")
+}
+
+// Load -
+// This method returns the state stored under key
+func (fs *FileStore) Load(ctx context.Context, key string) (State, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.load(key)
+}
+
+// load -
+// This method reads the state file for key, the caller must hold the mutex
+func (fs *FileStore) load(key string) (State, error) {
+	raw, err := ioutil.ReadFile(fs.path(key))
+	if os.IsNotExist(err) {
+		return State{}, ErrStateNotFound
+	}
+	if nil != err {
+		return State{}, err
+	}
+
+	var st State
+	if err := json.Unmarshal(raw, &st); nil != err {
+		return State{}, fmt.Errorf("%w: %v", ErrCorruptState, err)
+	}
+
+	return st, nil
+}
+
+// Save -
+// This method stores the state under key
+func (fs *FileStore) Save(ctx context.Context, key string, st State) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.save(key, st)
+}
+
+// save -
+// This method atomically replaces the state file for key, the caller must
+// hold the mutex
+func (fs *FileStore) save(key string, st State) error {
+	payload, err := json.Marshal(st)
+	if nil != err {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(fs.dir, ".state-*")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(payload); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), fs.path(key)); nil != err {
+		return err
+	}
+
+	return syncDir(fs.dir)
+}
+
+// CompareAndSwap -
+// This method stores the state under key if it is newer than the stored one
+func (fs *FileStore) CompareAndSwap(ctx context.Context, key string, st State) (bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	stored, err := fs.load(key)
+	switch {
+	case nil == err && stored.Version >= st.Version:
+		return false, nil
+	case nil != err && !errors.Is(err, ErrStateNotFound) && !errors.Is(err, ErrCorruptState):
+		return false, err
+	}
+
+	if err := fs.save(key, st); nil != err {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete -
+// This method removes the file holding the state stored under key
+func (fs *FileStore) Delete(ctx context.Context, key string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := os.Remove(fs.path(key)); nil != err {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return syncDir(fs.dir)
+}
+
+// Keys -
+// This method returns the keys starting with prefix, read from the names of
+// the state files
+func (fs *FileStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	infos, err := ioutil.ReadDir(fs.dir)
+	if nil != err {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		key, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if nil != err || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Ping -
+// This method checks that the directory holding the states still exists
+func (fs *FileStore) Ping(ctx context.Context) error {
+	info, err := os.Stat(fs.dir)
+	if nil != err {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", fs.dir)
+	}
+
+	return nil
+}
+
+// Close -
+// This method is a no-op for FileStore, every write is already synced
+func (fs *FileStore) Close() error {
+	return nil
+}
+
+// syncDir -
+// This function syncs a directory so a rename inside it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if nil != err {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,121 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "fibonacci-store")
+	if nil != err {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(newTestDir(t))
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	testStateStore(t, store)
+}
+
+func TestFileStore_Ping(t *testing.T) {
+	dir := newTestDir(t)
+	store, err := NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	os.RemoveAll(dir)
+	if err := store.Ping(context.Background()); !os.IsNotExist(err) {
+		t.Errorf("Ping() of a removed directory error = %v, want it not to exist", err)
+	}
+}
+
+func TestFileStore_reopen(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+	st := State{Schema: stateSchemaVersion, Version: 1, Index: 1, Previous: "0", Current: "1", Next: "1"}
+
+	first, err := NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if _, err := first.CompareAndSwap(ctx, "with/slash", st); nil != err {
+		t.Fatalf("FileStore.CompareAndSwap() error = %v", err)
+	}
+	first.Close()
+
+	second, err := NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if got, err := second.Load(ctx, "with/slash"); nil != err || got != st {
+		t.Errorf("FileStore.Load() after reopening = %+v, %v, want %+v", got, err, st)
+	}
+}
+
+func TestFileStore_corrupt(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store, err := NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, DefaultStateKey+".json"), []byte("{"), 0644); nil != err {
+		t.Fatalf("Failed to write corrupt state: %v", err)
+	}
+
+	if _, err := store.Load(ctx, DefaultStateKey); !errors.Is(err, ErrCorruptState) {
+		t.Errorf("FileStore.Load() error = %v, want %v", err, ErrCorruptState)
+	}
+
+	st := State{Schema: stateSchemaVersion, Version: 1, Current: "0"}
+	if swapped, err := store.CompareAndSwap(ctx, DefaultStateKey, st); nil != err || !swapped {
+		t.Errorf("FileStore.CompareAndSwap() = %v, %v, want a corrupt state replaced", swapped, err)
+	}
+}
+
+func TestInitializeFibonacci_fileStore(t *testing.T) {
+	dir := newTestDir(t)
+
+	store, err := NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	f, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		f.GetNext()
+	}
+	f.Close()
+
+	// A new store on the same directory stands in for a restart
+	store, err = NewFileStore(dir)
+	if nil != err {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	restored, err := InitializeFibonacci(store, Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	defer restored.Close()
+
+	if got := restored.GetCurrent().Value; NewNumber(55) != got {
+		t.Errorf("Restored current = %v, want 55", got)
+	}
+}
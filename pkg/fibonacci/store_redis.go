@@ -0,0 +1,176 @@
+package fibonacci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Key holding only the current value, as saved by earlier versions
+const redisLegacyKey = "fibonacci_current"
+
+// Keys asked for by each SCAN call of RedisStore.Keys
+const redisScanCount = 100
+
+// Escapes the characters of a key that are special in a SCAN MATCH pattern
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// casScript -
+// Writes the state in ARGV[2] to KEYS[1] only if its version in ARGV[1] is
+// newer than the version of the state already stored, so a stale write can
+// never replace a newer one. Returns 1 when written and 0 when stale.
+const casScript = `
+local stored = redis.call('GET', KEYS[1])
+if stored then
+	local ok, decoded = pcall(cjson.decode, stored)
+	if ok and type(decoded) == 'table' and tonumber(decoded['version'] or 0) >= tonumber(ARGV[1]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1
+`
+
+// RedisClient -
+// Wrapper interface for the redis client calls used by RedisStore
+type RedisClient interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	Ping(ctx context.Context) *redis.StatusCmd
+	Close() error
+}
+
+// RedisStore -
+// StateStore keeping each state as a single JSON value in redis, so all of
+// its fields are replaced atomically
+type RedisStore struct {
+	rdb RedisClient
+}
+
+// NewRedisStore -
+// This function creates a RedisStore using the given client
+func NewRedisStore(rdb RedisClient) *RedisStore {
+	return &RedisStore{
+		rdb: rdb,
+	}
+}
+
+// Load -
+// This method returns the state stored under key. For DefaultStateKey a value
+// saved by earlier versions under the legacy key is returned as a State with
+// only Current set and a Schema of 0.
+func (rs *RedisStore) Load(ctx context.Context, key string) (State, error) {
+	raw, err := rs.rdb.Get(ctx, key).Result()
+	if redis.Nil == err && DefaultStateKey == key {
+		return rs.loadLegacy(ctx)
+	}
+	if redis.Nil == err {
+		return State{}, ErrStateNotFound
+	}
+	if nil != err {
+		return State{}, err
+	}
+
+	var st State
+	if err := json.Unmarshal([]byte(raw), &st); nil != err {
+		return State{}, fmt.Errorf("%w: %v", ErrCorruptState, err)
+	}
+
+	return st, nil
+}
+
+// loadLegacy -
+// This method reads the value saved by earlier versions
+func (rs *RedisStore) loadLegacy(ctx context.Context) (State, error) {
+	raw, err := rs.rdb.Get(ctx, redisLegacyKey).Result()
+	if redis.Nil == err {
+		return State{}, ErrStateNotFound
+	}
+	if nil != err {
+		return State{}, err
+	}
+
+	return State{Current: raw}, nil
+}
+
+// Save -
+// This method stores the state under key
+func (rs *RedisStore) Save(ctx context.Context, key string, st State) error {
+	payload, err := json.Marshal(st)
+	if nil != err {
+		return err
+	}
+
+	return rs.rdb.Set(ctx, key, string(payload), 0).Err()
+}
+
+// CompareAndSwap -
+// This method stores the state under key through a Lua script, so comparing
+// versions and writing happen atomically in redis
+func (rs *RedisStore) CompareAndSwap(ctx context.Context, key string, st State) (bool, error) {
+	payload, err := json.Marshal(st)
+	if nil != err {
+		return false, err
+	}
+
+	written, err := rs.rdb.Eval(
+		ctx, casScript, []string{key}, st.Version, string(payload),
+	).Int()
+	if nil != err {
+		return false, err
+	}
+
+	return 1 == written, nil
+}
+
+// Delete -
+// This method removes the state stored under key, along with the legacy value
+// for the default key so it is not migrated back
+func (rs *RedisStore) Delete(ctx context.Context, key string) error {
+	keys := []string{key}
+	if DefaultStateKey == key {
+		keys = append(keys, redisLegacyKey)
+	}
+
+	return rs.rdb.Del(ctx, keys...).Err()
+}
+
+// Keys -
+// This method returns the keys starting with prefix, scanning redis so it is
+// not blocked the way KEYS would block it
+func (rs *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	match := redisGlobEscaper.Replace(prefix) + "*"
+
+	keys := []string{}
+	var cursor uint64
+	for {
+		page, next, err := rs.rdb.Scan(ctx, cursor, match, redisScanCount).Result()
+		if nil != err {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		if cursor = next; 0 == cursor {
+			return keys, nil
+		}
+	}
+}
+
+// Ping -
+// This method checks that redis answers
+func (rs *RedisStore) Ping(ctx context.Context) error {
+	return rs.rdb.Ping(ctx).Err()
+}
+
+// Close -
+// This method closes the redis client
+func (rs *RedisStore) Close() error {
+	return rs.rdb.Close()
+}
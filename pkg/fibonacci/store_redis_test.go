@@ -0,0 +1,90 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRedisStore(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	testStateStore(t, NewRedisStore(rdb))
+}
+
+func TestRedisStore_Load(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		values  map[string]string
+		want    State
+		wantErr error
+	}{
+		{
+			name:   "state",
+			key:    DefaultStateKey,
+			values: map[string]string{DefaultStateKey: `{"schema":1,"version":3,"index":2,"previous":"1","current":"1","next":"2"}`},
+			want:   State{Schema: 1, Version: 3, Index: 2, Previous: "1", Current: "1", Next: "2"},
+		},
+		{
+			name:   "legacy",
+			key:    DefaultStateKey,
+			values: map[string]string{redisLegacyKey: "5"},
+			want:   State{Current: "5"},
+		},
+		{
+			name:   "state preferred over legacy",
+			key:    DefaultStateKey,
+			values: map[string]string{DefaultStateKey: `{"schema":1,"index":0,"previous":"0","current":"0","next":"1"}`, redisLegacyKey: "5"},
+			want:   State{Schema: 1, Previous: "0", Current: "0", Next: "1"},
+		},
+		{
+			name:    "legacy only for the default key",
+			key:     "other",
+			values:  map[string]string{redisLegacyKey: "5"},
+			wantErr: ErrStateNotFound,
+		},
+		{
+			name:    "not json",
+			key:     DefaultStateKey,
+			values:  map[string]string{DefaultStateKey: "5"},
+			wantErr: ErrCorruptState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			for k, v := range tt.values {
+				mr.Set(k, v)
+			}
+
+			got, err := NewRedisStore(rdb).Load(context.Background(), tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RedisStore.Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RedisStore.Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisStore_Ping(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	store := NewRedisStore(rdb)
+
+	mr.Close()
+	if err := store.Ping(context.Background()); nil == err {
+		t.Errorf("Ping() of an unreachable redis error = nil, want an error")
+	}
+}
+
+func TestRedisStore_CompareAndSwap_corrupt(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Set(DefaultStateKey, "not a state")
+
+	st := State{Schema: stateSchemaVersion, Version: 1, Current: "0"}
+	swapped, err := NewRedisStore(rdb).CompareAndSwap(context.Background(), DefaultStateKey, st)
+	if nil != err || !swapped {
+		t.Errorf("RedisStore.CompareAndSwap() = %v, %v, want a corrupt state replaced", swapped, err)
+	}
+}
@@ -0,0 +1,132 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testStateStore -
+// This function checks the behaviour every StateStore must share
+func testStateStore(t *testing.T, store StateStore) {
+	ctx := context.Background()
+
+	if err := store.Ping(ctx); nil != err {
+		t.Errorf("Ping() error = %v", err)
+	}
+
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Load() of a missing key error = %v, want %v", err, ErrStateNotFound)
+	}
+
+	first := State{Schema: stateSchemaVersion, Version: 5, Index: 12, Previous: "89", Current: "144", Next: "233"}
+	if err := store.Save(ctx, "sequence", first); nil != err {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got, err := store.Load(ctx, "sequence"); nil != err || got != first {
+		t.Errorf("Load() = %+v, %v, want %+v", got, err, first)
+	}
+
+	stale := first
+	stale.Version = 4
+	if swapped, err := store.CompareAndSwap(ctx, "sequence", stale); nil != err || swapped {
+		t.Errorf("CompareAndSwap() of an older version = %v, %v, want false", swapped, err)
+	}
+
+	same := first
+	same.Index = 13
+	if swapped, err := store.CompareAndSwap(ctx, "sequence", same); nil != err || swapped {
+		t.Errorf("CompareAndSwap() of the same version = %v, %v, want false", swapped, err)
+	}
+
+	newer := first
+	newer.Version = 6
+	newer.Index = 13
+	if swapped, err := store.CompareAndSwap(ctx, "sequence", newer); nil != err || !swapped {
+		t.Errorf("CompareAndSwap() of a newer version = %v, %v, want true", swapped, err)
+	}
+	if got, err := store.Load(ctx, "sequence"); nil != err || got != newer {
+		t.Errorf("Load() = %+v, %v, want %+v", got, err, newer)
+	}
+
+	if swapped, err := store.CompareAndSwap(ctx, "other", first); nil != err || !swapped {
+		t.Errorf("CompareAndSwap() of a new key = %v, %v, want true", swapped, err)
+	}
+	if got, err := store.Load(ctx, "sequence"); nil != err || got != newer {
+		t.Errorf("Keys are not independent, Load() = %+v, %v, want %+v", got, err, newer)
+	}
+
+	if err := store.Save(ctx, "seq*:x", first); nil != err {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if keys, err := store.Keys(ctx, "sequ"); nil != err || !reflect.DeepEqual([]string{"sequence"}, sortedKeys(keys)) {
+		t.Errorf("Keys() = %v, %v, want [sequence]", keys, err)
+	}
+	if keys, err := store.Keys(ctx, "seq*"); nil != err || !reflect.DeepEqual([]string{"seq*:x"}, keys) {
+		t.Errorf("Keys() with a pattern character = %v, %v, want [seq*:x]", keys, err)
+	}
+	if keys, err := store.Keys(ctx, ""); nil != err || !reflect.DeepEqual([]string{"other", "seq*:x", "sequence"}, sortedKeys(keys)) {
+		t.Errorf("Keys() of every key = %v, %v, want [other seq*:x sequence]", keys, err)
+	}
+	if err := store.Delete(ctx, "seq*:x"); nil != err {
+		t.Errorf("Delete() error = %v", err)
+	}
+
+	if err := store.Delete(ctx, "other"); nil != err {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := store.Load(ctx, "other"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Load() of a deleted key error = %v, want %v", err, ErrStateNotFound)
+	}
+	if got, err := store.Load(ctx, "sequence"); nil != err || got != newer {
+		t.Errorf("Delete() removed another key, Load() = %+v, %v, want %+v", got, err, newer)
+	}
+	if err := store.Delete(ctx, "missing"); nil != err {
+		t.Errorf("Delete() of a missing key error = %v", err)
+	}
+
+	if err := store.Close(); nil != err {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// sortedKeys -
+// This function sorts keys returned in no particular order
+func sortedKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStateStore(t, NewMemoryStore())
+}
+
+func TestParseStoreKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    StoreKind
+		wantErr bool
+	}{
+		{name: "default", input: "", want: StoreRedis},
+		{name: "redis", input: "redis", want: StoreRedis},
+		{name: "memory", input: "Memory", want: StoreMemory},
+		{name: "file", input: "file", want: StoreFile},
+		{name: "wal", input: "WAL", want: StoreWAL},
+		{name: "unknown", input: "etcd", want: StoreRedis, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStoreKind(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseStoreKind() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseStoreKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package fibonacci
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// SyncPolicy -
+// Decides when WALStore fsyncs the records appended to its log
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs after every record, nothing acknowledged is ever lost
+	SyncAlways SyncPolicy = iota
+	// SyncBatch fsyncs once every WALOptions.BatchSize records
+	SyncBatch
+	// SyncInterval fsyncs in the background every WALOptions.Interval
+	SyncInterval
+)
+
+// ParseSyncPolicy -
+// This function converts a policy name ("always", "batch" or "interval") into
+// a SyncPolicy. An empty string selects the default always policy.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "always":
+		return SyncAlways, nil
+	case "batch":
+		return SyncBatch, nil
+	case "interval":
+		return SyncInterval, nil
+	}
+
+	return SyncAlways, fmt.Errorf("unknown fsync policy: %q", s)
+}
+
+// String -
+// This method returns the name of the policy as accepted by ParseSyncPolicy
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncBatch:
+		return "batch"
+	case SyncInterval:
+		return "interval"
+	}
+
+	return "always"
+}
+
+// WALOptions -
+// Settings for a WALStore, zero values select the defaults
+type WALOptions struct {
+	Sync SyncPolicy
+	// Records appended between fsyncs under SyncBatch, 100 by default
+	BatchSize int
+	// Time between fsyncs under SyncInterval, 1s by default
+	Interval time.Duration
+	// Records appended between snapshots, 1000 by default
+	SnapshotEvery int
+	// The default logger when nil
+	Logger *logging.Logger
+}
+
+// walRecord -
+// A single entry of the log, the state written for a key or, when Deleted is
+// set, the removal of the key. Seq numbers the records of a store in the order
+// they were written, records written before it was added have a Seq of 0.
+type walRecord struct {
+	Seq     uint64 `json:"seq,omitempty"`
+	Key     string `json:"key"`
+	State   State  `json:"state"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// walSnapshot -
+// The latest state of every key at the time the snapshot was taken, along with
+// the Seq of the last record it holds
+type walSnapshot struct {
+	Seq    uint64           `json:"seq,omitempty"`
+	States map[string]State `json:"states"`
+}
+
+// walFile -
+// The calls WALStore makes on its log, implemented by *os.File
+type walFile interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// WALStore -
+// StateStore keeping states in an append-only write-ahead log on the local
+// disk. Every write appends a checksummed record to the log, which is fsynced
+// according to the sync policy. The log is periodically folded into a snapshot
+// and truncated.
+// The log is replayed over the snapshot by the first call to any method, so a
+// torn record left by a crash is dropped and everything before it restored.
+// A write that fails is cut back out of the log; when it cannot be, the store
+// fails and refuses any further write rather than append after a torn record.
+// Only one process may use a directory at a time.
+type WALStore struct {
+	dir  string
+	opts WALOptions
+
+	mutex    sync.Mutex
+	replayed bool
+	states   map[string]State
+	wal      walFile
+	offset   int64
+	seq      uint64
+	appended int
+	unsynced int
+	failed   error
+	closed   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWALStore -
+// This function creates a WALStore in dir, creating the directory if needed
+func NewWALStore(dir string, opts WALOptions) (*WALStore, error) {
+	if 0 >= opts.BatchSize {
+		opts.BatchSize = 100
+	}
+	if 0 >= opts.Interval {
+		opts.Interval = time.Second
+	}
+	if 0 >= opts.SnapshotEvery {
+		opts.SnapshotEvery = 1000
+	}
+
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+
+	ws := &WALStore{
+		dir:    dir,
+		opts:   opts,
+		states: map[string]State{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if SyncInterval == opts.Sync {
+		go ws.syncLoop()
+	} else {
+		close(ws.done)
+	}
+
+	return ws, nil
+}
+
+// Load -
+// This method returns the state stored under key
+func (ws *WALStore) Load(ctx context.Context, key string) (State, error) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return State{}, err
+	}
+
+	st, ok := ws.states[key]
+	if !ok {
+		return State{}, ErrStateNotFound
+	}
+
+	return st, nil
+}
+
+// Save -
+// This method appends the state for key to the log
+func (ws *WALStore) Save(ctx context.Context, key string, st State) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return err
+	}
+
+	return ws.append(key, st)
+}
+
+// CompareAndSwap -
+// This method appends the state for key to the log if it is newer than the
+// stored one
+func (ws *WALStore) CompareAndSwap(ctx context.Context, key string, st State) (bool, error) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return false, err
+	}
+
+	if stored, ok := ws.states[key]; ok && stored.Version >= st.Version {
+		return false, nil
+	}
+
+	if err := ws.append(key, st); nil != err {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete -
+// This method appends the removal of key to the log
+func (ws *WALStore) Delete(ctx context.Context, key string) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return err
+	}
+
+	if _, ok := ws.states[key]; !ok {
+		return nil
+	}
+
+	return ws.write(walRecord{Key: key, Deleted: true})
+}
+
+// Keys -
+// This method returns the keys starting with prefix
+func (ws *WALStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key := range ws.states {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// logger -
+// This method returns the logger of the store
+func (ws *WALStore) logger() *logging.Logger {
+	return ws.opts.Logger.Component("store").With("store", "wal")
+}
+
+// Ping -
+// This method checks that the store is open, its snapshot and log can be read
+// and it has not failed
+func (ws *WALStore) Ping(ctx context.Context) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if err := ws.replay(); nil != err {
+		return err
+	}
+
+	return ws.failed
+}
+
+// Close -
+// This method fsyncs and closes the log
+func (ws *WALStore) Close() error {
+	ws.mutex.Lock()
+	if ws.closed {
+		ws.mutex.Unlock()
+		return nil
+	}
+	ws.closed = true
+	ws.mutex.Unlock()
+
+	close(ws.stop)
+	<-ws.done
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if nil == ws.wal {
+		return nil
+	}
+
+	if err := ws.wal.Sync(); nil != err {
+		ws.wal.Close()
+		return err
+	}
+
+	return ws.wal.Close()
+}
+
+// replay -
+// This method restores the states from the snapshot and the log once, the
+// caller must hold the mutex
+func (ws *WALStore) replay() error {
+	if ws.closed {
+		return os.ErrClosed
+	}
+	if ws.replayed {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(ws.dir, snapshotFileName))
+	switch {
+	case nil == err:
+		var snap walSnapshot
+		if err := json.Unmarshal(raw, &snap); nil != err {
+			return fmt.Errorf("%w: snapshot: %v", ErrCorruptState, err)
+		}
+		for key, st := range snap.States {
+			ws.states[key] = st
+		}
+		ws.seq = snap.Seq
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(ws.dir, walFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if nil != err {
+		return err
+	}
+
+	records, valid, err := ws.readLog(wal)
+	if nil != err {
+		wal.Close()
+		return err
+	}
+
+	// Drop anything after the last intact record, i.e. a write torn by a crash
+	if err := wal.Truncate(valid); nil != err {
+		wal.Close()
+		return err
+	}
+	if _, err := wal.Seek(valid, io.SeekStart); nil != err {
+		wal.Close()
+		return err
+	}
+
+	ws.wal = wal
+	ws.offset = valid
+	ws.appended = records
+	ws.replayed = true
+
+	ws.logger().Info("replayed write-ahead log", "records", records, "dir", ws.dir)
+	return nil
+}
+
+// readLog -
+// This method applies every intact record of the log to the states, returning
+// how many were read and the offset just past the last one. Records already
+// held by the snapshot are skipped, the log is only truncated after the
+// snapshot is written so a crash in between leaves both.
+func (ws *WALStore) readLog(wal *os.File) (int, int64, error) {
+	reader := bufio.NewReader(wal)
+	records := 0
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if io.EOF == err {
+			if 0 < len(line) {
+				ws.logger().Warn("dropping torn write-ahead log record", "offset", valid)
+			}
+			return records, valid, nil
+		}
+		if nil != err {
+			return 0, 0, err
+		}
+
+		rec, ok := decodeRecord(line)
+		if !ok {
+			ws.logger().Warn("dropping corrupt write-ahead log record", "offset", valid)
+			return records, valid, nil
+		}
+
+		switch stored, exists := ws.states[rec.Key]; {
+		case 0 != rec.Seq && rec.Seq <= ws.seq:
+			// Already in the snapshot
+		case rec.Deleted:
+			delete(ws.states, rec.Key)
+		case 0 == rec.Seq && exists && stored.Version >= rec.State.Version:
+			// Unnumbered records can not be told apart from those in the
+			// snapshot, only a newer version of a state is taken from them
+		default:
+			ws.states[rec.Key] = rec.State
+		}
+		if ws.seq < rec.Seq {
+			ws.seq = rec.Seq
+		}
+
+		records++
+		valid += int64(len(line))
+	}
+}
+
+// append -
+// This method writes the state for key to the log and applies it, the caller
+// must hold the mutex
+func (ws *WALStore) append(key string, st State) error {
+	return ws.write(walRecord{Key: key, State: st})
+}
+
+// write -
+// This method writes a record to the log and applies it, the caller must hold
+// the mutex. Once the record is written it is not undone: a snapshot failing
+// afterwards is only logged and tried again on the next write.
+func (ws *WALStore) write(rec walRecord) error {
+	if nil != ws.failed {
+		return ws.failed
+	}
+
+	rec.Seq = ws.seq + 1
+	line, err := encodeRecord(rec)
+	if nil != err {
+		return err
+	}
+
+	if _, err := ws.wal.Write(line); nil != err {
+		// Part of the record may have been written, later records must not
+		// follow it or replaying would drop them along with it
+		if cut := ws.truncate(ws.offset); nil != cut {
+			return cut
+		}
+		return err
+	}
+	ws.offset += int64(len(line))
+	ws.seq = rec.Seq
+
+	if rec.Deleted {
+		delete(ws.states, rec.Key)
+	} else {
+		ws.states[rec.Key] = rec.State
+	}
+	ws.appended++
+	ws.unsynced++
+
+	switch {
+	case SyncAlways == ws.opts.Sync,
+		SyncBatch == ws.opts.Sync && ws.unsynced >= ws.opts.BatchSize:
+		if err := ws.sync(); nil != err {
+			return err
+		}
+	}
+
+	if ws.appended >= ws.opts.SnapshotEvery {
+		if err := ws.snapshot(); nil != err {
+			ws.logger().Error("could not snapshot write-ahead log", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// truncate -
+// This method cuts the log back to offset and moves the next write there. A
+// log that cannot be cut back fails the store, the caller must hold the mutex.
+func (ws *WALStore) truncate(offset int64) error {
+	if err := ws.wal.Truncate(offset); nil != err {
+		return ws.fail(err)
+	}
+	if _, err := ws.wal.Seek(offset, io.SeekStart); nil != err {
+		return ws.fail(err)
+	}
+
+	ws.offset = offset
+	return nil
+}
+
+// fail -
+// This method marks the store failed, so every later write returns the error,
+// the caller must hold the mutex
+func (ws *WALStore) fail(err error) error {
+	ws.failed = fmt.Errorf("write-ahead log failed: %v", err)
+	ws.logger().Error("write-ahead log failed, refusing writes", "error", err)
+
+	return ws.failed
+}
+
+// sync -
+// This method fsyncs the log, the caller must hold the mutex
+func (ws *WALStore) sync() error {
+	if 0 == ws.unsynced {
+		return nil
+	}
+
+	if err := ws.wal.Sync(); nil != err {
+		return err
+	}
+
+	ws.unsynced = 0
+	return nil
+}
+
+// snapshot -
+// This method writes every state to a new snapshot, then truncates the log it
+// replaces, the caller must hold the mutex.
+// Crashing in between is safe, the snapshot holds the Seq of the last record
+// so replaying skips the records it already holds.
+func (ws *WALStore) snapshot() error {
+	payload, err := json.Marshal(walSnapshot{Seq: ws.seq, States: ws.states})
+	if nil != err {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(ws.dir, ".snapshot-*")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(payload); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(ws.dir, snapshotFileName)); nil != err {
+		return err
+	}
+	if err := syncDir(ws.dir); nil != err {
+		return err
+	}
+
+	if err := ws.truncate(0); nil != err {
+		return err
+	}
+	if err := ws.wal.Sync(); nil != err {
+		return err
+	}
+
+	ws.appended = 0
+	ws.unsynced = 0
+	return nil
+}
+
+// syncLoop -
+// This method fsyncs the log every interval under SyncInterval
+func (ws *WALStore) syncLoop() {
+	defer close(ws.done)
+
+	ticker := time.NewTicker(ws.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ws.stop:
+			return
+		case <-ticker.C:
+			ws.mutex.Lock()
+			if nil != ws.wal {
+				if err := ws.sync(); nil != err {
+					ws.logger().Error("could not sync write-ahead log", "error", err)
+				}
+			}
+			ws.mutex.Unlock()
+		}
+	}
+}
+
+// encodeRecord -
+// This function formats a record as a line holding the CRC-32 of its JSON
+// followed by the JSON itself
+func encodeRecord(rec walRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if nil != err {
+		return nil, err
+	}
+
+	line := make([]byte, 0, len(payload)+10)
+	line = strconv.AppendUint(line, uint64(crc32.ChecksumIEEE(payload)), 16)
+	line = append(line, ' ')
+	line = append(line, payload...)
+	line = append(line, '\n')
+
+	return line, nil
+}
+
+// decodeRecord -
+// This function parses a line written by encodeRecord, reporting false when it
+// is damaged
+func decodeRecord(line []byte) (walRecord, bool) {
+	var rec walRecord
+
+	sep := bytes.IndexByte(line, ' ')
+	if 0 > sep {
+		return rec, false
+	}
+
+	checksum, err := strconv.ParseUint(string(line[:sep]), 16, 32)
+	if nil != err {
+		return rec, false
+	}
+
+	payload := bytes.TrimSuffix(line[sep+1:], []byte{'\n'})
+	if uint32(checksum) != crc32.ChecksumIEEE(payload) {
+		return rec, false
+	}
+
+	if err := json.Unmarshal(payload, &rec); nil != err {
+		return rec, false
+	}
+
+	return rec, true
+}
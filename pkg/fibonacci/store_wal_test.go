@@ -0,0 +1,392 @@
+package fibonacci
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWALStore(t *testing.T, dir string, opts WALOptions) *WALStore {
+	t.Helper()
+
+	store, err := NewWALStore(dir, opts)
+	if nil != err {
+		t.Fatalf("NewWALStore() error = %v", err)
+	}
+
+	return store
+}
+
+func testState(version uint64) State {
+	return State{Schema: stateSchemaVersion, Version: version, Index: version, Current: "0"}
+}
+
+func TestWALStore(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncBatch, SyncInterval} {
+		t.Run(policy.String(), func(t *testing.T) {
+			testStateStore(t, newTestWALStore(t, newTestDir(t), WALOptions{Sync: policy}))
+		})
+	}
+}
+
+func TestWALStore_Ping(t *testing.T) {
+	store := newTestWALStore(t, newTestDir(t), WALOptions{})
+
+	store.Close()
+	if err := store.Ping(context.Background()); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Ping() of a closed store error = %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestWALStore_replay(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	for v := uint64(1); v <= 10; v++ {
+		if _, err := store.CompareAndSwap(ctx, "a", testState(v)); nil != err {
+			t.Fatalf("WALStore.CompareAndSwap() error = %v", err)
+		}
+	}
+	store.Save(ctx, "b", testState(3))
+	store.Close()
+
+	reopened := newTestWALStore(t, dir, WALOptions{})
+
+	if got, err := reopened.Load(ctx, "a"); nil != err || 10 != got.Version {
+		t.Errorf("WALStore.Load() after replay = %+v, %v, want version 10", got, err)
+	}
+	if got, err := reopened.Load(ctx, "b"); nil != err || 3 != got.Version {
+		t.Errorf("WALStore.Load() after replay = %+v, %v, want version 3", got, err)
+	}
+
+	// Deletions are replayed too
+	reopened.Delete(ctx, "b")
+	reopened.Close()
+
+	again := newTestWALStore(t, dir, WALOptions{})
+	defer again.Close()
+
+	if _, err := again.Load(ctx, "b"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("WALStore.Load() of a deleted key after replay error = %v, want %v", err, ErrStateNotFound)
+	}
+}
+
+func TestWALStore_tornRecord(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	store.Save(ctx, "a", testState(1))
+	store.Save(ctx, "a", testState(2))
+	store.Close()
+
+	// Simulate a crash in the middle of writing the third record
+	walPath := filepath.Join(dir, walFileName)
+	wal, err := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if nil != err {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	wal.Write([]byte(`1234abcd {"key":"a","state":{"vers`))
+	wal.Close()
+
+	reopened := newTestWALStore(t, dir, WALOptions{})
+	if got, err := reopened.Load(ctx, "a"); nil != err || 2 != got.Version {
+		t.Errorf("WALStore.Load() after torn write = %+v, %v, want version 2", got, err)
+	}
+
+	// The torn record is truncated so new records follow the intact ones
+	if _, err := reopened.CompareAndSwap(ctx, "a", testState(3)); nil != err {
+		t.Fatalf("WALStore.CompareAndSwap() error = %v", err)
+	}
+	reopened.Close()
+
+	again := newTestWALStore(t, dir, WALOptions{})
+	defer again.Close()
+	if got, err := again.Load(ctx, "a"); nil != err || 3 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 3", got, err)
+	}
+}
+
+func TestWALStore_corruptRecord(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	store.Save(ctx, "a", testState(1))
+	store.Close()
+
+	walPath := filepath.Join(dir, walFileName)
+	wal, _ := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0644)
+	wal.Write([]byte("0 {\"key\":\"a\",\"state\":{\"version\":9}}\n"))
+	wal.Close()
+
+	reopened := newTestWALStore(t, dir, WALOptions{})
+	defer reopened.Close()
+	if got, err := reopened.Load(ctx, "a"); nil != err || 1 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want the record with a bad checksum dropped", got, err)
+	}
+}
+
+func TestWALStore_snapshot(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{SnapshotEvery: 5})
+	for v := uint64(1); v <= 12; v++ {
+		store.Save(ctx, "a", testState(v))
+	}
+	store.Save(ctx, "b", testState(1))
+	store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); nil != err {
+		t.Fatalf("No snapshot written: %v", err)
+	}
+
+	// 13 records with a snapshot every 5 leaves 3 in the log
+	raw, _ := ioutil.ReadFile(filepath.Join(dir, walFileName))
+	if lines := countLines(raw); 3 != lines {
+		t.Errorf("Log holds %v records after snapshotting, want 3", lines)
+	}
+
+	reopened := newTestWALStore(t, dir, WALOptions{SnapshotEvery: 5})
+	defer reopened.Close()
+	if got, err := reopened.Load(ctx, "a"); nil != err || 12 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 12", got, err)
+	}
+	if got, err := reopened.Load(ctx, "b"); nil != err || 1 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 1", got, err)
+	}
+}
+
+func TestWALStore_corruptSnapshot(t *testing.T) {
+	dir := newTestDir(t)
+	ioutil.WriteFile(filepath.Join(dir, snapshotFileName), []byte("{"), 0644)
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	defer store.Close()
+	if _, err := store.Load(context.Background(), "a"); !errors.Is(err, ErrCorruptState) {
+		t.Errorf("WALStore.Load() error = %v, want %v", err, ErrCorruptState)
+	}
+}
+
+func TestWALStore_syncPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("batch", func(t *testing.T) {
+		store := newTestWALStore(t, newTestDir(t), WALOptions{Sync: SyncBatch, BatchSize: 3})
+		defer store.Close()
+
+		store.Save(ctx, "a", testState(1))
+		store.Save(ctx, "a", testState(2))
+		if 2 != store.unsynced {
+			t.Errorf("unsynced = %v after 2 records, want 2", store.unsynced)
+		}
+		store.Save(ctx, "a", testState(3))
+		if 0 != store.unsynced {
+			t.Errorf("unsynced = %v after a full batch, want 0", store.unsynced)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		store := newTestWALStore(t, newTestDir(t), WALOptions{Sync: SyncInterval, Interval: 10 * time.Millisecond})
+		defer store.Close()
+
+		store.Save(ctx, "a", testState(1))
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			store.mutex.Lock()
+			unsynced := store.unsynced
+			store.mutex.Unlock()
+			if 0 == unsynced {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Errorf("Log was never synced in the background")
+	})
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    SyncPolicy
+		wantErr bool
+	}{
+		{name: "default", input: "", want: SyncAlways},
+		{name: "always", input: "always", want: SyncAlways},
+		{name: "batch", input: "Batch", want: SyncBatch},
+		{name: "interval", input: "interval", want: SyncInterval},
+		{name: "unknown", input: "never", want: SyncAlways, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSyncPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSyncPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseSyncPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitializeFibonacci_walStore(t *testing.T) {
+	dir := newTestDir(t)
+
+	f, err := InitializeFibonacci(newTestWALStore(t, dir, WALOptions{}), Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	for i := 0; i < 12; i++ {
+		f.GetNext()
+	}
+	f.Close()
+
+	restored, err := InitializeFibonacci(newTestWALStore(t, dir, WALOptions{}), Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	defer restored.Close()
+
+	if got := restored.GetCurrent().Value; NewNumber(144) != got {
+		t.Errorf("Restored current = %v, want 144", got)
+	}
+}
+
+func countLines(raw []byte) int {
+	lines := 0
+	for _, b := range raw {
+		if '\n' == b {
+			lines++
+		}
+	}
+
+	return lines
+}
+
+func TestWALStore_crashBeforeTruncate(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+	walPath := filepath.Join(dir, walFileName)
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	store.Save(ctx, "a", testState(5))
+	store.Delete(ctx, "a")
+	store.Save(ctx, "a", testState(1))
+	store.Save(ctx, "b", testState(5))
+	store.Save(ctx, "b", testState(2))
+	raw, _ := ioutil.ReadFile(walPath)
+
+	// Simulate a crash after the snapshot is written but before the log it
+	// replaces is truncated
+	store.mutex.Lock()
+	if err := store.snapshot(); nil != err {
+		t.Fatalf("WALStore.snapshot() error = %v", err)
+	}
+	store.mutex.Unlock()
+	store.Close()
+	ioutil.WriteFile(walPath, raw, 0644)
+
+	reopened := newTestWALStore(t, dir, WALOptions{})
+	for key, version := range map[string]uint64{"a": 1, "b": 2} {
+		if got, err := reopened.Load(ctx, key); nil != err || version != got.Version {
+			t.Errorf("WALStore.Load() of %v = %+v, %v, want version %v", key, got, err, version)
+		}
+	}
+
+	// New records are numbered after the skipped ones
+	reopened.Save(ctx, "a", testState(3))
+	reopened.Close()
+
+	again := newTestWALStore(t, dir, WALOptions{})
+	defer again.Close()
+	if got, err := again.Load(ctx, "a"); nil != err || 3 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 3", got, err)
+	}
+}
+
+// tornFile -
+// A log whose writes fail after writing half of the record, and whose
+// truncation fails once cut is set
+type tornFile struct {
+	*os.File
+	cut error
+}
+
+func (tf *tornFile) Write(p []byte) (int, error) {
+	n, _ := tf.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
+
+func (tf *tornFile) Truncate(size int64) error {
+	if nil != tf.cut {
+		return tf.cut
+	}
+
+	return tf.File.Truncate(size)
+}
+
+func TestWALStore_failedWrite(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{})
+	store.Save(ctx, "a", testState(1))
+
+	torn := &tornFile{File: store.wal.(*os.File)}
+	store.wal = torn
+	if _, err := store.CompareAndSwap(ctx, "a", testState(2)); nil == err {
+		t.Fatalf("WALStore.CompareAndSwap() of a failed write succeeded")
+	}
+
+	// The half written record is cut back out, so later records still replay
+	store.wal = torn.File
+	if _, err := store.CompareAndSwap(ctx, "a", testState(3)); nil != err {
+		t.Fatalf("WALStore.CompareAndSwap() error = %v", err)
+	}
+	store.Close()
+
+	reopened := newTestWALStore(t, dir, WALOptions{})
+	if got, err := reopened.Load(ctx, "a"); nil != err || 3 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 3", got, err)
+	}
+
+	// A record that cannot be cut back out fails the store
+	reopened.wal = &tornFile{File: reopened.wal.(*os.File), cut: errors.New("read-only file system")}
+	reopened.Save(ctx, "a", testState(4))
+	reopened.wal = reopened.wal.(*tornFile).File
+	if err := reopened.Save(ctx, "a", testState(5)); nil == err {
+		t.Errorf("WALStore.Save() of a failed store succeeded")
+	}
+	if err := reopened.Ping(ctx); nil == err {
+		t.Errorf("WALStore.Ping() of a failed store succeeded")
+	}
+	reopened.Close()
+}
+
+func TestWALStore_failedSnapshot(t *testing.T) {
+	dir := newTestDir(t)
+	ctx := context.Background()
+
+	store := newTestWALStore(t, dir, WALOptions{SnapshotEvery: 2})
+	defer store.Close()
+	store.Save(ctx, "a", testState(1))
+
+	// The written record is not undone by the snapshot failing
+	os.RemoveAll(dir)
+	if swapped, err := store.CompareAndSwap(ctx, "a", testState(2)); nil != err || !swapped {
+		t.Errorf("WALStore.CompareAndSwap() = %v, %v, want true", swapped, err)
+	}
+	if got, err := store.Load(ctx, "a"); nil != err || 2 != got.Version {
+		t.Errorf("WALStore.Load() = %+v, %v, want version 2", got, err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package logging contains a structured, leveled logger writing text or JSON
+package logging // import "github.com/dvo-dev/fibonacci-backend/pkg/logging"
@@ -0,0 +1,389 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level -
+// The severity of a record, records below the level of a Logger are dropped
+type Level int
+
+const (
+	// LevelDebug is for detail only wanted while investigating, such as every
+	// state written
+	LevelDebug Level = iota - 1
+	// LevelInfo is for the normal operation of the app, the default
+	LevelInfo
+	// LevelWarn is for problems the app works around, such as invalid settings
+	LevelWarn
+	// LevelError is for failures
+	LevelError
+)
+
+// ParseLevel -
+// This function converts a level name ("debug", "info", "warn" or "error")
+// into a Level. An empty string selects the default info level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return LevelInfo, nil
+	case "debug":
+		return LevelDebug, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level: %q", s)
+}
+
+// String -
+// This method returns the name a record of the level is written with
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+
+	return "INFO"
+}
+
+// Format -
+// How records are written
+type Format int
+
+const (
+	// FormatText writes every record as a line of key=value pairs
+	FormatText Format = iota
+	// FormatJSON writes every record as a line holding a JSON object
+	FormatJSON
+)
+
+// ParseFormat -
+// This function converts a format name ("text" or "json") into a Format. An
+// empty string selects the default text format.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+
+	return FormatText, fmt.Errorf("unknown log format: %q", s)
+}
+
+// ParseLevels -
+// This function converts a comma separated list of component=level pairs,
+// such as "persister=debug,http=warn", into levels per component
+func ParseLevels(s string) (map[string]Level, error) {
+	levels := map[string]Level{}
+	for _, pair := range strings.Split(s, ",") {
+		if 0 == len(strings.TrimSpace(pair)) {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if 2 != len(parts) || 0 == len(strings.TrimSpace(parts[0])) {
+			return nil, fmt.Errorf("invalid component level: %q", pair)
+		}
+
+		level, err := ParseLevel(parts[1])
+		if nil != err {
+			return nil, err
+		}
+		levels[strings.TrimSpace(parts[0])] = level
+	}
+
+	return levels, nil
+}
+
+// Options -
+// Settings of a Logger
+type Options struct {
+	Level  Level
+	Format Format
+	// Levels overriding Level for the named components
+	Levels map[string]Level
+}
+
+// output -
+// The destination shared by a Logger and every Logger derived from it
+type output struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	format Format
+	levels map[string]Level
+	now    func() time.Time
+}
+
+// Logger -
+// Writes leveled records holding a message and key-value fields, like
+// log/slog. Loggers are derived with With for fields and Component for a
+// subsystem, each of which may have its own level. A nil Logger writes
+// through Default, without the fields it would have been given.
+type Logger struct {
+	out       *output
+	level     Level
+	component string
+	fields    []interface{}
+}
+
+// New -
+// This function creates a Logger writing to w
+func New(w io.Writer, opts Options) *Logger {
+	levels := map[string]Level{}
+	for component, level := range opts.Levels {
+		levels[component] = level
+	}
+
+	return &Logger{
+		out:   &output{w: w, format: opts.Format, levels: levels, now: time.Now},
+		level: opts.Level,
+	}
+}
+
+// Discard -
+// This function creates a Logger writing nothing
+func Discard() *Logger {
+	return New(ioutil.Discard, Options{Level: LevelError + 1})
+}
+
+var defaultLogger = New(os.Stderr, Options{})
+
+// Default -
+// This function returns the Logger nil Loggers write through, info level
+// text on stderr
+func Default() *Logger {
+	return defaultLogger
+}
+
+// With -
+// This method returns a Logger adding the key-value pairs to every record
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if nil == l {
+		return nil
+	}
+
+	derived := *l
+	derived.fields = append(append([]interface{}(nil), l.fields...), kv...)
+	return &derived
+}
+
+// Component -
+// This method returns a Logger for the named subsystem, its records carry the
+// component and are filtered by the level configured for it, if any
+func (l *Logger) Component(name string) *Logger {
+	if nil == l {
+		return nil
+	}
+
+	derived := *l
+	derived.component = name
+	if level, ok := l.out.levels[name]; ok {
+		derived.level = level
+	}
+	return &derived
+}
+
+// Enabled -
+// This method reports whether records of the level are written
+func (l *Logger) Enabled(level Level) bool {
+	if nil == l {
+		return Default().Enabled(level)
+	}
+
+	return level >= l.level
+}
+
+// Debug -
+// This method writes a debug record with the key-value pairs
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.write(LevelDebug, msg, kv)
+}
+
+// Info -
+// This method writes an info record with the key-value pairs
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.write(LevelInfo, msg, kv)
+}
+
+// Warn -
+// This method writes a warning record with the key-value pairs
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.write(LevelWarn, msg, kv)
+}
+
+// Error -
+// This method writes an error record with the key-value pairs
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.write(LevelError, msg, kv)
+}
+
+// write -
+// This method formats and writes a record, one line per record
+func (l *Logger) write(level Level, msg string, kv []interface{}) {
+	if nil == l {
+		Default().write(level, msg, kv)
+		return
+	}
+	if !l.Enabled(level) {
+		return
+	}
+
+	// The fixed keys come first, then the fields of the Logger and those of
+	// the record in the order given
+	keys := []string{"time", "level"}
+	values := []interface{}{l.out.now().UTC().Format(time.RFC3339Nano), level.String()}
+	if 0 < len(l.component) {
+		keys = append(keys, "component")
+		values = append(values, l.component)
+	}
+	keys = append(keys, "msg")
+	values = append(values, msg)
+
+	fields := append(append([]interface{}(nil), l.fields...), kv...)
+	for i := 0; i < len(fields); i += 2 {
+		key, ok := fields[i].(string)
+		if !ok || i+1 == len(fields) {
+			// A value without a key, as log/slog reports it
+			keys = append(keys, "!BADKEY")
+			values = append(values, fields[i])
+			i--
+			continue
+		}
+
+		keys = append(keys, key)
+		values = append(values, fields[i+1])
+	}
+
+	var b strings.Builder
+	if FormatJSON == l.out.format {
+		writeJSON(&b, keys, values)
+	} else {
+		writeText(&b, keys, values)
+	}
+	b.WriteByte('\n')
+
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	io.WriteString(l.out.w, b.String())
+}
+
+// writeText -
+// This function writes the pairs as key=value, quoting values that would
+// otherwise be ambiguous
+func writeText(b *strings.Builder, keys []string, values []interface{}) {
+	for i, key := range keys {
+		if 0 < i {
+			b.WriteByte(' ')
+		}
+
+		s := textValue(values[i])
+		if needsQuoting(s) {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprintf(b, "%s=%s", key, s)
+	}
+}
+
+// writeJSON -
+// This function writes the pairs as the members of a JSON object, in order
+func writeJSON(b *strings.Builder, keys []string, values []interface{}) {
+	b.WriteByte('{')
+	for i, key := range keys {
+		if 0 < i {
+			b.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(jsonValue(values[i]))
+		if nil != err {
+			value, _ = json.Marshal(fmt.Sprint(values[i]))
+		}
+		fmt.Fprintf(b, "%s:%s", name, value)
+	}
+	b.WriteByte('}')
+}
+
+// textValue -
+// This function formats a value for the text format
+func textValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return fmt.Sprint(v)
+}
+
+// jsonValue -
+// This function converts the values JSON does not encode usefully on its own
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case json.Marshaler:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return v
+}
+
+// needsQuoting -
+// This function reports whether a text value is empty or holds spaces,
+// quotes, equal signs or control characters
+func needsQuoting(s string) bool {
+	if 0 == len(s) {
+		return true
+	}
+
+	for _, c := range s {
+		if ' ' >= c || '"' == c || '=' == c || 0x7f == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stdWriter -
+// Adapts a Logger to an io.Writer, each write becoming a record
+type stdWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (sw stdWriter) Write(p []byte) (int, error) {
+	sw.logger.write(sw.level, strings.TrimRight(string(p), "\n"), nil)
+	return len(p), nil
+}
+
+// NewStdLogger -
+// This function returns a standard library logger writing records of the level
+// through l, for packages such as net/http that only take a *log.Logger
+func NewStdLogger(l *Logger, level Level) *log.Logger {
+	return log.New(stdWriter{logger: l, level: level}, "", 0)
+}
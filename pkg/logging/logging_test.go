@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fixedLogger -
+// This function returns a Logger writing to buf with a fixed clock
+func fixedLogger(buf *bytes.Buffer, opts Options) *Logger {
+	l := New(buf, opts)
+	l.out.now = func() time.Time {
+		return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	}
+	return l
+}
+
+func TestLogger_text(t *testing.T) {
+	var buf bytes.Buffer
+	l := fixedLogger(&buf, Options{})
+
+	l.Component("sequence").With("sequence", "fibonacci").Info(
+		"sequence moved", "index", 42, "error", errors.New("not quite"), "latency", 1500*time.Millisecond,
+	)
+
+	want := `time=2021-03-04T05:06:07Z level=INFO component=sequence msg="sequence moved" sequence=fibonacci index=42 error="not quite" latency=1.5s` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("Incorrect record, wanted: %v but got: %v", want, got)
+	}
+}
+
+func TestLogger_json(t *testing.T) {
+	var buf bytes.Buffer
+	l := fixedLogger(&buf, Options{Format: FormatJSON})
+
+	l.With("request_id", "req-1").Warn("stopped", "index", uint64(7), "error", errors.New("gone"), "latency", time.Second)
+
+	want := `{"time":"2021-03-04T05:06:07Z","level":"WARN","msg":"stopped","request_id":"req-1","index":7,"error":"gone","latency":"1s"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("Incorrect record, wanted: %v but got: %v", want, got)
+	}
+}
+
+func TestLogger_badKey(t *testing.T) {
+	var buf bytes.Buffer
+	l := fixedLogger(&buf, Options{})
+
+	l.Info("odd", 1, "index", 2, "dangling")
+
+	want := `time=2021-03-04T05:06:07Z level=INFO msg=odd !BADKEY=1 index=2 !BADKEY=dangling` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("Incorrect record, wanted: %v but got: %v", want, got)
+	}
+}
+
+func TestLogger_levels(t *testing.T) {
+	var buf bytes.Buffer
+	l := fixedLogger(&buf, Options{
+		Level:  LevelWarn,
+		Levels: map[string]Level{"persister": LevelDebug, "http": LevelError},
+	})
+
+	tests := []struct {
+		name    string
+		logger  *Logger
+		level   Level
+		written bool
+	}{
+		{name: "below the level", logger: l, level: LevelInfo, written: false},
+		{name: "at the level", logger: l, level: LevelWarn, written: true},
+		{name: "component lowered", logger: l.Component("persister"), level: LevelDebug, written: true},
+		{name: "component raised", logger: l.Component("http"), level: LevelWarn, written: false},
+		{name: "component unset", logger: l.Component("store"), level: LevelInfo, written: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			tt.logger.write(tt.level, "message", nil)
+
+			if tt.written != (0 < buf.Len()) {
+				t.Errorf("Incorrect filtering, wanted written: %v but got: %q", tt.written, buf.String())
+			}
+			if tt.written != tt.logger.Enabled(tt.level) {
+				t.Errorf("Enabled() = %v, want %v", !tt.written, tt.written)
+			}
+		})
+	}
+}
+
+func TestLogger_nil(t *testing.T) {
+	var l *Logger
+	if nil != l.Component("server").With("key", "value") {
+		t.Errorf("Loggers derived from a nil Logger should be nil")
+	}
+
+	var buf bytes.Buffer
+	defer func(prev *Logger) { defaultLogger = prev }(defaultLogger)
+	defaultLogger = fixedLogger(&buf, Options{})
+
+	l.Info("through the default")
+	if 0 == buf.Len() {
+		t.Errorf("A nil Logger should write through the default logger")
+	}
+}
+
+func TestNewStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := fixedLogger(&buf, Options{})
+
+	NewStdLogger(l.Component("http"), LevelError).Printf("http: TLS handshake error from %v", "1.2.3.4")
+
+	want := `time=2021-03-04T05:06:07Z level=ERROR component=http msg="http: TLS handshake error from 1.2.3.4"` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("Incorrect record, wanted: %v but got: %v", want, got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Level
+		wantErr bool
+	}{
+		{raw: "", want: LevelInfo},
+		{raw: "debug", want: LevelDebug},
+		{raw: " WARN ", want: LevelWarn},
+		{raw: "warning", want: LevelWarn},
+		{raw: "error", want: LevelError},
+		{raw: "verbose", want: LevelInfo, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseLevel(tt.raw)
+			if (nil != err) != tt.wantErr {
+				t.Errorf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != got {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Format
+		wantErr bool
+	}{
+		{raw: "", want: FormatText},
+		{raw: "text", want: FormatText},
+		{raw: "JSON", want: FormatJSON},
+		{raw: "xml", want: FormatText, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseFormat(tt.raw)
+			if (nil != err) != tt.wantErr {
+				t.Errorf("ParseFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != got {
+				t.Errorf("ParseFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    map[string]Level
+		wantErr bool
+	}{
+		{raw: "", want: map[string]Level{}},
+		{
+			raw:  "persister=debug, http=warn,",
+			want: map[string]Level{"persister": LevelDebug, "http": LevelWarn},
+		},
+		{raw: "persister", wantErr: true},
+		{raw: "=debug", wantErr: true},
+		{raw: "persister=loud", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseLevels(tt.raw)
+			if (nil != err) != tt.wantErr {
+				t.Errorf("ParseLevels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("ParseLevels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
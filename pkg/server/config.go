@@ -0,0 +1,628 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Address served on unless server.host_port says otherwise
+	defaultHostPort = "0.0.0.0:8080"
+
+	// Time given to drain the requests in flight, and then to write the state,
+	// on shutdown unless server.shutdown_timeout says otherwise
+	defaultShutdownTimeout = 5 * time.Second
+
+	// Address of redis unless redis.addr says otherwise
+	defaultRedisAddr = "redis:6379"
+
+	// Directory of the file and wal stores unless store.dir says otherwise
+	defaultStateDir = "state"
+
+	// Written in place of secrets when the configuration is printed
+	redacted = "REDACTED"
+)
+
+// Config -
+// Every setting of the app. It is loaded by LoadConfig from, in increasing
+// precedence, the defaults, a YAML file, the environment and the command line.
+type Config struct {
+	HostPort        string
+	ShutdownTimeout time.Duration
+
+	Log LogConfig
+
+	Store      fibonacci.StoreKind
+	StateDir   string
+	Redis      RedisConfig
+	WAL        fibonacci.WALOptions
+	Mode       fibonacci.Mode
+	Overflow   fibonacci.OverflowPolicy
+	Limits     LimitConfig
+	Middleware MiddlewareConfig
+
+	CheckTimeout      time.Duration
+	MaxPersistenceLag time.Duration
+}
+
+// LogConfig -
+// Settings of the logger, see logging.Options
+type LogConfig struct {
+	Level  logging.Level
+	Format logging.Format
+	Levels map[string]logging.Level
+}
+
+// RedisConfig -
+// Settings of the connection to redis, used by the redis store
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// LimitConfig -
+// Bounds on the work a single request may cause and on the memory held
+type LimitConfig struct {
+	MaxIndex           uint64
+	MaxRange           uint64
+	MaxBatch           uint64
+	MaxModulus         uint64
+	MaxZeckendorfIndex uint64
+	PisanoCacheSize    int
+	MaxSequences       int
+	ResetHistorySize   int
+}
+
+// MiddlewareConfig -
+// Toggles of the optional middlewares
+type MiddlewareConfig struct {
+	RequestID    bool
+	AccessLog    bool
+	ResponseTime bool
+	Metrics      bool
+}
+
+// DefaultConfig -
+// This function returns the configuration used when nothing is set
+func DefaultConfig() Config {
+	return Config{
+		HostPort:        defaultHostPort,
+		ShutdownTimeout: defaultShutdownTimeout,
+		Log:             LogConfig{Level: logging.LevelInfo, Format: logging.FormatText},
+		Store:           fibonacci.StoreRedis,
+		StateDir:        defaultStateDir,
+		Redis:           RedisConfig{Addr: defaultRedisAddr},
+		Limits: LimitConfig{
+			MaxIndex:           defaultMaxIndex,
+			MaxRange:           defaultMaxRange,
+			MaxBatch:           defaultMaxBatch,
+			MaxModulus:         defaultMaxModulus,
+			MaxZeckendorfIndex: defaultMaxZeckendorfIndex,
+			PisanoCacheSize:    defaultPisanoCacheSize,
+			MaxSequences:       defaultMaxSequences,
+			ResetHistorySize:   defaultResetHistory,
+		},
+		Middleware: MiddlewareConfig{
+			RequestID:    true,
+			AccessLog:    true,
+			ResponseTime: true,
+			Metrics:      true,
+		},
+		CheckTimeout:      defaultCheckTimeout,
+		MaxPersistenceLag: defaultMaxPersistenceLag,
+	}
+}
+
+// setting -
+// A single setting, named by its key in the YAML file, where the dots nest,
+// its environment variable and its flag, the key with dashes for underscores
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	value  func(c *Config) flag.Value
+}
+
+// flagName -
+// This method returns the name of the command line flag of the setting
+func (st setting) flagName() string {
+	return strings.Replace(st.key, "_", "-", -1)
+}
+
+// settings -
+// This function lists every setting, in the order they are printed
+func settings() []setting {
+	return []setting{
+		{key: "server.host_port", env: "SERVING_HOST_PORT", usage: "address to serve on",
+			value: func(c *Config) flag.Value { return stringVar(&c.HostPort) }},
+		{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "time to drain requests and then to flush the state on shutdown",
+			value: func(c *Config) flag.Value { return durationVar(&c.ShutdownTimeout) }},
+
+		{key: "log.level", env: "LOG_LEVEL", usage: "lowest level logged: debug, info, warn or error",
+			value: func(c *Config) flag.Value { return levelVar(&c.Log.Level) }},
+		{key: "log.format", env: "LOG_FORMAT", usage: "log format: text or json",
+			value: func(c *Config) flag.Value { return formatVar(&c.Log.Format) }},
+		{key: "log.levels", env: "LOG_LEVELS", usage: "levels of single components, e.g. persister=debug,http=warn",
+			value: func(c *Config) flag.Value { return levelsVar(&c.Log.Levels) }},
+
+		{key: "store.kind", env: "STATE_STORE", usage: "state store: redis, memory, file or wal",
+			value: func(c *Config) flag.Value { return storeVar(&c.Store) }},
+		{key: "store.dir", env: "STATE_FILE_DIR", usage: "directory of the file and wal stores",
+			value: func(c *Config) flag.Value { return stringVar(&c.StateDir) }},
+		{key: "redis.addr", env: "REDIS_HOST_PORT", usage: "address of redis",
+			value: func(c *Config) flag.Value { return stringVar(&c.Redis.Addr) }},
+		{key: "redis.password", env: "REDIS_PASSWORD", usage: "password of redis", secret: true,
+			value: func(c *Config) flag.Value { return stringVar(&c.Redis.Password) }},
+		{key: "redis.db", env: "REDIS_DB", usage: "database of redis",
+			value: func(c *Config) flag.Value { return intVar(&c.Redis.DB) }},
+		{key: "wal.fsync", env: "WAL_FSYNC", usage: "when the log is fsynced: always, batch or interval",
+			value: func(c *Config) flag.Value { return syncVar(&c.WAL.Sync) }},
+		{key: "wal.fsync_batch", env: "WAL_FSYNC_BATCH", usage: "writes between fsyncs under batch, 0 for 100",
+			value: func(c *Config) flag.Value { return intVar(&c.WAL.BatchSize) }},
+		{key: "wal.fsync_interval", env: "WAL_FSYNC_INTERVAL", usage: "time between fsyncs under interval, 0 for 1s",
+			value: func(c *Config) flag.Value { return durationVar(&c.WAL.Interval) }},
+		{key: "wal.snapshot_every", env: "WAL_SNAPSHOT_EVERY", usage: "writes between snapshots, 0 for 1000",
+			value: func(c *Config) flag.Value { return intVar(&c.WAL.SnapshotEvery) }},
+
+		{key: "fibonacci.mode", env: "FIBONACCI_MODE", usage: "arithmetic of the sequence: uint64 or big",
+			value: func(c *Config) flag.Value { return modeVar(&c.Mode) }},
+		{key: "fibonacci.overflow_policy", env: "FIBONACCI_OVERFLOW_POLICY", usage: "what uint64 mode does past F(93): reject, wrap or saturate",
+			value: func(c *Config) flag.Value { return overflowVar(&c.Overflow) }},
+		{key: "fibonacci.max_index", env: "FIBONACCI_MAX_INDEX", usage: "largest index served by /fibonacci",
+			value: func(c *Config) flag.Value { return uintVar(&c.Limits.MaxIndex) }},
+		{key: "fibonacci.max_range", env: "FIBONACCI_MAX_RANGE", usage: "most terms streamed by a single range request",
+			value: func(c *Config) flag.Value { return uintVar(&c.Limits.MaxRange) }},
+		{key: "fibonacci.max_batch", env: "FIBONACCI_MAX_BATCH", usage: "most terms advanced by a single /next request",
+			value: func(c *Config) flag.Value { return uintVar(&c.Limits.MaxBatch) }},
+		{key: "fibonacci.max_modulus", env: "FIBONACCI_MAX_MODULUS", usage: "largest modulus served by /pisano",
+			value: func(c *Config) flag.Value { return uintVar(&c.Limits.MaxModulus) }},
+		{key: "fibonacci.max_zeckendorf_index", env: "FIBONACCI_MAX_ZECKENDORF_INDEX", usage: "index of the largest term /fibonacci/zeckendorf decomposes",
+			value: func(c *Config) flag.Value { return uintVar(&c.Limits.MaxZeckendorfIndex) }},
+		{key: "fibonacci.pisano_cache_size", env: "PISANO_CACHE_SIZE", usage: "Pisano periods cached",
+			value: func(c *Config) flag.Value { return intVar(&c.Limits.PisanoCacheSize) }},
+		{key: "sequences.max", env: "MAX_SEQUENCES", usage: "most named sequences kept in the state store",
+			value: func(c *Config) flag.Value { return intVar(&c.Limits.MaxSequences) }},
+		{key: "sequences.reset_history_size", env: "RESET_HISTORY_SIZE", usage: "resets listed by /sequence/resets",
+			value: func(c *Config) flag.Value { return intVar(&c.Limits.ResetHistorySize) }},
+
+		{key: "middleware.request_id", env: "MIDDLEWARE_REQUEST_ID", usage: "give every request an ID",
+			value: func(c *Config) flag.Value { return boolVar(&c.Middleware.RequestID) }},
+		{key: "middleware.access_log", env: "MIDDLEWARE_ACCESS_LOG", usage: "log every request",
+			value: func(c *Config) flag.Value { return boolVar(&c.Middleware.AccessLog) }},
+		{key: "middleware.response_time", env: "MIDDLEWARE_RESPONSE_TIME", usage: "report the time taken in X-Response-Time",
+			value: func(c *Config) flag.Value { return boolVar(&c.Middleware.ResponseTime) }},
+		{key: "middleware.metrics", env: "MIDDLEWARE_METRICS", usage: "count requests for /metrics",
+			value: func(c *Config) flag.Value { return boolVar(&c.Middleware.Metrics) }},
+
+		{key: "health.check_timeout", env: "HEALTH_CHECK_TIMEOUT", usage: "longest a single health check may take",
+			value: func(c *Config) flag.Value { return durationVar(&c.CheckTimeout) }},
+		{key: "health.max_persistence_lag", env: "READY_MAX_PERSISTENCE_LAG", usage: "age of the oldest unwritten change past which the server is not ready",
+			value: func(c *Config) flag.Value { return durationVar(&c.MaxPersistenceLag) }},
+	}
+}
+
+// LoadConfig -
+// This function loads the configuration from, in increasing precedence, the
+// defaults, the YAML file named by the -config flag or the CONFIG_FILE
+// environment variable, the environment read through getenv and the command
+// line arguments, then validates it. flag.ErrHelp is returned, after the usage
+// is printed to stderr, when -h or -help is given.
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+	all := settings()
+
+	// The flags are only recorded while parsing, as the file they may name
+	// has to be applied first
+	fs := flag.NewFlagSet("fibonacci_server", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML configuration file (env CONFIG_FILE)")
+
+	flags := map[string]string{}
+	for _, st := range all {
+		name := st.flagName()
+		fs.Var(
+			recordedVar{Value: st.value(&cfg), set: func(raw string) { flags[name] = raw }},
+			name, fmt.Sprintf("%v (env %v)", st.usage, st.env),
+		)
+	}
+	if err := fs.Parse(args); nil != err {
+		if flag.ErrHelp == err {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return Config{}, err
+	}
+	if 0 < fs.NArg() {
+		return Config{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if 0 < len(*configFile) {
+		file, err := readConfigFile(*configFile)
+		if nil != err {
+			return Config{}, err
+		}
+		if err := applyConfig(&cfg, all, func(st setting) string { return "key " + st.key }, func(st setting) (string, bool) {
+			raw, ok := file[st.key]
+			delete(file, st.key)
+			return raw, ok
+		}); nil != err {
+			return Config{}, fmt.Errorf("%v: %v", *configFile, err)
+		}
+		if 0 < len(file) {
+			return Config{}, fmt.Errorf("%v: unknown keys: %v", *configFile, sortedKeys(file))
+		}
+	}
+
+	if err := applyConfig(&cfg, all, func(st setting) string { return st.env }, func(st setting) (string, bool) {
+		raw := getenv(st.env)
+		return raw, 0 < len(raw)
+	}); nil != err {
+		return Config{}, err
+	}
+
+	if err := applyConfig(&cfg, all, func(st setting) string { return "flag -" + st.flagName() }, func(st setting) (string, bool) {
+		raw, ok := flags[st.flagName()]
+		return raw, ok
+	}); nil != err {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// applyConfig -
+// This function sets every setting lookup finds a value for, failing on the
+// first invalid value, which is reported under the name given by source
+func applyConfig(cfg *Config, all []setting, source func(setting) string, lookup func(setting) (string, bool)) error {
+	for _, st := range all {
+		raw, ok := lookup(st)
+		if !ok {
+			continue
+		}
+
+		if err := st.value(cfg).Set(raw); nil != err {
+			return fmt.Errorf("invalid %v: %v", source(st), err)
+		}
+	}
+
+	return nil
+}
+
+// readConfigFile -
+// This function reads a YAML file into its values by dotted key, the maps
+// given for log.levels are turned into component=level lists
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(raw, &doc); nil != err {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	values := map[string]string{}
+	flattenConfig("", doc, values)
+	return values, nil
+}
+
+// flattenConfig -
+// This function walks the nested maps of a YAML document, recording the
+// scalars under their dotted keys
+func flattenConfig(prefix string, node map[interface{}]interface{}, values map[string]string) {
+	for k, v := range node {
+		key := fmt.Sprint(k)
+		if 0 < len(prefix) {
+			key = prefix + "." + key
+		}
+
+		nested, ok := v.(map[interface{}]interface{})
+		switch {
+		case ok && "log.levels" == key:
+			var pairs []string
+			for component, level := range nested {
+				pairs = append(pairs, fmt.Sprintf("%v=%v", component, level))
+			}
+			sort.Strings(pairs)
+			values[key] = strings.Join(pairs, ",")
+		case ok:
+			flattenConfig(key, nested, values)
+		case nil == v:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Validate -
+// This method checks the settings that parse but cannot work, reporting all of
+// them at once
+func (c Config) Validate() error {
+	var problems []string
+	if _, _, err := net.SplitHostPort(c.HostPort); nil != err {
+		problems = append(problems, fmt.Sprintf("server.host_port: %v", err))
+	}
+	if fibonacci.StoreRedis == c.Store {
+		if _, _, err := net.SplitHostPort(c.Redis.Addr); nil != err {
+			problems = append(problems, fmt.Sprintf("redis.addr: %v", err))
+		}
+	}
+	if (fibonacci.StoreFile == c.Store || fibonacci.StoreWAL == c.Store) && 0 == len(c.StateDir) {
+		problems = append(problems, "store.dir: must be set for the file and wal stores")
+	}
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{key: "server.shutdown_timeout", value: c.ShutdownTimeout},
+		{key: "health.check_timeout", value: c.CheckTimeout},
+		{key: "health.max_persistence_lag", value: c.MaxPersistenceLag},
+	} {
+		if 0 >= d.value {
+			problems = append(problems, fmt.Sprintf("%v: must be positive", d.key))
+		}
+	}
+	if 0 > c.WAL.Interval {
+		problems = append(problems, "wal.fsync_interval: must not be negative")
+	}
+
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{key: "redis.db", value: c.Redis.DB},
+		{key: "wal.fsync_batch", value: c.WAL.BatchSize},
+		{key: "wal.snapshot_every", value: c.WAL.SnapshotEvery},
+		{key: "fibonacci.pisano_cache_size", value: c.Limits.PisanoCacheSize},
+		{key: "sequences.max", value: c.Limits.MaxSequences},
+		{key: "sequences.reset_history_size", value: c.Limits.ResetHistorySize},
+	} {
+		if 0 > n.value {
+			problems = append(problems, fmt.Sprintf("%v: must not be negative", n.key))
+		}
+	}
+
+	if 0 < len(problems) {
+		return fmt.Errorf("invalid configuration: %v", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Fields -
+// This method returns the effective settings as key-value pairs for logging,
+// in the order of the settings, with secrets that are set redacted
+func (c Config) Fields() []interface{} {
+	var kv []interface{}
+	for _, st := range settings() {
+		value := st.value(&c).String()
+		if st.secret && 0 < len(value) {
+			value = redacted
+		}
+		kv = append(kv, st.key, value)
+	}
+
+	return kv
+}
+
+// String -
+// This method formats the effective settings as key=value pairs, with secrets
+// redacted so a printed Config never leaks them
+func (c Config) String() string {
+	kv := c.Fields()
+	pairs := make([]string, 0, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%v=%q", kv[i], kv[i+1]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// LoggingOptions -
+// This method returns the options of the logger
+func (c Config) LoggingOptions() logging.Options {
+	return logging.Options{Level: c.Log.Level, Format: c.Log.Format, Levels: c.Log.Levels}
+}
+
+// sortedKeys -
+// This function returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// funcVar -
+// A flag.Value parsing and formatting a setting through the given funcs
+type funcVar struct {
+	set    func(string) error
+	get    func() string
+	isBool bool
+}
+
+func (fv funcVar) Set(raw string) error { return fv.set(raw) }
+func (fv funcVar) IsBoolFlag() bool     { return fv.isBool }
+
+func (fv funcVar) String() string {
+	// The flag package formats a zero value to tell defaults apart
+	if nil == fv.get {
+		return ""
+	}
+
+	return fv.get()
+}
+
+// recordedVar -
+// A flag.Value recording the raw values it is given rather than setting them
+type recordedVar struct {
+	flag.Value
+	set func(string)
+}
+
+func (rv recordedVar) Set(raw string) error {
+	rv.set(raw)
+	return nil
+}
+
+func (rv recordedVar) String() string {
+	if nil == rv.Value {
+		return ""
+	}
+
+	return rv.Value.String()
+}
+
+func (rv recordedVar) IsBoolFlag() bool {
+	bf, ok := rv.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+func stringVar(p *string) flag.Value {
+	return funcVar{
+		set: func(raw string) error { *p = raw; return nil },
+		get: func() string { return *p },
+	}
+}
+
+func boolVar(p *bool) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = strconv.ParseBool(raw)
+			return err
+		},
+		get:    func() string { return strconv.FormatBool(*p) },
+		isBool: true,
+	}
+}
+
+func intVar(p *int) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = strconv.Atoi(raw)
+			return err
+		},
+		get: func() string { return strconv.Itoa(*p) },
+	}
+}
+
+func uintVar(p *uint64) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = strconv.ParseUint(raw, 10, 64)
+			return err
+		},
+		get: func() string { return strconv.FormatUint(*p, 10) },
+	}
+}
+
+func durationVar(p *time.Duration) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = time.ParseDuration(raw)
+			return err
+		},
+		get: func() string { return p.String() },
+	}
+}
+
+func levelVar(p *logging.Level) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = logging.ParseLevel(raw)
+			return err
+		},
+		get: func() string { return strings.ToLower(p.String()) },
+	}
+}
+
+func formatVar(p *logging.Format) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = logging.ParseFormat(raw)
+			return err
+		},
+		get: func() string {
+			if logging.FormatJSON == *p {
+				return "json"
+			}
+			return "text"
+		},
+	}
+}
+
+func levelsVar(p *map[string]logging.Level) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = logging.ParseLevels(raw)
+			return err
+		},
+		get: func() string {
+			pairs := make([]string, 0, len(*p))
+			for component, level := range *p {
+				pairs = append(pairs, component+"="+strings.ToLower(level.String()))
+			}
+			sort.Strings(pairs)
+			return strings.Join(pairs, ",")
+		},
+	}
+}
+
+func storeVar(p *fibonacci.StoreKind) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = fibonacci.ParseStoreKind(raw)
+			return err
+		},
+		get: func() string { return p.String() },
+	}
+}
+
+func syncVar(p *fibonacci.SyncPolicy) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = fibonacci.ParseSyncPolicy(raw)
+			return err
+		},
+		get: func() string { return p.String() },
+	}
+}
+
+func modeVar(p *fibonacci.Mode) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = fibonacci.ParseMode(raw)
+			return err
+		},
+		get: func() string { return p.String() },
+	}
+}
+
+func overflowVar(p *fibonacci.OverflowPolicy) flag.Value {
+	return funcVar{
+		set: func(raw string) (err error) {
+			*p, err = fibonacci.ParseOverflowPolicy(raw)
+			return err
+		},
+		get: func() string { return p.String() },
+	}
+}
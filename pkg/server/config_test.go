@@ -0,0 +1,244 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+// writeConfigFile -
+// This function writes a YAML configuration file to a temporary directory
+func writeConfigFile(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "server-config")
+	if nil != err {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); nil != err {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+// envOf -
+// This function returns a getenv reading from m
+func envOf(m map[string]string) func(string) string {
+	return func(name string) string {
+		return m[name]
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	file := writeConfigFile(t, `
+server:
+  host_port: 127.0.0.1:9000
+  shutdown_timeout: 10s
+log:
+  format: json
+  levels:
+    persister: debug
+    http: warn
+store:
+  kind: wal
+  dir: /var/lib/fibonacci
+redis:
+  password: from-file
+  db: 2
+wal:
+  fsync: batch
+  fsync_batch: 10
+fibonacci:
+  max_index: 500
+middleware:
+  access_log: false
+`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(c *Config)
+	}{
+		{
+			name: "defaults",
+			want: func(c *Config) {},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"SERVING_HOST_PORT":         "0.0.0.0:9090",
+				"REDIS_HOST_PORT":           "cache:6380",
+				"REDIS_PASSWORD":            "secret",
+				"REDIS_DB":                  "1",
+				"FIBONACCI_MODE":            "big",
+				"MIDDLEWARE_METRICS":        "false",
+				"READY_MAX_PERSISTENCE_LAG": "1m",
+			},
+			want: func(c *Config) {
+				c.HostPort = "0.0.0.0:9090"
+				c.Redis = RedisConfig{Addr: "cache:6380", Password: "secret", DB: 1}
+				c.Mode = fibonacci.ModeBig
+				c.Middleware.Metrics = false
+				c.MaxPersistenceLag = time.Minute
+			},
+		},
+		{
+			name: "file",
+			args: []string{"-config", file},
+			want: func(c *Config) {
+				c.HostPort = "127.0.0.1:9000"
+				c.ShutdownTimeout = 10 * time.Second
+				c.Log.Format = logging.FormatJSON
+				c.Log.Levels = map[string]logging.Level{"persister": logging.LevelDebug, "http": logging.LevelWarn}
+				c.Store = fibonacci.StoreWAL
+				c.StateDir = "/var/lib/fibonacci"
+				c.Redis.Password = "from-file"
+				c.Redis.DB = 2
+				c.WAL = fibonacci.WALOptions{Sync: fibonacci.SyncBatch, BatchSize: 10}
+				c.Limits.MaxIndex = 500
+				c.Middleware.AccessLog = false
+			},
+		},
+		{
+			name: "environment over file over defaults, flags over all",
+			args: []string{"-fibonacci.max-index=700", "-middleware.access-log", "-redis.db", "4"},
+			env: map[string]string{
+				"CONFIG_FILE":         file,
+				"FIBONACCI_MAX_INDEX": "600",
+				"REDIS_PASSWORD":      "from-env",
+				"MAX_SEQUENCES":       "5",
+			},
+			want: func(c *Config) {
+				c.HostPort = "127.0.0.1:9000"
+				c.ShutdownTimeout = 10 * time.Second
+				c.Log.Format = logging.FormatJSON
+				c.Log.Levels = map[string]logging.Level{"persister": logging.LevelDebug, "http": logging.LevelWarn}
+				c.Store = fibonacci.StoreWAL
+				c.StateDir = "/var/lib/fibonacci"
+				c.Redis.Password = "from-env"
+				c.Redis.DB = 4
+				c.WAL = fibonacci.WALOptions{Sync: fibonacci.SyncBatch, BatchSize: 10}
+				c.Limits.MaxIndex = 700
+				c.Limits.MaxSequences = 5
+				c.Middleware.AccessLog = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := DefaultConfig()
+			tt.want(&want)
+
+			got, err := LoadConfig(tt.args, envOf(tt.env))
+			if nil != err {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("LoadConfig() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_invalid(t *testing.T) {
+	unknown := writeConfigFile(t, "server:\n  host_port: 0.0.0.0:8080\n  hostport: typo\n")
+	malformed := writeConfigFile(t, "server: [\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "environment",
+			env:  map[string]string{"FIBONACCI_MAX_INDEX": "-1"},
+			want: "invalid FIBONACCI_MAX_INDEX",
+		},
+		{
+			name: "flag",
+			args: []string{"-store.kind=etcd"},
+			want: "invalid flag -store.kind",
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-max-index=5"},
+			want: "flag provided but not defined",
+		},
+		{
+			name: "unknown key",
+			args: []string{"-config", unknown},
+			want: "unknown keys: [server.hostport]",
+		},
+		{
+			name: "malformed file",
+			args: []string{"-config", malformed},
+			want: "yaml",
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "/nonexistent/config.yaml"},
+			want: "no such file",
+		},
+		{
+			name: "validation",
+			env: map[string]string{
+				"SERVING_HOST_PORT":    "8080",
+				"HEALTH_CHECK_TIMEOUT": "0s",
+				"REDIS_DB":             "-1",
+			},
+			want: "invalid configuration: server.host_port: address 8080: missing port in address; " +
+				"health.check_timeout: must be positive; redis.db: must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(tt.args, envOf(tt.env))
+			if nil == err || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Fields(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Redis.Password = "hunter2"
+	cfg.Log.Levels = map[string]logging.Level{"http": logging.LevelWarn, "persister": logging.LevelDebug}
+
+	fields := map[interface{}]interface{}{}
+	kv := cfg.Fields()
+	for i := 0; i < len(kv); i += 2 {
+		fields[kv[i]] = kv[i+1]
+	}
+
+	want := map[string]string{
+		"server.host_port":      defaultHostPort,
+		"redis.password":        redacted,
+		"log.levels":            "http=warn,persister=debug",
+		"log.level":             "info",
+		"store.kind":            "redis",
+		"middleware.access_log": "true",
+	}
+	for key, value := range want {
+		if value != fields[key] {
+			t.Errorf("Incorrect %v, wanted: %v but got: %v", key, value, fields[key])
+		}
+	}
+	if len(settings()) != len(fields) {
+		t.Errorf("Incorrect number of fields, wanted: %v but got: %v", len(settings()), len(fields))
+	}
+	if s := cfg.String(); strings.Contains(s, "hunter2") {
+		t.Errorf("Password leaked by String(): %v", s)
+	}
+}
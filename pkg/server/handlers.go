@@ -1,18 +1,36 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
 )
 
 // fibonacciSequence -
 // Simple wrapper interface for accessing Server's fibSequence to make
-// testing easier.
+// testing easier. Besides the current, next and previous terms it covers
+// iterating over several next terms at once, stepping back, seeking to an
+// index, resetting to the start, which returns the term and epoch it reset
+// from and clears the epoch, and the overflow policy and epoch the sequence is
+// served with.
 type fibonacciSequence interface {
-	GetCurrent(s *Server) uint64
-	GetNext(s *Server) uint64
-	GetPrevious(s *Server) uint64
+	GetCurrent(s *Server) fibonacci.Term
+	GetNext(s *Server) (fibonacci.Term, error)
+	GetNextN(s *Server, k int) ([]fibonacci.Term, error)
+	GetPrevious(s *Server) fibonacci.Term
+	Back(s *Server) (fibonacci.Term, error)
+	Seek(s *Server, index uint64) (fibonacci.Term, error)
+	Reset(s *Server) (fibonacci.Term, uint64)
+	GetOverflowPolicy(s *Server) fibonacci.OverflowPolicy
+	GetEpoch(s *Server) uint64
 }
 
 // fibonacciSeq -
@@ -20,82 +38,621 @@ type fibonacciSequence interface {
 type fibonacciSeq struct{}
 
 // GetCurrent -
-// This method retrieves the given Server's current fibonacci number
-func (fs fibonacciSeq) GetCurrent(s *Server) uint64 {
+// This method retrieves the given Server's current fibonacci term
+func (fs fibonacciSeq) GetCurrent(s *Server) fibonacci.Term {
 	return s.fibSequence.GetCurrent()
 }
 
 // GetNext -
-// This method retrieves the given Server's next fibonacci number
-func (fs fibonacciSeq) GetNext(s *Server) uint64 {
-	return s.fibSequence.GetNext(s.rdb)
+// This method retrieves the given Server's next fibonacci term
+func (fs fibonacciSeq) GetNext(s *Server) (fibonacci.Term, error) {
+	return s.fibSequence.GetNext()
+}
+
+// GetNextN -
+// This method advances the given Server's sequence by k and retrieves the
+// terms passed through
+func (fs fibonacciSeq) GetNextN(s *Server, k int) ([]fibonacci.Term, error) {
+	return s.fibSequence.GetNextN(k)
 }
 
 // GetPrevious -
-// This method retrieves the given Server's previous fibonacci number
-func (fs fibonacciSeq) GetPrevious(s *Server) uint64 {
+// This method retrieves the given Server's previous fibonacci term
+func (fs fibonacciSeq) GetPrevious(s *Server) fibonacci.Term {
 	return s.fibSequence.GetPrevious()
 }
 
+// Back -
+// This method rewinds the given Server's sequence by one
+func (fs fibonacciSeq) Back(s *Server) (fibonacci.Term, error) {
+	return s.fibSequence.Back()
+}
+
+// Seek -
+// This method moves the given Server's sequence to the given index
+func (fs fibonacciSeq) Seek(s *Server, index uint64) (fibonacci.Term, error) {
+	return s.fibSequence.Seek(index)
+}
+
+// Reset -
+// This method moves the given Server's sequence back to the start
+func (fs fibonacciSeq) Reset(s *Server) (fibonacci.Term, uint64) {
+	return s.fibSequence.Reset()
+}
+
+// GetOverflowPolicy -
+// This method retrieves the given Server's overflow policy
+func (fs fibonacciSeq) GetOverflowPolicy(s *Server) fibonacci.OverflowPolicy {
+	return s.fibSequence.GetOverflowPolicy()
+}
+
+// GetEpoch -
+// This method retrieves the number of times the given Server's sequence has
+// wrapped around
+func (fs fibonacciSeq) GetEpoch(s *Server) uint64 {
+	return s.fibSequence.GetEpoch()
+}
+
 var fibSeq fibonacciSequence
 
 func init() {
 	fibSeq = fibonacciSeq{}
 }
 
+// cursor -
+// The operations of a single sequence served by the current, next and
+// previous endpoints, implemented by *fibonacci.Fibonacci for named sequences
+// and by defaultCursor for the Server's own sequence
+type cursor interface {
+	GetCurrent() fibonacci.Term
+	GetNext() (fibonacci.Term, error)
+	GetNextN(k int) ([]fibonacci.Term, error)
+	GetPrevious() fibonacci.Term
+	GetOverflowPolicy() fibonacci.OverflowPolicy
+	GetEpoch() uint64
+}
+
+// defaultCursor -
+// Implements cursor for the Server's own sequence through fibSeq
+type defaultCursor struct {
+	s *Server
+}
+
+// The following methods forward to fibSeq so the Server's own sequence can be
+// mocked in tests
+
+func (dc defaultCursor) GetCurrent() fibonacci.Term {
+	return fibSeq.GetCurrent(dc.s)
+}
+
+func (dc defaultCursor) GetNext() (fibonacci.Term, error) {
+	return fibSeq.GetNext(dc.s)
+}
+
+func (dc defaultCursor) GetNextN(k int) ([]fibonacci.Term, error) {
+	return fibSeq.GetNextN(dc.s, k)
+}
+
+func (dc defaultCursor) GetPrevious() fibonacci.Term {
+	return fibSeq.GetPrevious(dc.s)
+}
+
+func (dc defaultCursor) GetOverflowPolicy() fibonacci.OverflowPolicy {
+	return fibSeq.GetOverflowPolicy(dc.s)
+}
+
+func (dc defaultCursor) GetEpoch() uint64 {
+	return fibSeq.GetEpoch(dc.s)
+}
+
 // handleCurrent -
-// This function should return the current number in the Fibonacci sequence.
+// This function should return the current number in the Fibonacci sequence
+// and its index.
 func (s *Server) handleCurrent() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"current": %d}`, fibSeq.GetCurrent(s))))
+		s.serveCurrent(w, r, defaultCursor{s})
 	}
 }
 
+// serveCurrent -
+// This function writes the current number of the sequence and its index
+func (s *Server) serveCurrent(w http.ResponseWriter, r *http.Request, c cursor) {
+	payload := jsonTerm("current", c.GetCurrent())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(payload))
+}
+
 // handleNext -
 // This function should return the next number in the Fibonacci sequence and
-// progress the series.
+// its index, and progress the series.
+// With a count query parameter the series is progressed by that many numbers
+// at once, up to the server's limit, and all of them are returned.
+// The active overflow policy is reported in the X-Overflow-Policy header, and
+// an overflow rejected by the policy is answered with 409 Conflict.
 func (s *Server) handleNext() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"next": %d}`, fibSeq.GetNext(s))))
+		s.serveNext(w, r, defaultCursor{s})
+	}
+}
+
+// serveNext -
+// This function progresses the sequence and writes the numbers passed
+// through, as described for handleNext
+func (s *Server) serveNext(w http.ResponseWriter, r *http.Request, c cursor) {
+	count, e := s.parseCount(r.URL.Query())
+	if nil != e {
+		writeError(w, e)
+		return
+	}
+
+	terms, e := advance(w, c, count)
+	if nil != e {
+		writeError(w, e)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if 0 == count {
+		w.Write([]byte(jsonTerm("next", terms[0])))
+	} else {
+		w.Write([]byte(jsonTerms("next", terms)))
 	}
 }
 
+// parseCount -
+// This method reads the number of terms to advance by from the count query
+// parameter, 0 when it is missing
+func (s *Server) parseCount(query url.Values) (int, *apiError) {
+	raw := query.Get("count")
+	if 0 == len(raw) {
+		return 0, nil
+	}
+
+	k, err := strconv.ParseUint(raw, 10, 64)
+	if nil != err || 0 == k {
+		return 0, newAPIError(http.StatusBadRequest, "count must be a positive integer")
+	}
+	if k > s.maxBatch {
+		return 0, newAPIError(http.StatusUnprocessableEntity, fmt.Sprintf("count exceeds the maximum of %v", s.maxBatch))
+	}
+
+	return int(k), nil
+}
+
+// advance -
+// This function progresses the sequence by count terms, or by a single term
+// when count is 0, and reports the overflow policy in the headers. An
+// overflow rejected by the policy is returned as a 409 Conflict.
+func advance(w http.ResponseWriter, c cursor, count int) ([]fibonacci.Term, *apiError) {
+	policy := c.GetOverflowPolicy()
+
+	var terms []fibonacci.Term
+	var err error
+	if 0 == count {
+		var next fibonacci.Term
+		if next, err = c.GetNext(); nil == err {
+			terms = []fibonacci.Term{next}
+		}
+	} else {
+		terms, err = c.GetNextN(count)
+	}
+
+	w.Header().Set("X-Overflow-Policy", policy.String())
+	if fibonacci.OverflowWrap == policy {
+		w.Header().Set("X-Overflow-Epoch", strconv.FormatUint(c.GetEpoch(), 10))
+	}
+
+	if nil != err {
+		return nil, newAPIError(http.StatusConflict, err.Error(), member{"overflow_policy", policy.String()})
+	}
+
+	return terms, nil
+}
+
 // handlePrevious -
-// This function returns the previous number in the Fibonacci sequence.
+// This function returns the previous number in the Fibonacci sequence and its
+// index.
 func (s *Server) handlePrevious() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		s.servePrevious(w, r, defaultCursor{s})
+	}
+}
+
+// servePrevious -
+// This function writes the previous number of the sequence and its index
+func (s *Server) servePrevious(w http.ResponseWriter, r *http.Request, c cursor) {
+	payload := jsonTerm("previous", c.GetPrevious())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(payload))
+}
+
+// handleBack -
+// This function rewinds the Fibonacci sequence by one and returns the number
+// it is now on and its index.
+// Rewinding from the start of the sequence is answered with 409 Conflict.
+func (s *Server) handleBack() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := fibSeq.Back(s)
+		if nil != err {
+			writeError(w, newAPIError(http.StatusConflict, err.Error()))
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"previous": %d}`, fibSeq.GetPrevious(s))))
+		w.Write([]byte(jsonTerm("current", current)))
 	}
 }
 
+// Largest request body accepted by POST /sequences/:name
+const maxSequenceBodySize = 1 << 20
+
+// Room left in the body of PUT /sequence/position for the JSON around the
+// value, whose digits are bounded by the maximum index served
+const positionBodyOverhead = 1 << 10
+
+// log10(phi), the number of decimal digits F(n) gains per index
+const digitsPerIndex = 0.20898764024997873
+
+// maxValueDigits -
+// This method returns the most decimal digits of a Fibonacci number at an
+// index the server serves
+func (s *Server) maxValueDigits() int {
+	return int(float64(s.maxIndex)*digitsPerIndex) + 1
+}
+
+// positionBodySize -
+// This method returns the largest body accepted by PUT /sequence/position,
+// enough for a value at the maximum index served
+func (s *Server) positionBodySize() int64 {
+	return int64(s.maxValueDigits()) + positionBodyOverhead
+}
+
+// positionRequest -
+// The body of PUT /sequence/position, exactly one of the fields must be set.
+// The value may be given as a JSON number or a string.
+type positionRequest struct {
+	Index *uint64      `json:"index"`
+	Value *json.Number `json:"value"`
+}
+
+// handlePosition -
+// This function moves the Fibonacci sequence to the index, or to the index of
+// the Fibonacci number, given in the body and returns the number it is now on
+// and its index. As 1 is both F(1) and F(2), the value 1 moves to F(2).
+// A value that is not a Fibonacci number, or an index past the server's limit
+// or past F(93) in uint64 mode, is answered with 422 Unprocessable Entity.
+func (s *Server) handlePosition() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, e := s.seekPosition(w, r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jsonTerm("current", current)))
+	}
+}
+
+// seekPosition -
+// This method moves the sequence to the position given in the body of the
+// request, as described for handlePosition, and returns the term it is now on
+func (s *Server) seekPosition(w http.ResponseWriter, r *http.Request) (fibonacci.Term, *apiError) {
+	var req positionRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.positionBodySize()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); nil != err {
+		return fibonacci.Term{}, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+	}
+
+	var index uint64
+	switch {
+	case nil != req.Index && nil != req.Value:
+		return fibonacci.Term{}, newAPIError(http.StatusBadRequest, "index and value cannot be combined")
+	case nil != req.Index:
+		index = *req.Index
+	case nil != req.Value:
+		// Bound the work from the size of the value before parsing it, and
+		// from its magnitude before searching for its index
+		raw := req.Value.String()
+		if len(strings.TrimLeft(raw, "+-")) > s.maxValueDigits() {
+			return fibonacci.Term{}, s.errIndexExceeded()
+		}
+
+		v, ok := new(big.Int).SetString(raw, 10)
+		if !ok {
+			return fibonacci.Term{}, newAPIError(http.StatusBadRequest, "value must be an integer")
+		}
+		if fibonacci.ApproxIndex(v) > s.maxIndex {
+			return fibonacci.Term{}, s.errIndexExceeded()
+		}
+
+		if index, ok = fibonacci.IndexOf(v); !ok {
+			return fibonacci.Term{}, newAPIError(http.StatusUnprocessableEntity, "value is not a Fibonacci number")
+		}
+	default:
+		return fibonacci.Term{}, newAPIError(http.StatusBadRequest, "index or value is required")
+	}
+
+	if index > s.maxIndex {
+		return fibonacci.Term{}, s.errIndexExceeded()
+	}
+
+	current, err := fibSeq.Seek(s, index)
+	if nil != err {
+		return fibonacci.Term{}, newAPIError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	s.requestLogger(r).Info("sequence moved", "index", current.Index)
+	return current, nil
+}
+
+// errIndexExceeded -
+// This method returns the error answered for a position past the maximum
+// index served
+func (s *Server) errIndexExceeded() *apiError {
+	return newAPIError(
+		http.StatusUnprocessableEntity, fmt.Sprintf("index exceeds the maximum served of %v", s.maxIndex),
+	)
+}
+
+// handleReset -
+// This function moves the Fibonacci sequence back to F(0) in two steps. A
+// request without an X-Confirmation-Token header is answered with 428
+// Precondition Required and a single use token, which must be sent back within
+// a minute to perform the reset. The reset is recorded along with the
+// X-Requested-By header, or the client address when it is missing.
+func (s *Server) handleReset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec, e := s.confirmReset(r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		payload, _ := json.Marshal(rec)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(payload)
+	}
+}
+
+// confirmReset -
+// This method resets the sequence once the request confirms it, as described
+// for handleReset, and returns the record of the reset. A request without a
+// token is answered with an error holding a new one.
+func (s *Server) confirmReset(r *http.Request) (resetRecord, *apiError) {
+	token := r.Header.Get("X-Confirmation-Token")
+	if 0 == len(token) {
+		token, expiry, err := s.resets.issueToken()
+		if nil != err {
+			s.requestLogger(r).Error("could not issue reset confirmation token", "error", err)
+			return resetRecord{}, newAPIError(http.StatusInternalServerError, "failed to issue a confirmation token")
+		}
+
+		return resetRecord{}, newAPIError(
+			http.StatusPreconditionRequired, "reset must be confirmed with the X-Confirmation-Token header",
+			member{"token", token}, member{"expires_at", expiry.UTC().Format(time.RFC3339)},
+		)
+	}
+
+	if !s.resets.redeemToken(token) {
+		return resetRecord{}, newAPIError(http.StatusForbidden, "confirmation token is invalid or expired")
+	}
+
+	by := r.Header.Get("X-Requested-By")
+	if 0 == len(by) {
+		by = r.RemoteAddr
+	}
+
+	from, epoch := fibSeq.Reset(s)
+	rec := s.resets.record(by, from.Index, epoch)
+	s.requestLogger(r).Info("sequence reset", "by", rec.By, "from_index", rec.FromIndex, "from_epoch", rec.FromEpoch)
+
+	return rec, nil
+}
+
+// handleResetHistory -
+// This function returns the resets recorded since the server started, newest
+// first
+func (s *Server) handleResetHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := json.Marshal(struct {
+			Resets []resetRecord `json:"resets"`
+		}{Resets: s.resets.history()})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(payload)
+	}
+}
+
+// handleIndex -
+// This function returns the term of the Fibonacci sequence at the index given
+// in the path, without reading or advancing the sequence.
+// Indexes above the server's limit are answered with 422 Unprocessable Entity.
+func (s *Server) handleIndex() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, e := s.parseIndex(r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"index": %d, "value": %s}`, n, jsonNumber(fibonacci.Nth(n)))))
+	}
+}
+
+// parseIndex -
+// This method reads the index given in the path, which must not exceed the
+// server's limit
+func (s *Server) parseIndex(r *http.Request) (uint64, *apiError) {
+	raw := httprouter.ParamsFromContext(r.Context()).ByName("n")
+	n, err := strconv.ParseUint(raw, 10, 64)
+	if nil != err {
+		return 0, newAPIError(http.StatusBadRequest, "index must be a non-negative integer")
+	}
+
+	if n > s.maxIndex {
+		return 0, newAPIError(
+			http.StatusUnprocessableEntity, "index exceeds the maximum served", member{"max_index", s.maxIndex},
+		)
+	}
+
+	return n, nil
+}
+
+// handleRange -
+// This function streams the terms of the Fibonacci sequence from the index in
+// the from query parameter (0 by default) up to and including the index in to,
+// or count terms, without reading or advancing the sequence.
+// The output format is negotiated from the Accept header: a JSON array,
+// newline delimited JSON or CSV.
+func (s *Server) handleRange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, ok := negotiateRangeEncoder(r.Header.Get("Accept"))
+		if !ok {
+			writeError(w, errNotAcceptable)
+			return
+		}
+
+		from, count, e := s.parseRange(r.URL.Query())
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", enc.contentType())
+		w.WriteHeader(http.StatusOK)
+		if err := streamRange(w, r, enc, from, count); nil != err {
+			s.requestLogger(r).Warn("stopped streaming range", "from", from, "error", err)
+		}
+	}
+}
+
+// parseRange -
+// This function reads the first index and number of terms of a range from the
+// query, returning the status to answer with when they are invalid or exceed
+// the server's limits
+func (s *Server) parseRange(query url.Values) (uint64, uint64, *apiError) {
+	param := func(name string) (uint64, bool, error) {
+		raw := query.Get(name)
+		if 0 == len(raw) {
+			return 0, false, nil
+		}
+
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if nil != err {
+			return 0, true, fmt.Errorf("%v must be a non-negative integer", name)
+		}
+
+		return v, true, nil
+	}
+
+	from, _, err := param("from")
+	if nil != err {
+		return 0, 0, newAPIError(http.StatusBadRequest, err.Error())
+	}
+	to, hasTo, err := param("to")
+	if nil != err {
+		return 0, 0, newAPIError(http.StatusBadRequest, err.Error())
+	}
+	count, hasCount, err := param("count")
+	if nil != err {
+		return 0, 0, newAPIError(http.StatusBadRequest, err.Error())
+	}
+
+	switch {
+	case hasTo && hasCount:
+		return 0, 0, newAPIError(http.StatusBadRequest, "to and count cannot be combined")
+	case hasTo:
+		if to < from {
+			return 0, 0, newAPIError(http.StatusBadRequest, "to must not be below from")
+		}
+		if to > s.maxIndex {
+			return 0, 0, newAPIError(
+				http.StatusUnprocessableEntity, fmt.Sprintf("index exceeds the maximum served of %v", s.maxIndex),
+			)
+		}
+		count = to - from + 1
+	case hasCount:
+		if from > s.maxIndex || count > s.maxIndex-from+1 {
+			return 0, 0, newAPIError(
+				http.StatusUnprocessableEntity, fmt.Sprintf("index exceeds the maximum served of %v", s.maxIndex),
+			)
+		}
+	default:
+		return 0, 0, newAPIError(http.StatusBadRequest, "to or count is required")
+	}
+
+	if count > s.maxRange {
+		return 0, 0, newAPIError(
+			http.StatusUnprocessableEntity, fmt.Sprintf("range exceeds the maximum of %v terms", s.maxRange),
+		)
+	}
+
+	return from, count, nil
+}
+
 // handleHealth -
-// This function is simply a health check endpoint.
+// This function summarizes /readyz, answering {"status": "healthy"} when the
+// server is ready and {"status": "unhealthy"} with a 503 otherwise
 func (s *Server) handleHealth() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		if !s.readinessReport(r.Context()).ok() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status": "unhealthy"}`))
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status": "healthy"}`))
 	}
 }
 
-// This function is simply a wrapper to catch occuring panics and recover gracefully
-func recoveryWrapper(h http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
+// Answered when no media type of the Accept header of a range is supported
+var errNotAcceptable = newAPIError(
+	http.StatusNotAcceptable, "supported formats are application/json, application/x-ndjson and text/csv",
+)
+
+// jsonNumber -
+// This function formats a sequence value for embedding in a JSON payload, big
+// values are written as strings
+func jsonNumber(n fibonacci.Number) string {
+	b, _ := n.MarshalJSON()
+	return string(b)
+}
+
+// jsonTerm -
+// This function formats a term as a JSON object holding its index and its
+// value under the given name
+func jsonTerm(name string, t fibonacci.Term) string {
+	return fmt.Sprintf(`{"index": %d, %q: %s}`, t.Index, name, jsonNumber(t.Value))
+}
 
-		defer func() {
-			if r := recover(); nil != r {
-				log.Printf("Error occurred: %v\n, recovered", r)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
-		}()
+// jsonTerms -
+// This function formats terms as a JSON object holding them as an array of
+// index and value pairs under the given name
+func jsonTerms(name string, terms []fibonacci.Term) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `{%q: [`, name)
+	for i, t := range terms {
+		if 0 < i {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, `{"index": %d, "value": %s}`, t.Index, jsonNumber(t.Value))
+	}
+	b.WriteString("]}")
 
-		h.ServeHTTP(w, r)
-	})
+	return b.String()
 }
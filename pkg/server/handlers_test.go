@@ -1,32 +1,82 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
 )
 
 type mockFibSequence struct {
-	current  uint64
-	next     uint64
-	previous uint64
+	current  fibonacci.Term
+	next     fibonacci.Term
+	previous fibonacci.Term
+	nextErr  error
+	batch    []fibonacci.Term
+	backErr  error
+	seekErr  error
+	policy   fibonacci.OverflowPolicy
+	epoch    uint64
 }
 
-func (mfs mockFibSequence) GetCurrent(s *Server) uint64 {
+func (mfs mockFibSequence) GetCurrent(s *Server) fibonacci.Term {
 	return mfs.current
 }
 
-func (mfs mockFibSequence) GetNext(s *Server) uint64 {
-	return mfs.next
+func (mfs mockFibSequence) GetNext(s *Server) (fibonacci.Term, error) {
+	return mfs.next, mfs.nextErr
+}
+
+func (mfs mockFibSequence) GetNextN(s *Server, k int) ([]fibonacci.Term, error) {
+	if nil != mfs.nextErr {
+		return nil, mfs.nextErr
+	}
+
+	return mfs.batch[:k], nil
 }
 
-func (mfs mockFibSequence) GetPrevious(s *Server) uint64 {
+func (mfs mockFibSequence) GetPrevious(s *Server) fibonacci.Term {
 	return mfs.previous
 }
 
+func (mfs mockFibSequence) Back(s *Server) (fibonacci.Term, error) {
+	return mfs.previous, mfs.backErr
+}
+
+func (mfs mockFibSequence) Seek(s *Server, index uint64) (fibonacci.Term, error) {
+	if nil != mfs.seekErr {
+		return fibonacci.Term{}, mfs.seekErr
+	}
+
+	return fibonacci.Term{Index: index, Value: fibonacci.Nth(index)}, nil
+}
+
+func (mfs mockFibSequence) Reset(s *Server) (fibonacci.Term, uint64) {
+	return mfs.current, mfs.epoch
+}
+
+func (mfs mockFibSequence) GetOverflowPolicy(s *Server) fibonacci.OverflowPolicy {
+	return mfs.policy
+}
+
+func (mfs mockFibSequence) GetEpoch(s *Server) uint64 {
+	return mfs.epoch
+}
+
+func bigFromString(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}
+
 func TestServer_handleCurrent(t *testing.T) {
 	type fields struct {
 		mfs fibonacciSequence
@@ -46,14 +96,29 @@ func TestServer_handleCurrent(t *testing.T) {
 			name: "happy path",
 			fields: fields{
 				mfs: mockFibSequence{
-					current:  5,
-					next:     8,
-					previous: 3,
+					current:  fibonacci.Term{Index: 5, Value: fibonacci.NewNumber(5)},
+					next:     fibonacci.Term{Index: 6, Value: fibonacci.NewNumber(8)},
+					previous: fibonacci.Term{Index: 4, Value: fibonacci.NewNumber(3)},
+				},
+			},
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 5, "current": 5}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "big mode",
+			fields: fields{
+				mfs: mockFibSequence{
+					current:  fibonacci.Term{Index: 93, Value: fibonacci.NewBigNumber(bigFromString("12200160415121876738"))},
+					next:     fibonacci.Term{Index: 94, Value: fibonacci.NewBigNumber(bigFromString("19740274219868223167"))},
+					previous: fibonacci.Term{Index: 92, Value: fibonacci.NewBigNumber(bigFromString("7540113804746346429"))},
 				},
 			},
 			wants: wants{
 				contentType: "application/json",
-				payload:     fmt.Sprintf(`{"current": %d}`, 5),
+				payload:     `{"index": 93, "current": "12200160415121876738"}`,
 				statusCode:  http.StatusOK,
 			},
 		},
@@ -98,6 +163,191 @@ func TestServer_handleNext(t *testing.T) {
 		mfs fibonacciSequence
 	}
 
+	type wants struct {
+		contentType    string
+		payload        string
+		statusCode     int
+		overflowPolicy string
+		overflowEpoch  string
+	}
+	batch := []fibonacci.Term{
+		{Index: 6, Value: fibonacci.NewNumber(8)},
+		{Index: 7, Value: fibonacci.NewNumber(13)},
+		{Index: 8, Value: fibonacci.NewNumber(21)},
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		query  string
+		wants  wants
+	}{
+		{
+			name: "happy path",
+			fields: fields{
+				mfs: mockFibSequence{
+					current:  fibonacci.Term{Index: 5, Value: fibonacci.NewNumber(5)},
+					next:     fibonacci.Term{Index: 6, Value: fibonacci.NewNumber(8)},
+					previous: fibonacci.Term{Index: 4, Value: fibonacci.NewNumber(3)},
+				},
+			},
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"index": 6, "next": 8}`,
+				statusCode:     http.StatusOK,
+				overflowPolicy: "reject",
+			},
+		},
+		{
+			name: "big mode",
+			fields: fields{
+				mfs: mockFibSequence{
+					current:  fibonacci.Term{Index: 93, Value: fibonacci.NewBigNumber(bigFromString("12200160415121876738"))},
+					next:     fibonacci.Term{Index: 94, Value: fibonacci.NewBigNumber(bigFromString("19740274219868223167"))},
+					previous: fibonacci.Term{Index: 92, Value: fibonacci.NewBigNumber(bigFromString("7540113804746346429"))},
+				},
+			},
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"index": 94, "next": "19740274219868223167"}`,
+				statusCode:     http.StatusOK,
+				overflowPolicy: "reject",
+			},
+		},
+		{
+			name: "overflow rejected",
+			fields: fields{
+				mfs: mockFibSequence{
+					nextErr: fibonacci.ErrOverflow,
+					policy:  fibonacci.OverflowReject,
+				},
+			},
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"error": "next value in the sequence overflows uint64", "overflow_policy": "reject"}`,
+				statusCode:     http.StatusConflict,
+				overflowPolicy: "reject",
+			},
+		},
+		{
+			name: "overflow wrapped",
+			fields: fields{
+				mfs: mockFibSequence{
+					next:   fibonacci.Term{Index: 0, Value: fibonacci.NewNumber(0)},
+					policy: fibonacci.OverflowWrap,
+					epoch:  2,
+				},
+			},
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"index": 0, "next": 0}`,
+				statusCode:     http.StatusOK,
+				overflowPolicy: "wrap",
+				overflowEpoch:  "2",
+			},
+		},
+		{
+			name:   "batch",
+			fields: fields{mfs: mockFibSequence{batch: batch}},
+			query:  "?count=3",
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"next": [{"index": 6, "value": 8}, {"index": 7, "value": 13}, {"index": 8, "value": 21}]}`,
+				statusCode:     http.StatusOK,
+				overflowPolicy: "reject",
+			},
+		},
+		{
+			name: "batch overflow rejected",
+			fields: fields{
+				mfs: mockFibSequence{
+					nextErr: fibonacci.ErrOverflow,
+					policy:  fibonacci.OverflowReject,
+				},
+			},
+			query: "?count=3",
+			wants: wants{
+				contentType:    "application/json",
+				payload:        `{"error": "next value in the sequence overflows uint64", "overflow_policy": "reject"}`,
+				statusCode:     http.StatusConflict,
+				overflowPolicy: "reject",
+			},
+		},
+		{
+			name:   "batch of zero",
+			fields: fields{mfs: mockFibSequence{batch: batch}},
+			query:  "?count=0",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "count must be a positive integer"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "batch above limit",
+			fields: fields{mfs: mockFibSequence{batch: batch}},
+			query:  "?count=11",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "count exceeds the maximum of 10"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{maxBatch: 10}
+			fibSeq = tt.fields.mfs
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/next"+tt.query, nil)
+			rw := httptest.NewRecorder()
+
+			server.handleNext()(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.overflowPolicy, resp.Header.Get("X-Overflow-Policy")) {
+				t.Errorf(
+					"Incorrect overflow policy, wanted: %v but got: %v",
+					tt.wants.overflowPolicy, resp.Header.Get("X-Overflow-Policy"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.overflowEpoch, resp.Header.Get("X-Overflow-Epoch")) {
+				t.Errorf(
+					"Incorrect overflow epoch, wanted: %v but got: %v",
+					tt.wants.overflowEpoch, resp.Header.Get("X-Overflow-Epoch"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
+
+func TestServer_handlePrevious(t *testing.T) {
+	type fields struct {
+		mfs fibonacciSequence
+	}
+
 	type wants struct {
 		contentType string
 		payload     string
@@ -112,14 +362,29 @@ func TestServer_handleNext(t *testing.T) {
 			name: "happy path",
 			fields: fields{
 				mfs: mockFibSequence{
-					current:  5,
-					next:     8,
-					previous: 3,
+					current:  fibonacci.Term{Index: 5, Value: fibonacci.NewNumber(5)},
+					next:     fibonacci.Term{Index: 6, Value: fibonacci.NewNumber(8)},
+					previous: fibonacci.Term{Index: 4, Value: fibonacci.NewNumber(3)},
+				},
+			},
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 4, "previous": 3}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "big mode",
+			fields: fields{
+				mfs: mockFibSequence{
+					current:  fibonacci.Term{Index: 93, Value: fibonacci.NewBigNumber(bigFromString("12200160415121876738"))},
+					next:     fibonacci.Term{Index: 94, Value: fibonacci.NewBigNumber(bigFromString("19740274219868223167"))},
+					previous: fibonacci.Term{Index: 92, Value: fibonacci.NewBigNumber(bigFromString("7540113804746346429"))},
 				},
 			},
 			wants: wants{
 				contentType: "application/json",
-				payload:     fmt.Sprintf(`{"next": %d}`, 8),
+				payload:     `{"index": 92, "previous": "7540113804746346429"}`,
 				statusCode:  http.StatusOK,
 			},
 		},
@@ -131,7 +396,7 @@ func TestServer_handleNext(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/current", nil)
 			rw := httptest.NewRecorder()
 
-			server.handleNext()(rw, req)
+			server.handlePrevious()(rw, req)
 			resp := rw.Result()
 			payload, _ := ioutil.ReadAll(resp.Body)
 
@@ -159,7 +424,7 @@ func TestServer_handleNext(t *testing.T) {
 	}
 }
 
-func TestServer_handlePrevious(t *testing.T) {
+func TestServer_handleBack(t *testing.T) {
 	type fields struct {
 		mfs fibonacciSequence
 	}
@@ -178,26 +443,707 @@ func TestServer_handlePrevious(t *testing.T) {
 			name: "happy path",
 			fields: fields{
 				mfs: mockFibSequence{
-					current:  5,
-					next:     8,
-					previous: 3,
+					current:  fibonacci.Term{Index: 5, Value: fibonacci.NewNumber(5)},
+					next:     fibonacci.Term{Index: 6, Value: fibonacci.NewNumber(8)},
+					previous: fibonacci.Term{Index: 4, Value: fibonacci.NewNumber(3)},
 				},
 			},
 			wants: wants{
 				contentType: "application/json",
-				payload:     fmt.Sprintf(`{"previous": %d}`, 3),
+				payload:     `{"index": 4, "current": 3}`,
 				statusCode:  http.StatusOK,
 			},
 		},
+		{
+			name: "at start",
+			fields: fields{
+				mfs: mockFibSequence{
+					backErr: fibonacci.ErrAtStart,
+				},
+			},
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "sequence is already at its start"}`,
+				statusCode:  http.StatusConflict,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := &Server{}
 			fibSeq = tt.fields.mfs
-			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/current", nil)
+			req := httptest.NewRequest(http.MethodPost, "http://0.0.0.0:8080/back", nil)
 			rw := httptest.NewRecorder()
 
-			server.handlePrevious()(rw, req)
+			server.handleBack()(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
+
+func TestServer_handlePosition(t *testing.T) {
+	type fields struct {
+		mfs fibonacciSequence
+	}
+
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		body   string
+		wants  wants
+	}{
+		{
+			name:   "index",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"index": 12}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 12, "current": 144}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "value",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": 144}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 12, "current": 144}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "big value as string",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": "354224848179261915075"}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 100, "current": "354224848179261915075"}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "one",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": 1}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 2, "current": 1}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "not fibonacci",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": 4}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "value is not a Fibonacci number"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:   "fractional value",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": 1.5}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "value must be an integer"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "both",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"index": 12, "value": 144}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index and value cannot be combined"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "neither",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index or value is required"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "above limit",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"index": 1001}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index exceeds the maximum served of 1000"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:   "value with too many digits",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": "` + strings.Repeat("9", 300) + `"}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index exceeds the maximum served of 1000"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:   "value above limit",
+			fields: fields{mfs: mockFibSequence{}},
+			body:   `{"value": "` + strings.Repeat("9", 209) + `"}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index exceeds the maximum served of 1000"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:   "past uint64",
+			fields: fields{mfs: mockFibSequence{seekErr: fibonacci.ErrIndexOutOfRange}},
+			body:   `{"index": 94}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index does not fit in uint64 mode"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{maxIndex: 1000}
+			fibSeq = tt.fields.mfs
+			req := httptest.NewRequest(http.MethodPut, "http://0.0.0.0:8080/sequence/position", strings.NewReader(tt.body))
+			rw := httptest.NewRecorder()
+
+			server.handlePosition()(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
+
+func TestServer_handlePosition_oversized(t *testing.T) {
+	server := &Server{maxIndex: defaultMaxIndex}
+	fibSeq = mockFibSequence{}
+
+	tests := []struct {
+		name       string
+		digits     int
+		statusCode int
+	}{
+		{name: "too many digits", digits: server.maxValueDigits() + 1, statusCode: http.StatusUnprocessableEntity},
+		{name: "body too large", digits: 1 << 20, statusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"value": "` + strings.Repeat("7", tt.digits) + `"}`
+			req := httptest.NewRequest(http.MethodPut, "http://0.0.0.0:8080/sequence/position", strings.NewReader(body))
+			rw := httptest.NewRecorder()
+
+			start := time.Now()
+			server.handlePosition()(rw, req)
+			elapsed := time.Since(start)
+
+			if tt.statusCode != rw.Code {
+				t.Errorf("Incorrect status code written, wanted: %v but got: %v", tt.statusCode, rw.Code)
+			}
+			// Searching for the index of such a value takes seconds
+			if time.Second < elapsed {
+				t.Errorf("Oversized value took %v to reject", elapsed)
+			}
+		})
+	}
+}
+
+func TestServer_handleReset(t *testing.T) {
+	at := time.Date(2022, 1, 13, 12, 0, 0, 0, time.UTC)
+	mfs := mockFibSequence{
+		current: fibonacci.Term{Index: 12, Value: fibonacci.NewNumber(144)},
+		epoch:   1,
+	}
+
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name    string
+		token   string
+		by      string
+		elapsed time.Duration
+		wants   wants
+	}{
+		{
+			name:  "happy path",
+			token: "issued",
+			by:    "ops",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"by":"ops","at":"2022-01-13T12:00:00Z","from_index":12,"from_epoch":1}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:  "anonymous",
+			token: "issued",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"by":"192.0.2.1:1234","at":"2022-01-13T12:00:00Z","from_index":12,"from_epoch":1}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:  "unknown token",
+			token: "guessed",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "confirmation token is invalid or expired"}`,
+				statusCode:  http.StatusForbidden,
+			},
+		},
+		{
+			name:    "expired token",
+			token:   "issued",
+			elapsed: 2 * resetTokenTTL,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "confirmation token is invalid or expired"}`,
+				statusCode:  http.StatusForbidden,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := at
+			server := &Server{resets: newResetLog(defaultResetHistory)}
+			server.resets.now = func() time.Time { return now }
+			fibSeq = mfs
+
+			// Ask for a token first
+			req := httptest.NewRequest(http.MethodPost, "http://0.0.0.0:8080/sequence/reset", nil)
+			rw := httptest.NewRecorder()
+			server.handleReset()(rw, req)
+
+			var issued struct {
+				Token     string `json:"token"`
+				ExpiresAt string `json:"expires_at"`
+			}
+			if http.StatusPreconditionRequired != rw.Code {
+				t.Fatalf("Incorrect status code without a token, wanted: %v but got: %v", http.StatusPreconditionRequired, rw.Code)
+			}
+			if err := json.Unmarshal(rw.Body.Bytes(), &issued); nil != err || 0 == len(issued.Token) {
+				t.Fatalf("No token issued: %s", rw.Body.String())
+			}
+			if want := "2022-01-13T12:01:00Z"; want != issued.ExpiresAt {
+				t.Errorf("Incorrect token expiry, wanted: %v but got: %v", want, issued.ExpiresAt)
+			}
+
+			token := tt.token
+			if "issued" == token {
+				token = issued.Token
+			}
+			now = now.Add(tt.elapsed)
+
+			req = httptest.NewRequest(http.MethodPost, "http://0.0.0.0:8080/sequence/reset", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Confirmation-Token", token)
+			if 0 < len(tt.by) {
+				req.Header.Set("X-Requested-By", tt.by)
+			}
+			rw = httptest.NewRecorder()
+
+			server.handleReset()(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+
+			// Tokens are single use
+			rw = httptest.NewRecorder()
+			server.handleReset()(rw, req)
+			if http.StatusForbidden != rw.Code {
+				t.Errorf("Token was accepted twice, got status: %v", rw.Code)
+			}
+		})
+	}
+}
+
+func TestServer_handleResetHistory(t *testing.T) {
+	at := time.Date(2022, 1, 13, 12, 0, 0, 0, time.UTC)
+
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name    string
+		records []resetRecord
+		wants   wants
+	}{
+		{
+			name: "empty",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"resets":[]}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "newest first",
+			records: []resetRecord{
+				{By: "alice", FromIndex: 5},
+				{By: "bob", FromIndex: 7, FromEpoch: 2},
+			},
+			wants: wants{
+				contentType: "application/json",
+				payload: `{"resets":[` +
+					`{"by":"bob","at":"2022-01-13T12:00:00Z","from_index":7,"from_epoch":2},` +
+					`{"by":"alice","at":"2022-01-13T12:00:00Z","from_index":5,"from_epoch":0}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{resets: newResetLog(defaultResetHistory)}
+			server.resets.now = func() time.Time { return at }
+			for _, rec := range tt.records {
+				server.resets.record(rec.By, rec.FromIndex, rec.FromEpoch)
+			}
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/sequence/resets", nil)
+			rw := httptest.NewRecorder()
+
+			server.handleResetHistory()(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
+
+func TestServer_handleIndex(t *testing.T) {
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name  string
+		path  string
+		wants wants
+	}{
+		{
+			name: "happy path",
+			path: "/fibonacci/12",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 12, "value": 144}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "start",
+			path: "/fibonacci/0",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 0, "value": 0}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "big",
+			path: "/fibonacci/100",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"index": 100, "value": "354224848179261915075"}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "not a number",
+			path: "/fibonacci/twelve",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index must be a non-negative integer"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "negative",
+			path: "/fibonacci/-1",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index must be a non-negative integer"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "above limit",
+			path: "/fibonacci/1001",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index exceeds the maximum served", "max_index": 1000}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The sequence must never be consulted
+			fibSeq = nil
+			server := &Server{maxIndex: 1000}
+			router := httprouter.New()
+			router.HandlerFunc(http.MethodGet, "/fibonacci/:n", server.handleIndex())
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080"+tt.path, nil)
+			rw := httptest.NewRecorder()
+
+			router.ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
+
+func TestServer_handleRange(t *testing.T) {
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		wants  wants
+	}{
+		{
+			name: "json",
+			path: "/fibonacci?from=10&to=12",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `[{"index": 10, "value": 55}, {"index": 11, "value": 89}, {"index": 12, "value": 144}]`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "ndjson",
+			path:   "/fibonacci?count=3",
+			accept: "application/x-ndjson",
+			wants: wants{
+				contentType: "application/x-ndjson",
+				payload:     "{\"index\": 0, \"value\": 0}\n{\"index\": 1, \"value\": 1}\n{\"index\": 2, \"value\": 1}\n",
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "csv across uint64 limit",
+			path:   "/fibonacci?from=93&count=2",
+			accept: "text/csv;q=0.9, application/xml",
+			wants: wants{
+				contentType: "text/csv",
+				payload:     "index,value\n93,12200160415121876738\n94,19740274219868223167\n",
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name: "empty",
+			path: "/fibonacci?from=5&count=0",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `[]`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "unsupported format",
+			path:   "/fibonacci?count=3",
+			accept: "application/xml",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "supported formats are application/json, application/x-ndjson and text/csv"}`,
+				statusCode:  http.StatusNotAcceptable,
+			},
+		},
+		{
+			name: "missing end",
+			path: "/fibonacci?from=3",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "to or count is required"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "both ends",
+			path: "/fibonacci?to=3&count=3",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "to and count cannot be combined"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "reversed",
+			path: "/fibonacci?from=5&to=3",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "to must not be below from"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid",
+			path: "/fibonacci?from=-1&count=3",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "from must be a non-negative integer"}`,
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "above index limit",
+			path: "/fibonacci?from=990&count=20",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "index exceeds the maximum served of 1000"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name: "above range limit",
+			path: "/fibonacci?from=0&to=100",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "range exceeds the maximum of 50 terms"}`,
+				statusCode:  http.StatusUnprocessableEntity,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The sequence must never be consulted
+			fibSeq = nil
+			server := &Server{maxIndex: 1000, maxRange: 50}
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080"+tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rw := httptest.NewRecorder()
+
+			server.handleRange()(rw, req)
 			resp := rw.Result()
 			payload, _ := ioutil.ReadAll(resp.Body)
 
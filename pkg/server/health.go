@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Longest a single check may take unless HEALTH_CHECK_TIMEOUT says
+	// otherwise, a check still running is reported as failing
+	defaultCheckTimeout = 2 * time.Second
+
+	// Age of the oldest unwritten change past which the server is no longer
+	// ready, unless READY_MAX_PERSISTENCE_LAG says otherwise
+	defaultMaxPersistenceLag = 30 * time.Second
+)
+
+// Checker -
+// Reports on something the server depends on, returning an error when it is
+// unhealthy. The context is cancelled once the check has timed out.
+type Checker func(ctx context.Context) error
+
+// healthCheck -
+// A named Checker
+type healthCheck struct {
+	name  string
+	check Checker
+}
+
+// checkResult -
+// The outcome of a check, as listed by /livez and /readyz
+type checkResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthReport -
+// The body of /livez and /readyz, Status is "ok" only when every check is
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+// ok -
+// This method reports whether every check passed
+func (hr healthReport) ok() bool {
+	return "ok" == hr.Status
+}
+
+// AddLivenessCheck -
+// This method adds a check to /livez, and so to /readyz as well. A failing
+// liveness check means the server should be restarted.
+func (s *Server) AddLivenessCheck(name string, c Checker) {
+	s.liveness = append(s.liveness, healthCheck{name: name, check: c})
+}
+
+// AddReadinessCheck -
+// This method adds a check to /readyz. A failing readiness check means the
+// server should not be sent traffic until it recovers.
+func (s *Server) AddReadinessCheck(name string, c Checker) {
+	s.readiness = append(s.readiness, healthCheck{name: name, check: c})
+}
+
+// addDefaultChecks -
+// This method registers the checks of the sequence and its persistence: the
+// lock of the sequence can be taken for liveness, and for readiness the state
+// store answers and persistence is not lagging behind. Whether the state was
+// restored is not checked, a state that cannot be loaded fails startup before
+// there is a server to ask.
+func (s *Server) addDefaultChecks() {
+	s.AddLivenessCheck("sequence", s.checkSequence)
+	s.AddReadinessCheck("store", s.checkStore)
+	s.AddReadinessCheck("persistence", s.checkPersistence)
+}
+
+// checkSequence -
+// This method reads the current term, which blocks when the lock of the
+// sequence is stuck
+func (s *Server) checkSequence(ctx context.Context) error {
+	fibSeq.GetCurrent(s)
+	return nil
+}
+
+// checkStore -
+// This method pings the state store
+func (s *Server) checkStore(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+// checkPersistence -
+// This method fails when the oldest change not yet written is older than the
+// lag allowed
+func (s *Server) checkPersistence(ctx context.Context) error {
+	lag := s.fibSequence.GetPersistenceStats().Lag
+	if lag > s.maxPersistenceLag {
+		return fmt.Errorf("persistence is %v behind, more than the %v allowed", lag, s.maxPersistenceLag)
+	}
+
+	return nil
+}
+
+// runChecks -
+// This method runs the checks concurrently, each limited to the check
+// timeout, and reports on them in the order given
+func (s *Server) runChecks(ctx context.Context, checks []healthCheck) healthReport {
+	timeout := s.checkTimeout
+	if 0 >= timeout {
+		timeout = defaultCheckTimeout
+	}
+
+	report := healthReport{Status: "ok", Checks: make([]checkResult, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			report.Checks[i] = runCheck(checkCtx, hc)
+		}(i, hc)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if "ok" != result.Status {
+			report.Status = "failing"
+		}
+	}
+
+	return report
+}
+
+// runCheck -
+// This function runs a check until it returns or its context is done. A check
+// that ignores its context is left running in the background, and a panic is
+// reported as a failure rather than taking the server down.
+func runCheck(ctx context.Context, hc healthCheck) checkResult {
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); nil != p {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- hc.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New("check timed out")
+		}
+	}
+
+	result := checkResult{
+		Name:      hc.name,
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if nil != err {
+		result.Status = "failing"
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// readinessReport -
+// This method runs the liveness and readiness checks, a server that is not
+// live is not ready either
+func (s *Server) readinessReport(ctx context.Context) healthReport {
+	checks := make([]healthCheck, 0, len(s.liveness)+len(s.readiness))
+	checks = append(checks, s.liveness...)
+	checks = append(checks, s.readiness...)
+
+	return s.runChecks(ctx, checks)
+}
+
+// handleLivez -
+// This function reports whether the server is alive, answering 200 when every
+// liveness check passes and 503 otherwise, along with the result and latency
+// of each check
+func (s *Server) handleLivez() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, s.runChecks(r.Context(), s.liveness))
+	}
+}
+
+// handleReadyz -
+// This function reports whether the server is ready to be sent traffic, see
+// handleLivez
+func (s *Server) handleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, s.readinessReport(r.Context()))
+	}
+}
+
+// writeHealthReport -
+// This function answers with the report, 503 when a check failed
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if !report.ok() {
+		status = http.StatusServiceUnavailable
+	}
+
+	payload, _ := json.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
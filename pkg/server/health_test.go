@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+// unreachableStore -
+// A state store that cannot be reached, so nothing can be loaded, written or
+// pinged
+type unreachableStore struct {
+	fibonacci.StateStore
+}
+
+var errUnreachable = errors.New("connection refused")
+
+func (us unreachableStore) Load(ctx context.Context, key string) (fibonacci.State, error) {
+	return fibonacci.State{}, errUnreachable
+}
+
+func (us unreachableStore) CompareAndSwap(ctx context.Context, key string, st fibonacci.State) (bool, error) {
+	return false, errUnreachable
+}
+
+func (us unreachableStore) Ping(ctx context.Context) error {
+	return errUnreachable
+}
+
+func TestServer_runChecks(t *testing.T) {
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	server := &Server{checkTimeout: 20 * time.Millisecond}
+	report := server.runChecks(context.Background(), []healthCheck{
+		{name: "passing", check: func(ctx context.Context) error { return nil }},
+		{name: "failing", check: func(ctx context.Context) error { return errUnreachable }},
+		{name: "stuck", check: func(ctx context.Context) error { <-stuck; return nil }},
+		{name: "panicking", check: func(ctx context.Context) error { panic("boom") }},
+	})
+
+	if "failing" != report.Status {
+		t.Errorf("Incorrect status, wanted: failing but got: %v", report.Status)
+	}
+
+	want := []checkResult{
+		{Name: "passing", Status: "ok"},
+		{Name: "failing", Status: "failing", Error: "connection refused"},
+		{Name: "stuck", Status: "failing", Error: "check timed out"},
+		{Name: "panicking", Status: "failing", Error: "check panicked: boom"},
+	}
+	if len(want) != len(report.Checks) {
+		t.Fatalf("Incorrect checks, wanted: %v but got: %v", want, report.Checks)
+	}
+	for i, got := range report.Checks {
+		if 0 > got.LatencyMS {
+			t.Errorf("Negative latency for %v: %v", got.Name, got.LatencyMS)
+		}
+		got.LatencyMS = 0
+		if want[i] != got {
+			t.Errorf("Incorrect check, wanted: %+v but got: %+v", want[i], got)
+		}
+	}
+	if stuck := report.Checks[2].LatencyMS; 20 > stuck {
+		t.Errorf("Stuck check reported after %vms, before its timeout", stuck)
+	}
+}
+
+func TestServer_health(t *testing.T) {
+	type wants struct {
+		statusCode int
+		status     string
+		checks     map[string]string
+	}
+	tests := []struct {
+		name  string
+		store fibonacci.StateStore
+		path  string
+		wants wants
+	}{
+		{
+			name:  "live",
+			store: fibonacci.NewMemoryStore(),
+			path:  "/livez",
+			wants: wants{
+				statusCode: http.StatusOK,
+				status:     "ok",
+				checks:     map[string]string{"sequence": "ok"},
+			},
+		},
+		{
+			name:  "ready",
+			store: fibonacci.NewMemoryStore(),
+			path:  "/readyz",
+			wants: wants{
+				statusCode: http.StatusOK,
+				status:     "ok",
+				checks: map[string]string{
+					"sequence": "ok", "store": "ok", "persistence": "ok",
+				},
+			},
+		},
+		{
+			name:  "live without the store",
+			store: unreachableStore{fibonacci.NewMemoryStore()},
+			path:  "/livez",
+			wants: wants{
+				statusCode: http.StatusOK,
+				status:     "ok",
+				checks:     map[string]string{"sequence": "ok"},
+			},
+		},
+		{
+			name:  "not ready without the store",
+			store: unreachableStore{fibonacci.NewMemoryStore()},
+			path:  "/readyz",
+			wants: wants{
+				statusCode: http.StatusServiceUnavailable,
+				status:     "failing",
+				checks: map[string]string{
+					"sequence": "ok", "store": "failing", "persistence": "ok",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The sequence starts while the store is reachable, which it has
+			// to be for the state to be loaded
+			fib, err := fibonacci.InitializeFibonacci(fibonacci.NewMemoryStore(), fibonacci.Options{})
+			if nil != err {
+				t.Fatalf("InitializeFibonacci() error = %v", err)
+			}
+			defer fib.Close()
+
+			server := &Server{
+				fibSequence:       fib,
+				router:            httprouter.New(),
+				store:             tt.store,
+				maxPersistenceLag: defaultMaxPersistenceLag,
+			}
+			server.addDefaultChecks()
+			server.routes()
+
+			fibSeq = fibonacciSeq{}
+			rw := httptest.NewRecorder()
+			server.GetRouter().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if tt.wants.statusCode != resp.StatusCode {
+				t.Errorf("Incorrect status code written, wanted: %v but got: %v", tt.wants.statusCode, resp.StatusCode)
+			}
+			if "no-store" != resp.Header.Get("Cache-Control") {
+				t.Errorf("Incorrect Cache-Control, wanted: no-store but got: %v", resp.Header.Get("Cache-Control"))
+			}
+
+			var report healthReport
+			if err := json.Unmarshal(payload, &report); nil != err {
+				t.Fatalf("Invalid report %s: %v", payload, err)
+			}
+			if tt.wants.status != report.Status {
+				t.Errorf("Incorrect status, wanted: %v but got: %v", tt.wants.status, report.Status)
+			}
+
+			checks := map[string]string{}
+			for _, c := range report.Checks {
+				checks[c.Name] = c.Status
+				if ("ok" == c.Status) != (0 == len(c.Error)) {
+					t.Errorf("Check %v is %v with error %q", c.Name, c.Status, c.Error)
+				}
+			}
+			if len(tt.wants.checks) != len(checks) {
+				t.Errorf("Incorrect checks, wanted: %v but got: %v", tt.wants.checks, checks)
+			}
+			for name, status := range tt.wants.checks {
+				if status != checks[name] {
+					t.Errorf("Incorrect status of %v, wanted: %v but got: %v", name, status, checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_handleHealth_notReady(t *testing.T) {
+	server := &Server{router: httprouter.New()}
+	server.AddReadinessCheck("store", func(ctx context.Context) error { return errUnreachable })
+	server.routes()
+
+	tests := []struct {
+		path        string
+		contentType string
+		payload     string
+	}{
+		{
+			path:        "/health",
+			contentType: "application/json",
+			payload:     `{"status": "unhealthy"}`,
+		},
+		{
+			path:        "/v1/health",
+			contentType: "application/problem+json",
+			payload:     `{"checks":[{"name":"store","status":"failing","latency_ms":0,"error":"connection refused"}],"detail":"server is not ready","instance":"/v1/health","status":503,"title":"Service Unavailable","type":"about:blank"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			server.GetRouter().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if http.StatusServiceUnavailable != resp.StatusCode {
+				t.Errorf("Incorrect status code written, wanted: %v but got: %v", http.StatusServiceUnavailable, resp.StatusCode)
+			}
+			if tt.contentType != resp.Header.Get("Content-Type") {
+				t.Errorf("Incorrect content type, wanted: %v but got: %v", tt.contentType, resp.Header.Get("Content-Type"))
+			}
+
+			// The latency varies, so it is zeroed before comparing
+			var got, want interface{}
+			json.Unmarshal(payload, &got)
+			json.Unmarshal([]byte(tt.payload), &want)
+			if m, ok := got.(map[string]interface{}); ok {
+				if checks, ok := m["checks"].([]interface{}); ok {
+					checks[0].(map[string]interface{})["latency_ms"] = float64(0)
+				}
+			}
+			if !jsonEqual(got, want) {
+				t.Errorf("Incorrect payload, wanted: %v but got: %s", tt.payload, payload)
+			}
+		})
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ra, _ := json.Marshal(a)
+	rb, _ := json.Marshal(b)
+	return string(ra) == string(rb)
+}
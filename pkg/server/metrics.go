@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds in seconds of the buckets of the request latency histogram,
+// the defaults of the Prometheus client libraries
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Route label of the requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// routeKey -
+// Identifies the latency histogram of a route
+type routeKey struct {
+	route  string
+	method string
+}
+
+// requestKey -
+// Identifies the request counter of a route and status
+type requestKey struct {
+	routeKey
+	status int
+}
+
+// histogram -
+// Counts observations per bucket of latencyBuckets, the last count is for
+// observations above every bucket
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// httpMetrics -
+// Counts the requests answered and their latencies per route. The route is
+// the pattern it was registered with rather than the path requested, so the
+// number of series stays bounded. A nil httpMetrics records nothing.
+type httpMetrics struct {
+	mutex     sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[routeKey]*histogram
+}
+
+// newHTTPMetrics -
+// This function creates an empty httpMetrics
+func newHTTPMetrics() *httpMetrics {
+	return &httpMetrics{
+		requests:  map[requestKey]uint64{},
+		latencies: map[routeKey]*histogram{},
+	}
+}
+
+// observe -
+// This method records a request to the route answered with status after d
+func (m *httpMetrics) observe(route, method string, status int, d time.Duration) {
+	if nil == m {
+		return
+	}
+
+	key := routeKey{route: route, method: method}
+	seconds := d.Seconds()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[requestKey{routeKey: key, status: status}]++
+
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latencies[key] = h
+	}
+	h.counts[sort.SearchFloat64s(latencyBuckets, seconds)]++
+	h.sum += seconds
+}
+
+// write -
+// This method writes the request counters and latency histograms in the
+// Prometheus text exposition format, sorted so the output is stable
+func (m *httpMetrics) write(w io.Writer) {
+	if nil == m {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	requests := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.routeKey != b.routeKey {
+			return a.routeKey.less(b.routeKey)
+		}
+		return a.status < b.status
+	})
+
+	writeHeader(w, "http_requests_total", "counter", "Requests answered, by route, method and status.")
+	for _, key := range requests {
+		fmt.Fprintf(
+			w, "http_requests_total{route=%s,method=%s,status=\"%d\"} %d\n",
+			labelValue(key.route), labelValue(key.method), key.status, m.requests[key],
+		)
+	}
+
+	routes := make([]routeKey, 0, len(m.latencies))
+	for key := range m.latencies {
+		routes = append(routes, key)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+
+	writeHeader(w, "http_request_duration_seconds", "histogram", "Time taken to answer requests, by route and method.")
+	for _, key := range routes {
+		h := m.latencies[key]
+		labels := fmt.Sprintf("route=%s,method=%s", labelValue(key.route), labelValue(key.method))
+
+		cumulative := uint64(0)
+		for i, count := range h.counts {
+			cumulative += count
+
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = formatFloat(latencyBuckets[i])
+			}
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, le, cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, cumulative)
+	}
+}
+
+// less -
+// This method orders route keys by route, then method
+func (rk routeKey) less(other routeKey) bool {
+	if rk.route != other.route {
+		return rk.route < other.route
+	}
+	return rk.method < other.method
+}
+
+// withMetrics -
+// This method returns a middleware recording the requests to the route in the
+// server's metrics
+func (s *Server) withMetrics(route string) middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := wrapWriter(w)
+
+			defer func() {
+				status := sw.status
+				if 0 == status {
+					status = http.StatusOK
+				}
+				s.metrics.observe(route, r.Method, status, time.Since(start))
+			}()
+
+			h.ServeHTTP(sw, r)
+		}
+	}
+}
+
+// handleMetrics -
+// This function returns the metrics of the server in the Prometheus text
+// exposition format: requests and their latencies per route, panics
+// recovered, the steps each named sequence saturated on, and the position,
+// lock contention and persistence of the sequence.
+func (s *Server) handleMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		buf := bufio.NewWriter(w)
+		defer buf.Flush()
+
+		s.metrics.write(buf)
+
+		writeHeader(buf, "http_panics_total", "counter", "Handler panics recovered.")
+		fmt.Fprintf(buf, "http_panics_total %d\n", s.PanicCount())
+
+		if nil != s.sequences {
+			stats := s.sequences.Stats()
+			names := make([]string, 0, len(stats))
+			for name := range stats {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			writeHeader(
+				buf, "fibonacci_named_sequence_saturated_total", "counter",
+				"Requests for the next term of a named sequence that stayed on its last term after overflowing.",
+			)
+			for _, name := range names {
+				fmt.Fprintf(
+					buf, "fibonacci_named_sequence_saturated_total{sequence=%s} %d\n",
+					labelValue(name), stats[name].Saturated,
+				)
+			}
+		}
+
+		if nil == s.fibSequence {
+			return
+		}
+
+		seq := s.fibSequence.GetSequenceStats()
+		writeHeader(buf, "fibonacci_sequence_index", "gauge", "Index of the term the sequence is on.")
+		fmt.Fprintf(buf, "fibonacci_sequence_index %d\n", seq.Index)
+		writeHeader(buf, "fibonacci_sequence_advances_total", "counter", "Terms the sequence has advanced by.")
+		fmt.Fprintf(buf, "fibonacci_sequence_advances_total %d\n", seq.Advances)
+		writeHeader(
+			buf, "fibonacci_sequence_saturated_total", "counter",
+			"Requests for the next term that stayed on the last term after overflowing.",
+		)
+		fmt.Fprintf(buf, "fibonacci_sequence_saturated_total %d\n", seq.Saturated)
+		writeHeader(buf, "fibonacci_lock_wait_seconds", "summary", "Time spent waiting for the lock of the sequence.")
+		fmt.Fprintf(buf, "fibonacci_lock_wait_seconds_sum %s\n", formatFloat(seq.LockWait.Seconds()))
+		fmt.Fprintf(buf, "fibonacci_lock_wait_seconds_count %d\n", seq.LockAcquisitions)
+
+		st := s.fibSequence.GetPersistenceStats()
+		writeHeader(buf, "fibonacci_persistence_writes_total", "counter", "Writes of the sequence state, by result.")
+		fmt.Fprintf(buf, "fibonacci_persistence_writes_total{result=\"coalesced\"} %d\n", st.Coalesced)
+		fmt.Fprintf(buf, "fibonacci_persistence_writes_total{result=\"failed\"} %d\n", st.Failed)
+		fmt.Fprintf(buf, "fibonacci_persistence_writes_total{result=\"stale\"} %d\n", st.Stale)
+		fmt.Fprintf(buf, "fibonacci_persistence_writes_total{result=\"written\"} %d\n", st.Written)
+		writeHeader(buf, "fibonacci_persistence_queue_depth", "gauge", "States waiting to be written.")
+		fmt.Fprintf(buf, "fibonacci_persistence_queue_depth %d\n", st.QueueDepth)
+		writeHeader(buf, "fibonacci_persistence_lag_seconds", "gauge", "Age of the oldest change not yet written.")
+		fmt.Fprintf(buf, "fibonacci_persistence_lag_seconds %s\n", formatFloat(st.Lag.Seconds()))
+
+		// Left out until the first successful write, there is no age to report
+		if !st.LastWritten.IsZero() {
+			writeHeader(
+				buf, "fibonacci_persistence_last_success_age_seconds", "gauge",
+				"Time since the state was last written successfully.",
+			)
+			fmt.Fprintf(
+				buf, "fibonacci_persistence_last_success_age_seconds %s\n",
+				formatFloat(s.clock().Sub(st.LastWritten).Seconds()),
+			)
+		}
+	}
+}
+
+// writeHeader -
+// This function writes the HELP and TYPE lines of a metric
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelValue -
+// This function quotes a label value, escaping backslashes, quotes and
+// newlines as the exposition format requires
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// formatFloat -
+// This function formats a sample value the way Prometheus does
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
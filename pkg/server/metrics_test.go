@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+func Test_httpMetrics_write(t *testing.T) {
+	m := newHTTPMetrics()
+	m.observe("/v1/next", http.MethodGet, http.StatusOK, 3*time.Millisecond)
+	m.observe("/v1/next", http.MethodGet, http.StatusConflict, 20*time.Millisecond)
+	m.observe("/current", http.MethodGet, http.StatusOK, 30*time.Second)
+
+	var buf bytes.Buffer
+	m.write(&buf)
+
+	want := `# HELP http_requests_total Requests answered, by route, method and status.
+# TYPE http_requests_total counter
+http_requests_total{route="/current",method="GET",status="200"} 1
+http_requests_total{route="/v1/next",method="GET",status="200"} 1
+http_requests_total{route="/v1/next",method="GET",status="409"} 1
+# HELP http_request_duration_seconds Time taken to answer requests, by route and method.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.005"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.01"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.025"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.05"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.1"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.25"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="0.5"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="1"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="2.5"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="5"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="10"} 0
+http_request_duration_seconds_bucket{route="/current",method="GET",le="+Inf"} 1
+http_request_duration_seconds_sum{route="/current",method="GET"} 30
+http_request_duration_seconds_count{route="/current",method="GET"} 1
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.005"} 1
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.01"} 1
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.025"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.05"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.1"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.25"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="0.5"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="1"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="2.5"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="5"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="10"} 2
+http_request_duration_seconds_bucket{route="/v1/next",method="GET",le="+Inf"} 2
+http_request_duration_seconds_sum{route="/v1/next",method="GET"} 0.023
+http_request_duration_seconds_count{route="/v1/next",method="GET"} 2
+`
+	if got := buf.String(); want != got {
+		t.Errorf("httpMetrics.write() = %v, want %v", got, want)
+	}
+}
+
+func Test_labelValue(t *testing.T) {
+	if got, want := labelValue("a\"b\\c\nd"), `"a\"b\\c\nd"`; want != got {
+		t.Errorf("labelValue() = %v, want %v", got, want)
+	}
+}
+
+func TestServer_handleMetrics(t *testing.T) {
+	fib, err := fibonacci.InitializeFibonacci(fibonacci.NewMemoryStore(), fibonacci.Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+
+	server := &Server{
+		fibSequence:    fib,
+		router:         httprouter.New(),
+		maxBatch:       10,
+		middlewareOpts: middlewareOptions{metrics: true},
+		metrics:        newHTTPMetrics(),
+	}
+	server.routes()
+
+	fibSeq = fibonacciSeq{}
+	for _, path := range []string{"/v1/next?count=3", "/v1/sequences/alpha/current", "/missing"} {
+		server.GetRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	// The last write is only reported once the worker has made it
+	fib.Close()
+
+	rw := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	resp := rw.Result()
+	payload, _ := ioutil.ReadAll(resp.Body)
+
+	if want := "text/plain; version=0.0.4; charset=utf-8"; want != resp.Header.Get("Content-Type") {
+		t.Errorf("Incorrect content type, wanted: %v but got: %v", want, resp.Header.Get("Content-Type"))
+	}
+
+	for _, want := range []string{
+		`http_requests_total{route="/v1/next",method="GET",status="200"} 1`,
+		`http_requests_total{route="/v1/sequences/:name/current",method="GET",status="500"} 1`,
+		`http_requests_total{route="unmatched",method="GET",status="404"} 1`,
+		`http_request_duration_seconds_count{route="/v1/next",method="GET"} 1`,
+		"http_panics_total 1\n",
+		"fibonacci_sequence_index 3\n",
+		"fibonacci_sequence_advances_total 3\n",
+		"fibonacci_sequence_saturated_total 0\n",
+		"fibonacci_lock_wait_seconds_count ",
+		`fibonacci_persistence_writes_total{result="written"} 1`,
+		`fibonacci_persistence_writes_total{result="failed"} 0`,
+		"fibonacci_persistence_queue_depth 0\n",
+		"fibonacci_persistence_last_success_age_seconds ",
+	} {
+		if !strings.Contains(string(payload), want) {
+			t.Errorf("Metrics are missing %q:\n%s", want, payload)
+		}
+	}
+}
+
+func TestServer_handleMetrics_namedSequences(t *testing.T) {
+	server := &Server{
+		router:    httprouter.New(),
+		metrics:   newHTTPMetrics(),
+		sequences: fibonacci.NewRegistry(fibonacci.NewMemoryStore(), fibonacci.Options{OverflowPolicy: fibonacci.OverflowSaturate}, 2),
+	}
+	server.routes()
+	defer server.sequences.Close()
+
+	// a(n) = 2a(n-1) from 1 saturates at 2^63, 2 steps short of 65
+	doubling, err := server.sequences.Create("doubling", fibonacci.RecurrenceSpec{
+		Coefficients: []int64{2}, Seeds: []*big.Int{big.NewInt(1)},
+	})
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	doubling.GetNextN(65)
+	if _, err := server.sequences.Create("fib", fibonacci.RecurrenceSpec{}); nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	payload := rw.Body.String()
+
+	for _, want := range []string{
+		`fibonacci_named_sequence_saturated_total{sequence="doubling"} 2`,
+		`fibonacci_named_sequence_saturated_total{sequence="fib"} 0`,
+	} {
+		if !strings.Contains(payload, want) {
+			t.Errorf("Metrics are missing %q:\n%s", want, payload)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+// middleware -
+// Wraps a handler with behaviour shared by every route
+type middleware func(http.HandlerFunc) http.HandlerFunc
+
+// chain -
+// This function composes middlewares into one, the first given runs
+// outermost
+func chain(mws ...middleware) middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// middlewareOptions -
+// Toggles the optional middlewares, read from the MIDDLEWARE_REQUEST_ID,
+// MIDDLEWARE_ACCESS_LOG, MIDDLEWARE_RESPONSE_TIME and MIDDLEWARE_METRICS
+// environment variables. Panics are always recovered.
+type middlewareOptions struct {
+	requestID    bool
+	accessLog    bool
+	responseTime bool
+	metrics      bool
+}
+
+// middleware -
+// This method returns the chain run for the given route: request IDs, access
+// logs, response times, metrics and panic recovery, in that order, leaving out
+// the middlewares that are turned off
+func (s *Server) middleware(route string) middleware {
+	var mws []middleware
+	if s.middlewareOpts.requestID {
+		mws = append(mws, s.withRequestID)
+	}
+	if s.middlewareOpts.accessLog {
+		mws = append(mws, s.withAccessLog)
+	}
+	if s.middlewareOpts.responseTime {
+		mws = append(mws, withResponseTime)
+	}
+	if s.middlewareOpts.metrics {
+		mws = append(mws, s.withMetrics(route))
+	}
+	mws = append(mws, s.recoveryWrapper)
+
+	return chain(mws...)
+}
+
+// statusWriter -
+// Wraps the ResponseWriter of a handler to record the status and the number
+// of bytes written, calling beforeHeader once just before the header is sent.
+// Flushes are passed through so streamed responses keep working.
+type statusWriter struct {
+	http.ResponseWriter
+	status       int
+	bytes        int
+	beforeHeader func(http.Header)
+}
+
+// wrapWriter -
+// This function wraps w as a statusWriter, reusing w when it already is one
+// so nested middlewares share it
+func wrapWriter(w http.ResponseWriter) *statusWriter {
+	if sw, ok := w.(*statusWriter); ok {
+		return sw
+	}
+
+	return &statusWriter{ResponseWriter: w}
+}
+
+// started -
+// This method reports whether the header has been sent
+func (sw *statusWriter) started() bool {
+	return 0 != sw.status
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.started() {
+		return
+	}
+	if nil != sw.beforeHeader {
+		sw.beforeHeader(sw.Header())
+	}
+
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.WriteHeader(http.StatusOK)
+
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		sw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+	}
+}
+
+// requestIDKey -
+// The context key of the request ID
+type requestIDKey struct{}
+
+// Longest X-Request-ID accepted from a client
+const maxRequestIDLength = 128
+
+// withRequestID -
+// This method gives every request an ID, the X-Request-ID header of the
+// request when it is valid and a new random one otherwise. The ID is sent back
+// in the X-Request-ID header and carried in the request's context.
+func (s *Server) withRequestID(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			var err error
+			if id, err = newRequestID(); nil != err {
+				s.logger.Component("http").Error("could not generate a request ID", "error", err)
+			}
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	}
+}
+
+// requestID -
+// This function returns the ID given to the request, empty when there is none
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger -
+// This method returns the logger of the server for handling r, carrying the
+// ID of the request when it has one
+func (s *Server) requestLogger(r *http.Request) *logging.Logger {
+	logger := s.logger.Component("server")
+	if id := requestID(r.Context()); 0 < len(id) {
+		logger = logger.With("request_id", id)
+	}
+
+	return logger
+}
+
+// validRequestID -
+// This function reports whether id is 1 to 128 letters, digits, dashes,
+// underscores, dots or colons, so it can be logged and echoed safely
+func validRequestID(id string) bool {
+	if 0 == len(id) || maxRequestIDLength < len(id) {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case '-' == c, '_' == c, '.' == c, ':' == c:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID -
+// This function returns a random 128 bit request ID, all zeros along with the
+// error when no randomness could be read
+func newRequestID() (string, error) {
+	raw := make([]byte, 16)
+	_, err := rand.Read(raw)
+
+	return hex.EncodeToString(raw), err
+}
+
+// withAccessLog -
+// This method logs every request once it has been answered, under the http
+// component with its ID, method, path, status, latency and the bytes written
+func (s *Server) withAccessLog(h http.HandlerFunc) http.HandlerFunc {
+	logger := s.logger.Component("http")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := wrapWriter(w)
+
+		defer func() {
+			status := sw.status
+			if 0 == status {
+				// Nothing written is answered with 200 by net/http, unless
+				// the handler was aborted
+				status = http.StatusOK
+			}
+
+			logger.Info(
+				"request", "request_id", requestID(r.Context()), "method", r.Method, "path", r.URL.Path,
+				"status", status, "latency", time.Since(start), "bytes", sw.bytes,
+			)
+		}()
+
+		h.ServeHTTP(sw, r)
+	}
+}
+
+// withResponseTime -
+// This function reports the time taken to start the response in the
+// X-Response-Time header, for streamed responses this is the time to the
+// first byte
+func withResponseTime(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := wrapWriter(w)
+
+		next := sw.beforeHeader
+		sw.beforeHeader = func(header http.Header) {
+			header.Set("X-Response-Time", time.Since(start).String())
+			if nil != next {
+				next(header)
+			}
+		}
+
+		h.ServeHTTP(sw, r)
+	}
+}
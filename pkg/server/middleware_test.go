@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
+)
+
+func Test_chain(t *testing.T) {
+	var order []string
+	mark := func(name string) middleware {
+		return func(h http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				h.ServeHTTP(w, r)
+			}
+		}
+	}
+
+	h := chain(mark("outer"), mark("inner"))(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want := []string{"outer", "inner", "handler"}; !reflect.DeepEqual(want, order) {
+		t.Errorf("Incorrect order, wanted: %v but got: %v", want, order)
+	}
+}
+
+func Test_withRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "propagated", incoming: "abc-123_x.y:z", keep: true},
+		{name: "missing", incoming: "", keep: false},
+		{name: "invalid", incoming: "abc 123", keep: false},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1), keep: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := (&Server{}).withRequestID(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestID(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/current", nil)
+			if 0 != len(tt.incoming) {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			got := rw.Header().Get("X-Request-ID")
+			if got != seen {
+				t.Errorf("Header %v does not match the context %v", got, seen)
+			}
+			if tt.keep && tt.incoming != got {
+				t.Errorf("Incorrect request ID, wanted: %v but got: %v", tt.incoming, got)
+			}
+			if !tt.keep && (tt.incoming == got || 32 != len(got)) {
+				t.Errorf("Request ID %v was not generated", got)
+			}
+		})
+	}
+}
+
+func Test_withAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	s := &Server{logger: logging.New(&buf, logging.Options{})}
+
+	h := chain(s.withRequestID, s.withAccessLog)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/back", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := ` level=INFO component=http msg=request request_id=req-1 method=POST path=/back status=418 latency=`
+	if got := buf.String(); !strings.Contains(got, want) || !strings.HasSuffix(got, " bytes=5\n") {
+		t.Errorf("Incorrect access log, wanted: %v...bytes=5 but got: %v", want, got)
+	}
+}
+
+func Test_withResponseTime(t *testing.T) {
+	flushed := false
+	s := &Server{logger: logging.Discard()}
+	h := chain(s.withAccessLog, withResponseTime)(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		flushed = true
+	})
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+
+	if 0 == len(rw.Header().Get("X-Response-Time")) {
+		t.Errorf("X-Response-Time header is missing")
+	}
+	if !flushed || !rw.Flushed {
+		t.Errorf("Flush was not passed through")
+	}
+}
+
+func TestServer_middleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    middlewareOptions
+		headers []string
+	}{
+		{
+			name:    "all",
+			opts:    middlewareOptions{requestID: true, accessLog: true, responseTime: true},
+			headers: []string{"X-Request-ID", "X-Response-Time"},
+		},
+		{name: "request ID", opts: middlewareOptions{requestID: true}, headers: []string{"X-Request-ID"}},
+		{name: "none", opts: middlewareOptions{}, headers: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{middlewareOpts: tt.opts}
+			h := s.middleware("/current")(func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			})
+
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/current", nil))
+
+			if http.StatusInternalServerError != rw.Code {
+				t.Errorf("Panic was not recovered, got status %v", rw.Code)
+			}
+			for _, name := range []string{"X-Request-ID", "X-Response-Time"} {
+				want := false
+				for _, h := range tt.headers {
+					want = want || h == name
+				}
+				if got := 0 != len(rw.Header().Get(name)); got != want {
+					t.Errorf("Header %v present: %v, wanted: %v", name, got, want)
+				}
+			}
+		})
+	}
+}
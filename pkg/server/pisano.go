@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// Largest modulus /pisano computes the period of unless
+	// FIBONACCI_MAX_MODULUS says otherwise, the period is at most 6m
+	defaultMaxModulus = 1000000
+
+	// Moduli whose period is kept unless PISANO_CACHE_SIZE says otherwise
+	defaultPisanoCacheSize = 1024
+)
+
+// handlePisano -
+// This function returns the Pisano period of the modulus given in the path,
+// the number of terms after which F(n) mod m repeats. Periods are cached, a
+// modulus of 0 is answered with 400 Bad Request and one above the server's
+// limit with 422 Unprocessable Entity.
+func (s *Server) handlePisano() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, period, e := s.pisanoPeriod(r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"modulus": %d, "period": %d}`, m, period)))
+	}
+}
+
+// pisanoPeriod -
+// This method returns the modulus given in the path and its Pisano period
+func (s *Server) pisanoPeriod(r *http.Request) (uint64, uint64, *apiError) {
+	raw := httprouter.ParamsFromContext(r.Context()).ByName("m")
+	m, err := strconv.ParseUint(raw, 10, 64)
+	if nil != err || 0 == m {
+		return 0, 0, newAPIError(http.StatusBadRequest, "modulus must be a positive integer")
+	}
+
+	if m > s.maxModulus {
+		return 0, 0, newAPIError(
+			http.StatusUnprocessableEntity, "modulus exceeds the maximum served", member{"max_modulus", s.maxModulus},
+		)
+	}
+
+	period, err := s.pisano.Period(m)
+	if nil != err {
+		return 0, 0, newAPIError(http.StatusBadRequest, err.Error())
+	}
+
+	return m, period, nil
+}
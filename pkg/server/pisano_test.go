@@ -0,0 +1,89 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestServer_handlePisano(t *testing.T) {
+	type wants struct {
+		payload    string
+		statusCode int
+	}
+	tests := []struct {
+		name  string
+		path  string
+		wants wants
+	}{
+		{
+			name:  "happy path",
+			path:  "/pisano/10",
+			wants: wants{payload: `{"modulus": 10, "period": 60}`, statusCode: http.StatusOK},
+		},
+		{
+			name:  "cached",
+			path:  "/pisano/10",
+			wants: wants{payload: `{"modulus": 10, "period": 60}`, statusCode: http.StatusOK},
+		},
+		{
+			name:  "one",
+			path:  "/pisano/1",
+			wants: wants{payload: `{"modulus": 1, "period": 1}`, statusCode: http.StatusOK},
+		},
+		{
+			name:  "zero",
+			path:  "/pisano/0",
+			wants: wants{payload: `{"error": "modulus must be a positive integer"}`, statusCode: http.StatusBadRequest},
+		},
+		{
+			name:  "not a number",
+			path:  "/pisano/ten",
+			wants: wants{payload: `{"error": "modulus must be a positive integer"}`, statusCode: http.StatusBadRequest},
+		},
+		{
+			name: "above limit",
+			path: "/pisano/1001",
+			wants: wants{
+				payload:    `{"error": "modulus exceeds the maximum served", "max_modulus": 1000}`,
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+	}
+
+	// The sequence must never be consulted
+	fibSeq = nil
+	server := &Server{maxModulus: 1000, pisano: fibonacci.NewPisanoCache(4)}
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/pisano/:m", server.handlePisano())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080"+tt.path, nil)
+			rw := httptest.NewRecorder()
+
+			router.ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+)
+
+// Name reported in the envelope for the Server's own sequence and for the
+// endpoints computing Fibonacci numbers without any sequence state
+const defaultSequenceName = "fibonacci"
+
+// member -
+// A named member added to an error payload, after its message
+type member struct {
+	name  string
+	value interface{}
+}
+
+// apiError -
+// A failure to answer a request with, the status code, a message and any
+// further members describing it. Unversioned routes write it as an
+// {"error": ...} object, /v1 routes as an RFC 7807 problem.
+type apiError struct {
+	status  int
+	detail  string
+	members []member
+}
+
+// newAPIError -
+// This function creates an apiError with the given members, in order
+func newAPIError(status int, detail string, members ...member) *apiError {
+	return &apiError{status: status, detail: detail, members: members}
+}
+
+// Error -
+// This method returns the message of the error
+func (e *apiError) Error() string {
+	return e.detail
+}
+
+// writeError -
+// This function answers an unversioned route with the error as a JSON object
+// holding the message under "error", followed by the members
+func writeError(w http.ResponseWriter, e *apiError) {
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"error": %q`, e.detail)
+	for _, m := range e.members {
+		value, _ := json.Marshal(m.value)
+		fmt.Fprintf(&b, `, %q: %s`, m.name, value)
+	}
+	b.WriteString("}")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	w.Write([]byte(b.String()))
+}
+
+// writeProblem -
+// This function answers a /v1 route with the error as an RFC 7807
+// application/problem+json document, the members become extension members
+func writeProblem(w http.ResponseWriter, r *http.Request, e *apiError) {
+	problem := map[string]interface{}{
+		"type":     "about:blank",
+		"title":    http.StatusText(e.status),
+		"status":   e.status,
+		"detail":   e.detail,
+		"instance": r.URL.Path,
+	}
+	for _, m := range e.members {
+		problem[m.name] = m.value
+	}
+
+	payload, _ := json.Marshal(problem)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.status)
+	w.Write(payload)
+}
+
+// envelope -
+// The body of every successful /v1 response that is not streamed. Value holds
+// a sequence value or a typed result, Index the position of the value when it
+// has one and Sequence the sequence it belongs to.
+type envelope struct {
+	Value     interface{} `json:"value"`
+	Index     *uint64     `json:"index,omitempty"`
+	Sequence  string      `json:"sequence"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// termEnvelope -
+// This function wraps a term of the named sequence
+func termEnvelope(sequence string, t fibonacci.Term) envelope {
+	index := t.Index
+	return envelope{Value: t.Value, Index: &index, Sequence: sequence}
+}
+
+// termValue -
+// A term of a sequence within the value of an envelope
+type termValue struct {
+	Index uint64           `json:"index"`
+	Value fibonacci.Number `json:"value"`
+}
+
+// termValues -
+// This function converts terms for the value of an envelope
+func termValues(terms []fibonacci.Term) []termValue {
+	values := make([]termValue, len(terms))
+	for i, t := range terms {
+		values[i] = termValue{Index: t.Index, Value: t.Value}
+	}
+
+	return values
+}
+
+// writeEnvelope -
+// This function answers a /v1 route with the envelope, stamped with the
+// current time
+func (s *Server) writeEnvelope(w http.ResponseWriter, status int, env envelope) {
+	env.Timestamp = s.clock().UTC()
+
+	payload, _ := json.Marshal(env)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+// clock -
+// This method returns the current time
+func (s *Server) clock() time.Time {
+	if nil == s.now {
+		return time.Now()
+	}
+
+	return s.now()
+}
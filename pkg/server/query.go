@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Largest index whose Zeckendorf representation /fibonacci/zeckendorf serves
+// unless FIBONACCI_MAX_ZECKENDORF_INDEX says otherwise, a representation of a
+// number below F(n) holds up to n/2 terms of up to n * 0.21 digits each
+const defaultMaxZeckendorfIndex = 10000
+
+// handleQuery -
+// This function answers a number theory query about the integer given in the
+// path, without reading or advancing the sequence:
+//   - check - whether it is a Fibonacci number
+//   - index - the index n for which it is F(n)
+//   - zeckendorf - its Zeckendorf representation, largest term first
+//
+// The query shares its path segment with the index of /fibonacci/:n, which
+// httprouter requires to be named the same.
+// An integer that is not a non-negative integer is answered with 400 Bad
+// Request, one past F(FIBONACCI_MAX_INDEX), or past
+// F(FIBONACCI_MAX_ZECKENDORF_INDEX) for a Zeckendorf representation, with 422
+// Unprocessable Entity, and so is the index of a number that is not a
+// Fibonacci number.
+func (s *Server) handleQuery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, v, e := s.parseQuery(r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		value := jsonNumber(bigNumber(v))
+
+		var payload string
+		switch query {
+		case "check":
+			payload = fmt.Sprintf(`{"value": %s, "fibonacci": %t}`, value, fibonacci.IsFibonacci(v))
+		case "index":
+			n, ok := fibonacci.IndexOf(v)
+			if !ok {
+				writeError(w, errNotFibonacci)
+				return
+			}
+			payload = fmt.Sprintf(`{"value": %s, "index": %d}`, value, n)
+		case "zeckendorf":
+			terms := jsonTerms("terms", fibonacci.Zeckendorf(v))
+			payload = fmt.Sprintf(`{"value": %s, %s`, value, strings.TrimPrefix(terms, "{"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(payload))
+	}
+}
+
+// Answered for the index of a number that is not a Fibonacci number
+var errNotFibonacci = newAPIError(http.StatusUnprocessableEntity, "value is not a Fibonacci number")
+
+// parseQuery -
+// This method reads the query and the number it is about from the path,
+// rejecting a number too large to answer the query for
+func (s *Server) parseQuery(r *http.Request) (string, *big.Int, *apiError) {
+	params := httprouter.ParamsFromContext(r.Context())
+	query := params.ByName("n")
+
+	limit := s.maxIndex
+	switch query {
+	case "check", "index":
+	case "zeckendorf":
+		if s.maxZeckendorfIndex < limit {
+			limit = s.maxZeckendorfIndex
+		}
+	default:
+		return "", nil, newAPIError(http.StatusNotFound, fmt.Sprintf("unknown query %q", query))
+	}
+
+	v, ok := new(big.Int).SetString(params.ByName("x"), 10)
+	if !ok || 0 > v.Sign() {
+		return "", nil, newAPIError(http.StatusBadRequest, "number must be a non-negative integer")
+	}
+
+	// Bound the work from the size of the number before doing any of it
+	if fibonacci.ApproxIndex(v) > limit {
+		return "", nil, newAPIError(
+			http.StatusUnprocessableEntity, "number exceeds the maximum served", member{"max_index", limit},
+		)
+	}
+
+	return query, v, nil
+}
+
+// bigNumber -
+// This function wraps v as a Number, held as a uint64 when it fits one
+func bigNumber(v *big.Int) fibonacci.Number {
+	if v.IsUint64() {
+		return fibonacci.NewNumber(v.Uint64())
+	}
+
+	return fibonacci.NewBigNumber(v)
+}
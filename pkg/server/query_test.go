@@ -0,0 +1,162 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestServer_handleQuery(t *testing.T) {
+	type wants struct {
+		payload    string
+		statusCode int
+	}
+	tests := []struct {
+		name  string
+		path  string
+		wants wants
+	}{
+		{
+			name:  "check fibonacci",
+			path:  "/fibonacci/check/144",
+			wants: wants{payload: `{"value": 144, "fibonacci": true}`, statusCode: http.StatusOK},
+		},
+		{
+			name:  "check not fibonacci",
+			path:  "/fibonacci/check/145",
+			wants: wants{payload: `{"value": 145, "fibonacci": false}`, statusCode: http.StatusOK},
+		},
+		{
+			name: "check big",
+			path: "/fibonacci/check/354224848179261915075",
+			wants: wants{
+				payload:    `{"value": "354224848179261915075", "fibonacci": true}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:  "index",
+			path:  "/fibonacci/index/144",
+			wants: wants{payload: `{"value": 144, "index": 12}`, statusCode: http.StatusOK},
+		},
+		{
+			name:  "index of one",
+			path:  "/fibonacci/index/1",
+			wants: wants{payload: `{"value": 1, "index": 2}`, statusCode: http.StatusOK},
+		},
+		{
+			name: "index big",
+			path: "/fibonacci/index/354224848179261915075",
+			wants: wants{
+				payload:    `{"value": "354224848179261915075", "index": 100}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "index not fibonacci",
+			path: "/fibonacci/index/145",
+			wants: wants{
+				payload:    `{"error": "value is not a Fibonacci number"}`,
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name: "zeckendorf",
+			path: "/fibonacci/zeckendorf/100",
+			wants: wants{
+				payload:    `{"value": 100, "terms": [{"index": 11, "value": 89}, {"index": 6, "value": 8}, {"index": 4, "value": 3}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:  "zeckendorf zero",
+			path:  "/fibonacci/zeckendorf/0",
+			wants: wants{payload: `{"value": 0, "terms": []}`, statusCode: http.StatusOK},
+		},
+		{
+			name: "zeckendorf at limit",
+			path: "/fibonacci/zeckendorf/12586269026",
+			wants: wants{
+				payload:    `{"value": 12586269026, "terms": [{"index": 50, "value": 12586269025}, {"index": 2, "value": 1}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "negative",
+			path: "/fibonacci/check/-1",
+			wants: wants{
+				payload:    `{"error": "number must be a non-negative integer"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "not a number",
+			path: "/fibonacci/index/twelve",
+			wants: wants{
+				payload:    `{"error": "number must be a non-negative integer"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "above limit",
+			path: "/fibonacci/check/1" + strings.Repeat("0", 50),
+			wants: wants{
+				payload:    `{"error": "number exceeds the maximum served", "max_index": 200}`,
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name: "above zeckendorf limit",
+			path: "/fibonacci/zeckendorf/354224848179261915076",
+			wants: wants{
+				payload:    `{"error": "number exceeds the maximum served", "max_index": 50}`,
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name: "unknown query",
+			path: "/fibonacci/factor/144",
+			wants: wants{
+				payload:    `{"error": "unknown query \"factor\""}`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+
+	// The sequence must never be consulted
+	fibSeq = nil
+	router := httprouter.New()
+	server := &Server{maxIndex: 200, maxZeckendorfIndex: 50}
+	router.HandlerFunc(http.MethodGet, "/fibonacci/:n", server.handleIndex())
+	router.HandlerFunc(http.MethodGet, "/fibonacci/:n/:x", server.handleQuery())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080"+tt.path, nil)
+			rw := httptest.NewRecorder()
+
+			router.ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
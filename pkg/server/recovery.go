@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+)
+
+// Answered for a handler that panicked
+var errInternal = newAPIError(http.StatusInternalServerError, "internal error")
+
+// recoveryWrapper -
+// This method catches a panic of the handler, logs its value along with the
+// stack trace, counts it and answers with a 500 error: an RFC 7807 problem
+// under /v1 and an {"error": ...} object otherwise. Once the response has
+// started it can no longer be replaced, the connection is then closed so the
+// client cannot mistake the partial response for a complete one.
+// http.ErrAbortHandler is passed on, as it is the way to abort a response on
+// purpose.
+func (s *Server) recoveryWrapper(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := wrapWriter(w)
+
+		defer func() {
+			p := recover()
+			if nil == p {
+				return
+			}
+			if http.ErrAbortHandler == p {
+				panic(p)
+			}
+
+			atomic.AddUint64(&s.panics, 1)
+			s.requestLogger(r).Error(
+				"recovered panic", "method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(p), "stack", string(debug.Stack()),
+			)
+
+			if sw.started() {
+				panic(http.ErrAbortHandler)
+			}
+
+			if isVersioned(r) {
+				writeProblem(sw, r, errInternal)
+			} else {
+				writeError(sw, errInternal)
+			}
+		}()
+
+		h.ServeHTTP(sw, r)
+	}
+}
+
+// isVersioned -
+// This function reports whether the request is for a /v1 route
+func isVersioned(r *http.Request) bool {
+	return "/v1" == r.URL.Path || strings.HasPrefix(r.URL.Path, "/v1/")
+}
+
+// PanicCount -
+// This method returns the number of handler panics recovered since the server
+// started
+func (s *Server) PanicCount() uint64 {
+	return atomic.LoadUint64(&s.panics)
+}
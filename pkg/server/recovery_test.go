@@ -0,0 +1,185 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// panickingFibSequence -
+// Implements fibonacciSequence and panics on every call, as a nil sequence
+// would
+type panickingFibSequence struct {
+	fibonacciSequence
+}
+
+func TestServer_recoveryWrapper(t *testing.T) {
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+		panics      uint64
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		wants  wants
+	}{
+		{
+			name:   "unversioned",
+			method: http.MethodGet,
+			path:   "/current",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "internal error"}`,
+				statusCode:  http.StatusInternalServerError,
+				panics:      1,
+			},
+		},
+		{
+			name:   "versioned",
+			method: http.MethodGet,
+			path:   "/v1/next",
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"internal error","instance":"/v1/next","status":500,` +
+					`"title":"Internal Server Error","type":"about:blank"}`,
+				statusCode: http.StatusInternalServerError,
+				panics:     2,
+			},
+		},
+		{
+			name:   "named sequence",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/current",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"error": "internal error"}`,
+				statusCode:  http.StatusInternalServerError,
+				panics:      3,
+			},
+		},
+		{
+			name:   "no panic",
+			method: http.MethodGet,
+			path:   "/v1/fibonacci/12",
+			wants: wants{
+				contentType: "application/json",
+				statusCode:  http.StatusOK,
+				panics:      3,
+			},
+		},
+	}
+
+	// Every call to the sequence panics, as does any use of the missing
+	// registry of named sequences
+	fibSeq = panickingFibSequence{}
+	server := &Server{router: httprouter.New(), maxIndex: 1000}
+	server.routes()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://0.0.0.0:8080"+tt.path, nil)
+			rw := httptest.NewRecorder()
+
+			server.GetRouter().ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if 0 != len(tt.wants.payload) && !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+
+			if got := server.PanicCount(); tt.wants.panics != got {
+				t.Errorf("Incorrect panic count, wanted: %v but got: %v", tt.wants.panics, got)
+			}
+		})
+	}
+}
+
+func TestServer_recoveryWrapper_deprecated(t *testing.T) {
+	server := &Server{}
+
+	// Wrapped the way routes() wraps the unversioned /health
+	route := server.recoveryWrapper(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/health", nil)
+	rw := httptest.NewRecorder()
+	deprecated(route).ServeHTTP(rw, req)
+
+	if http.StatusInternalServerError != rw.Code || `{"error": "internal error"}` != rw.Body.String() {
+		t.Errorf("Incorrect response, wanted: 500 but got: %v %s", rw.Code, rw.Body.String())
+	}
+	if "true" != rw.Header().Get("Deprecation") {
+		t.Errorf("Headers of the outer middleware were dropped")
+	}
+	if 1 != server.PanicCount() {
+		t.Errorf("Incorrect panic count, wanted: 1 but got: %v", server.PanicCount())
+	}
+}
+
+func TestServer_recoveryWrapper_started(t *testing.T) {
+	server := &Server{}
+	h := server.recoveryWrapper(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[1, 2"))
+		panic("boom")
+	})
+
+	defer func() {
+		if p := recover(); http.ErrAbortHandler != p {
+			t.Errorf("Incorrect panic, wanted: %v but got: %v", http.ErrAbortHandler, p)
+		}
+		if 1 != server.PanicCount() {
+			t.Errorf("Incorrect panic count, wanted: 1 but got: %v", server.PanicCount())
+		}
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/fibonacci", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	t.Errorf("A panic after the response started was swallowed")
+}
+
+func TestServer_recoveryWrapper_abort(t *testing.T) {
+	server := &Server{}
+	h := server.recoveryWrapper(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if p := recover(); http.ErrAbortHandler != p {
+			t.Errorf("Incorrect panic, wanted: %v but got: %v", http.ErrAbortHandler, p)
+		}
+		if 0 != server.PanicCount() {
+			t.Errorf("An aborted handler was counted as a panic")
+		}
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/current", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// Resets kept by the audit log unless RESET_HISTORY_SIZE says otherwise
+	defaultResetHistory = 100
+
+	// Time a reset confirmation token stays valid
+	resetTokenTTL = time.Minute
+
+	// Reset confirmation tokens outstanding at once, issuing another evicts
+	// the one closest to expiring
+	maxResetTokens = 100
+)
+
+// resetRecord -
+// An audit entry of a reset of the sequence
+type resetRecord struct {
+	By        string    `json:"by"`
+	At        time.Time `json:"at"`
+	FromIndex uint64    `json:"from_index"`
+	FromEpoch uint64    `json:"from_epoch"`
+}
+
+// resetLog -
+// Issues the single use tokens confirming a reset and keeps a bounded history
+// of the resets performed, newest last. The history is kept in memory and
+// does not survive a restart.
+type resetLog struct {
+	capacity int
+	// Replaced in tests, time.Now when nil
+	now func() time.Time
+
+	mutex   sync.Mutex
+	tokens  map[string]time.Time
+	records []resetRecord
+}
+
+// newResetLog -
+// This function creates a resetLog keeping at most capacity records
+func newResetLog(capacity int) *resetLog {
+	return &resetLog{
+		capacity: capacity,
+		tokens:   map[string]time.Time{},
+	}
+}
+
+// issueToken -
+// This method returns a new token confirming a single reset, along with the
+// time it expires at
+func (rl *resetLog) issueToken() (string, time.Time, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); nil != err {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := rl.clock()
+	rl.pruneTokens(now)
+	if len(rl.tokens) >= maxResetTokens {
+		rl.evictToken()
+	}
+
+	expiry := now.Add(resetTokenTTL)
+	rl.tokens[token] = expiry
+
+	return token, expiry, nil
+}
+
+// redeemToken -
+// This method reports whether the token was issued and has not expired, and
+// invalidates it
+func (rl *resetLog) redeemToken(token string) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := rl.clock()
+	rl.pruneTokens(now)
+
+	_, ok := rl.tokens[token]
+	delete(rl.tokens, token)
+
+	return ok
+}
+
+// pruneTokens -
+// This method drops the tokens expired at now, the caller must hold the mutex
+func (rl *resetLog) pruneTokens(now time.Time) {
+	for t, expiry := range rl.tokens {
+		if now.After(expiry) {
+			delete(rl.tokens, t)
+		}
+	}
+}
+
+// evictToken -
+// This method drops the token closest to expiring, i.e. the oldest one, the
+// caller must hold the mutex
+func (rl *resetLog) evictToken() {
+	var oldest string
+	var oldestExpiry time.Time
+	for t, expiry := range rl.tokens {
+		if "" == oldest || expiry.Before(oldestExpiry) {
+			oldest, oldestExpiry = t, expiry
+		}
+	}
+
+	delete(rl.tokens, oldest)
+}
+
+// record -
+// This method adds a reset to the history, dropping the oldest record once
+// the history is full
+func (rl *resetLog) record(by string, fromIndex, fromEpoch uint64) resetRecord {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rec := resetRecord{By: by, At: rl.clock().UTC(), FromIndex: fromIndex, FromEpoch: fromEpoch}
+	rl.records = append(rl.records, rec)
+	if len(rl.records) > rl.capacity {
+		rl.records = append([]resetRecord(nil), rl.records[len(rl.records)-rl.capacity:]...)
+	}
+
+	return rec
+}
+
+// history -
+// This method returns a copy of the recorded resets, newest first
+func (rl *resetLog) history() []resetRecord {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	records := make([]resetRecord, len(rl.records))
+	for i, rec := range rl.records {
+		records[len(rl.records)-1-i] = rec
+	}
+
+	return records
+}
+
+// clock -
+// This method returns the current time, the caller must hold the mutex
+func (rl *resetLog) clock() time.Time {
+	if nil == rl.now {
+		return time.Now()
+	}
+
+	return rl.now()
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_resetLog_record(t *testing.T) {
+	rl := newResetLog(3)
+	for i := uint64(1); i <= 5; i++ {
+		rl.record("ops", i, 0)
+	}
+
+	var got []uint64
+	for _, rec := range rl.history() {
+		got = append(got, rec.FromIndex)
+	}
+
+	if want := []uint64{5, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resetLog.history() = %v, want %v", got, want)
+	}
+}
+
+func Test_resetLog_tokens(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	rl := newResetLog(3)
+	rl.now = func() time.Time { return now }
+
+	var tokens []string
+	for i := 0; i < maxResetTokens+1; i++ {
+		token, _, err := rl.issueToken()
+		if nil != err {
+			t.Fatalf("resetLog.issueToken() error = %v", err)
+		}
+		tokens = append(tokens, token)
+		now = now.Add(time.Millisecond)
+	}
+
+	// Issuing past the cap evicts the oldest token
+	if maxResetTokens != len(rl.tokens) {
+		t.Errorf("Incorrect outstanding tokens, wanted: %v but got: %v", maxResetTokens, len(rl.tokens))
+	}
+	if rl.redeemToken(tokens[0]) {
+		t.Errorf("The oldest token was redeemed after being evicted")
+	}
+	if !rl.redeemToken(tokens[1]) {
+		t.Errorf("A token was not redeemed")
+	}
+	if rl.redeemToken(tokens[1]) {
+		t.Errorf("A token was redeemed twice")
+	}
+
+	// Expired tokens are dropped on lookup
+	now = now.Add(resetTokenTTL)
+	if rl.redeemToken(tokens[maxResetTokens]) {
+		t.Errorf("An expired token was redeemed")
+	}
+	if 0 != len(rl.tokens) {
+		t.Errorf("Incorrect outstanding tokens, wanted: 0 but got: %v", len(rl.tokens))
+	}
+}
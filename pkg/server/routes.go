@@ -3,10 +3,54 @@ package server
 import "net/http"
 
 // This funciton initializes all the methods, routes, and assigns handlers for
-// the server's router
+// the server's router. Every route runs through the middleware chain. The
+// unversioned routes are deprecated aliases of the /v1 routes, kept with their
+// original payloads.
 func (s *Server) routes() {
-	s.router.HandlerFunc(http.MethodGet, "/current", recoveryWrapper(s.handleCurrent()))
-	s.router.HandlerFunc(http.MethodGet, "/next", recoveryWrapper(s.handleNext()))
-	s.router.HandlerFunc(http.MethodGet, "/previous", recoveryWrapper(s.handlePrevious()))
-	s.router.HandlerFunc(http.MethodGet, "/health", s.handleHealth())
+	handle := func(method, path string, h http.HandlerFunc) {
+		s.router.HandlerFunc(method, path, s.middleware(path)(h))
+	}
+
+	handle(http.MethodGet, "/v1/current", s.handleV1Current())
+	handle(http.MethodGet, "/v1/next", s.handleV1Next())
+	handle(http.MethodGet, "/v1/previous", s.handleV1Previous())
+	handle(http.MethodPost, "/v1/back", s.handleV1Back())
+	handle(http.MethodPut, "/v1/sequence/position", s.handleV1Position())
+	handle(http.MethodPost, "/v1/sequence/reset", s.handleV1Reset())
+	handle(http.MethodGet, "/v1/sequence/resets", s.handleV1ResetHistory())
+	handle(http.MethodPost, "/v1/sequences/:name", s.handleV1CreateSequence())
+	handle(http.MethodDelete, "/v1/sequences/:name", s.handleV1DeleteSequence())
+	handle(http.MethodGet, "/v1/sequences/:name/current", s.withV1Sequence(s.serveV1Current))
+	handle(http.MethodGet, "/v1/sequences/:name/next", s.withV1Sequence(s.serveV1Next))
+	handle(http.MethodGet, "/v1/sequences/:name/previous", s.withV1Sequence(s.serveV1Previous))
+	handle(http.MethodGet, "/v1/fibonacci", s.handleV1Range())
+	handle(http.MethodGet, "/v1/fibonacci/:n", s.handleV1Index())
+	handle(http.MethodGet, "/v1/fibonacci/:n/:x", s.handleV1Query())
+	handle(http.MethodGet, "/v1/pisano/:m", s.handleV1Pisano())
+	handle(http.MethodGet, "/v1/health", s.handleV1Health())
+
+	handle(http.MethodGet, "/current", deprecated(s.handleCurrent()))
+	handle(http.MethodGet, "/next", deprecated(s.handleNext()))
+	handle(http.MethodGet, "/previous", deprecated(s.handlePrevious()))
+	handle(http.MethodPost, "/back", deprecated(s.handleBack()))
+	handle(http.MethodPut, "/sequence/position", deprecated(s.handlePosition()))
+	handle(http.MethodPost, "/sequence/reset", deprecated(s.handleReset()))
+	handle(http.MethodGet, "/sequence/resets", deprecated(s.handleResetHistory()))
+	handle(http.MethodPost, "/sequences/:name", deprecated(s.handleCreateSequence()))
+	handle(http.MethodDelete, "/sequences/:name", deprecated(s.handleDeleteSequence()))
+	handle(http.MethodGet, "/sequences/:name/current", deprecated(s.withSequence(s.serveCurrent)))
+	handle(http.MethodGet, "/sequences/:name/next", deprecated(s.withSequence(s.serveNext)))
+	handle(http.MethodGet, "/sequences/:name/previous", deprecated(s.withSequence(s.servePrevious)))
+	handle(http.MethodGet, "/fibonacci", deprecated(s.handleRange()))
+	handle(http.MethodGet, "/fibonacci/:n", deprecated(s.handleIndex()))
+	handle(http.MethodGet, "/fibonacci/:n/:x", deprecated(s.handleQuery()))
+	handle(http.MethodGet, "/pisano/:m", deprecated(s.handlePisano()))
+	handle(http.MethodGet, "/health", deprecated(s.handleHealth()))
+
+	handle(http.MethodGet, "/livez", s.handleLivez())
+	handle(http.MethodGet, "/readyz", s.handleReadyz())
+	handle(http.MethodGet, "/metrics", s.handleMetrics())
+
+	s.router.NotFound = s.middleware(unmatchedRoute)(handleNotFound())
+	s.router.MethodNotAllowed = s.middleware(unmatchedRoute)(handleMethodNotAllowed())
 }
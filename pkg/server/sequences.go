@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Named sequences held at once unless MAX_SEQUENCES says otherwise
+const defaultMaxSequences = 100
+
+// sequenceErrorStatus -
+// This function maps an error of the sequence registry to a status code
+func sequenceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fibonacci.ErrInvalidName), errors.Is(err, fibonacci.ErrInvalidRecurrence):
+		return http.StatusBadRequest
+	case errors.Is(err, fibonacci.ErrSequenceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, fibonacci.ErrSequenceExists):
+		return http.StatusConflict
+	case errors.Is(err, fibonacci.ErrTooManySequences):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+// sequenceError -
+// This method converts a registry error to the status and message to answer
+// with, logging the errors that are not the client's
+func (s *Server) sequenceError(r *http.Request, err error) *apiError {
+	status := sequenceErrorStatus(err)
+	if http.StatusInternalServerError == status {
+		s.requestLogger(r).Error("could not access sequence", "error", err)
+	}
+
+	return newAPIError(status, err.Error())
+}
+
+// withSequence -
+// This function adapts a serve function of a single sequence to the named
+// sequence given in the path
+func (s *Server) withSequence(serve func(http.ResponseWriter, *http.Request, cursor)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+		seq, err := s.sequences.Get(name)
+		if nil != err {
+			writeError(w, s.sequenceError(r, err))
+			return
+		}
+
+		serve(w, r, seq)
+	}
+}
+
+// sequenceRequest -
+// The optional body of POST /sequences/:name, either the name of a preset or
+// the coefficients and seeds of a recurrence, optionally taken mod a modulus.
+// The seeds may be given as JSON numbers or strings.
+type sequenceRequest struct {
+	Preset       string        `json:"preset"`
+	Coefficients []int64       `json:"coefficients"`
+	Seeds        []json.Number `json:"seeds"`
+	Modulus      *uint64       `json:"modulus"`
+}
+
+// spec -
+// This method converts the request into the recurrence it describes
+func (req sequenceRequest) spec() (fibonacci.RecurrenceSpec, error) {
+	var modulus uint64
+	if nil != req.Modulus {
+		if 0 == *req.Modulus {
+			return fibonacci.RecurrenceSpec{}, fibonacci.ErrInvalidModulus
+		}
+		modulus = *req.Modulus
+	}
+
+	if 0 != len(req.Preset) {
+		if 0 != len(req.Coefficients) || 0 != len(req.Seeds) {
+			return fibonacci.RecurrenceSpec{}, errors.New("preset cannot be combined with coefficients or seeds")
+		}
+
+		spec, ok := fibonacci.Preset(req.Preset)
+		if !ok {
+			return fibonacci.RecurrenceSpec{}, fmt.Errorf("unknown preset: %q", req.Preset)
+		}
+		spec.Modulus = modulus
+		return spec, nil
+	}
+
+	spec := fibonacci.RecurrenceSpec{Coefficients: req.Coefficients, Modulus: modulus}
+	for _, raw := range req.Seeds {
+		seed, ok := new(big.Int).SetString(raw.String(), 10)
+		if !ok {
+			return fibonacci.RecurrenceSpec{}, errors.New("seeds must be integers")
+		}
+		spec.Seeds = append(spec.Seeds, seed)
+	}
+
+	return spec, nil
+}
+
+// handleCreateSequence -
+// This function starts a new named sequence. Without a body it is the
+// Fibonacci numbers from F(0), otherwise the body names a preset or gives the
+// coefficients and seeds of another linear recurrence, which starts from its
+// seeds, and may give a modulus every term is reduced by. An existing name
+// is answered with 409 Conflict and reaching the limit of sequences with 422
+// Unprocessable Entity.
+func (s *Server) handleCreateSequence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, seq, e := s.createSequence(w, r)
+		if nil != e {
+			writeError(w, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(fmt.Sprintf(
+			`{"name": %q, "index": %d, "current": %s}`,
+			name, seq.GetCurrent().Index, jsonNumber(seq.GetCurrent().Value),
+		)))
+	}
+}
+
+// createSequence -
+// This method starts the named sequence given in the path from the recurrence
+// described by the body of the request, as described for handleCreateSequence
+func (s *Server) createSequence(w http.ResponseWriter, r *http.Request) (string, fibonacci.Sequence, *apiError) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	var req sequenceRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSequenceBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); nil != err && io.EOF != err {
+		return "", nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+	}
+
+	spec, err := req.spec()
+	if nil != err {
+		return "", nil, newAPIError(http.StatusBadRequest, err.Error())
+	}
+
+	seq, err := s.sequences.Create(name, spec)
+	if nil != err {
+		return "", nil, s.sequenceError(r, err)
+	}
+
+	s.requestLogger(r).Info("sequence created", "sequence", name)
+	return name, seq, nil
+}
+
+// handleDeleteSequence -
+// This function removes a named sequence and its persisted state
+func (s *Server) handleDeleteSequence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+		if err := s.sequences.Delete(name); nil != err {
+			writeError(w, s.sequenceError(r, err))
+			return
+		}
+
+		s.requestLogger(r).Info("sequence deleted", "sequence", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
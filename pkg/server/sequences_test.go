@@ -0,0 +1,231 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestServer_sequences(t *testing.T) {
+	store := fibonacci.NewMemoryStore()
+	server := &Server{
+		router:    httprouter.New(),
+		maxBatch:  10,
+		sequences: fibonacci.NewRegistry(store, fibonacci.Options{}, 2),
+	}
+	server.routes()
+	defer server.sequences.Close()
+
+	type wants struct {
+		payload    string
+		statusCode int
+	}
+
+	// Steps run in order against the same server
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		wants  wants
+	}{
+		{
+			name:   "missing",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/current",
+			wants:  wants{payload: `{"error": "sequence does not exist"}`, statusCode: http.StatusNotFound},
+		},
+		{
+			name:   "create",
+			method: http.MethodPost,
+			path:   "/sequences/alpha",
+			wants:  wants{payload: `{"name": "alpha", "index": 0, "current": 0}`, statusCode: http.StatusCreated},
+		},
+		{
+			name:   "create again",
+			method: http.MethodPost,
+			path:   "/sequences/alpha",
+			wants:  wants{payload: `{"error": "sequence already exists"}`, statusCode: http.StatusConflict},
+		},
+		{
+			name:   "create second",
+			method: http.MethodPost,
+			path:   "/sequences/beta",
+			wants:  wants{payload: `{"name": "beta", "index": 0, "current": 0}`, statusCode: http.StatusCreated},
+		},
+		{
+			name:   "create over limit",
+			method: http.MethodPost,
+			path:   "/sequences/gamma",
+			wants:  wants{payload: `{"error": "too many sequences"}`, statusCode: http.StatusUnprocessableEntity},
+		},
+		{
+			name:   "create invalid",
+			method: http.MethodPost,
+			path:   "/sequences/a.b",
+			wants: wants{
+				payload:    `{"error": "sequence names must be 1 to 64 letters, digits, dashes or underscores"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "next",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/next?count=5",
+			wants: wants{
+				payload:    `{"next": [{"index": 1, "value": 1}, {"index": 2, "value": 1}, {"index": 3, "value": 2}, {"index": 4, "value": 3}, {"index": 5, "value": 5}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "current",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/current",
+			wants:  wants{payload: `{"index": 5, "current": 5}`, statusCode: http.StatusOK},
+		},
+		{
+			name:   "previous",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/previous",
+			wants:  wants{payload: `{"index": 4, "previous": 3}`, statusCode: http.StatusOK},
+		},
+		{
+			name:   "independent",
+			method: http.MethodGet,
+			path:   "/sequences/beta/current",
+			wants:  wants{payload: `{"index": 0, "current": 0}`, statusCode: http.StatusOK},
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   "/sequences/alpha",
+			wants:  wants{payload: ``, statusCode: http.StatusNoContent},
+		},
+		{
+			name:   "deleted",
+			method: http.MethodGet,
+			path:   "/sequences/alpha/current",
+			wants:  wants{payload: `{"error": "sequence does not exist"}`, statusCode: http.StatusNotFound},
+		},
+		{
+			name:   "delete missing",
+			method: http.MethodDelete,
+			path:   "/sequences/alpha",
+			wants:  wants{payload: `{"error": "sequence does not exist"}`, statusCode: http.StatusNotFound},
+		},
+		{
+			name:   "create unknown preset",
+			method: http.MethodPost,
+			path:   "/sequences/lucas",
+			body:   `{"preset": "padovan"}`,
+			wants:  wants{payload: `{"error": "unknown preset: \"padovan\""}`, statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "create invalid recurrence",
+			method: http.MethodPost,
+			path:   "/sequences/lucas",
+			body:   `{"coefficients": [1, 1], "seeds": [0]}`,
+			wants: wants{
+				payload:    `{"error": "invalid recurrence: 2 coefficients need as many seeds, got 1"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "create preset",
+			method: http.MethodPost,
+			path:   "/sequences/lucas",
+			body:   `{"preset": "lucas"}`,
+			wants:  wants{payload: `{"name": "lucas", "index": 0, "current": 2}`, statusCode: http.StatusCreated},
+		},
+		{
+			name:   "preset next",
+			method: http.MethodGet,
+			path:   "/sequences/lucas/next?count=3",
+			wants: wants{
+				payload:    `{"next": [{"index": 1, "value": 1}, {"index": 2, "value": 3}, {"index": 3, "value": 4}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "delete second",
+			method: http.MethodDelete,
+			path:   "/sequences/beta",
+			wants:  wants{payload: ``, statusCode: http.StatusNoContent},
+		},
+		{
+			name:   "create custom",
+			method: http.MethodPost,
+			path:   "/sequences/custom",
+			body:   `{"coefficients": [2, 1], "seeds": ["0", 1]}`,
+			wants:  wants{payload: `{"name": "custom", "index": 0, "current": 0}`, statusCode: http.StatusCreated},
+		},
+		{
+			name:   "custom next",
+			method: http.MethodGet,
+			path:   "/sequences/custom/next?count=3",
+			wants: wants{
+				payload:    `{"next": [{"index": 1, "value": 1}, {"index": 2, "value": 2}, {"index": 3, "value": 5}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "delete preset",
+			method: http.MethodDelete,
+			path:   "/sequences/lucas",
+			wants:  wants{payload: ``, statusCode: http.StatusNoContent},
+		},
+		{
+			name:   "create zero modulus",
+			method: http.MethodPost,
+			path:   "/sequences/modular",
+			body:   `{"modulus": 0}`,
+			wants:  wants{payload: `{"error": "modulus must be at least 1"}`, statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "create modular",
+			method: http.MethodPost,
+			path:   "/sequences/modular",
+			body:   `{"preset": "pell", "modulus": 3}`,
+			wants:  wants{payload: `{"name": "modular", "index": 0, "current": 0}`, statusCode: http.StatusCreated},
+		},
+		{
+			name:   "modular next",
+			method: http.MethodGet,
+			path:   "/sequences/modular/next?count=4",
+			wants: wants{
+				payload:    `{"next": [{"index": 1, "value": 1}, {"index": 2, "value": 2}, {"index": 3, "value": 2}, {"index": 4, "value": 0}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://0.0.0.0:8080"+tt.path, strings.NewReader(tt.body))
+			rw := httptest.NewRecorder()
+
+			server.router.ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+		})
+	}
+}
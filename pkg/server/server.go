@@ -1,9 +1,11 @@
 package server
 
 import (
-	"os"
+	"context"
+	"time"
 
 	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/dvo-dev/fibonacci-backend/pkg/logging"
 	"github.com/go-redis/redis/v8"
 	"github.com/julienschmidt/httprouter"
 )
@@ -13,7 +15,7 @@ import (
 type serverInitializer interface {
 	NewRedisClient(opt *redis.Options) *redis.Client
 	NewRouter() *httprouter.Router
-	InitializeFibonacci(rdb fibonacci.RedisClient) *fibonacci.Fibonacci
+	InitializeFibonacci(store fibonacci.StateStore, opts fibonacci.Options) (*fibonacci.Fibonacci, error)
 }
 
 // servInitializer -
@@ -28,8 +30,8 @@ func (servInit servInitializer) NewRedisClient(opt *redis.Options) *redis.Client
 
 // InitializeFibonacci -
 // Method that wraps fibonacci.InitializeFibonacci call
-func (servInit servInitializer) InitializeFibonacci(rdb fibonacci.RedisClient) *fibonacci.Fibonacci {
-	return fibonacci.InitializeFibonacci(rdb)
+func (servInit servInitializer) InitializeFibonacci(store fibonacci.StateStore, opts fibonacci.Options) (*fibonacci.Fibonacci, error) {
+	return fibonacci.InitializeFibonacci(store, opts)
 }
 
 // NewRouter -
@@ -48,33 +50,117 @@ func init() {
 // Server -
 // servInitmple server wrapper onjects to contain all baservInitc dependencies.
 type Server struct {
-	fibSequence *fibonacci.Fibonacci
-	router      *httprouter.Router
-	rdb         *redis.Client
+	fibSequence        *fibonacci.Fibonacci
+	router             *httprouter.Router
+	store              fibonacci.StateStore
+	maxIndex           uint64
+	maxRange           uint64
+	maxBatch           uint64
+	resets             *resetLog
+	sequences          *fibonacci.Registry
+	maxModulus         uint64
+	pisano             *fibonacci.PisanoCache
+	maxZeckendorfIndex uint64
+	// Replaced in tests, time.Now when nil
+	now func() time.Time
+	// Handler panics recovered, accessed atomically
+	panics         uint64
+	middlewareOpts middlewareOptions
+	metrics        *httpMetrics
+	// Checks run by /livez and /readyz
+	liveness          []healthCheck
+	readiness         []healthCheck
+	checkTimeout      time.Duration
+	maxPersistenceLag time.Duration
+	logger            *logging.Logger
 }
 
+const (
+	// Largest index served by /fibonacci unless FIBONACCI_MAX_INDEX says
+	// otherwise, F(1000000) has about 209000 digits
+	defaultMaxIndex = 1000000
+
+	// Most terms streamed by a single range request unless FIBONACCI_MAX_RANGE
+	// says otherwise
+	defaultMaxRange = 10000
+
+	// Most terms advanced by a single /next request unless FIBONACCI_MAX_BATCH
+	// says otherwise
+	defaultMaxBatch = 1000
+)
+
 // InitializeServer -
-// Public function used to initialize an instance of Server.
-func InitializeServer() *Server {
-	hostPort := os.Getenv("REDIS_HOST_PORT")
-	if 0 == len(hostPort) {
-		hostPort = "redis:6379"
+// Public function used to initialize an instance of Server from the given
+// configuration, logging through logger, or the default logger when nil.
+// An error is returned when the persisted sequence state cannot be restored.
+func InitializeServer(cfg Config, logger *logging.Logger) (*Server, error) {
+	store, err := newStateStore(cfg, logger)
+	if nil != err {
+		return nil, err
+	}
+
+	opts := fibonacci.Options{
+		Mode:           cfg.Mode,
+		OverflowPolicy: cfg.Overflow,
+		Logger:         logger,
 	}
 
-	rdb := servInit.NewRedisClient(&redis.Options{
-		Addr:     hostPort,
-		Password: "",
-		DB:       0,
-	})
+	fib, err := servInit.InitializeFibonacci(store, opts)
+	if nil != err {
+		store.Close()
+		return nil, err
+	}
 
 	s := &Server{
-		fibSequence: servInit.InitializeFibonacci(rdb),
-		router:      servInit.NewRouter(),
-		rdb:         rdb,
+		fibSequence:        fib,
+		router:             servInit.NewRouter(),
+		store:              store,
+		maxIndex:           cfg.Limits.MaxIndex,
+		maxRange:           cfg.Limits.MaxRange,
+		maxBatch:           cfg.Limits.MaxBatch,
+		resets:             newResetLog(cfg.Limits.ResetHistorySize),
+		sequences:          fibonacci.NewRegistry(store, opts, cfg.Limits.MaxSequences),
+		maxModulus:         cfg.Limits.MaxModulus,
+		pisano:             fibonacci.NewPisanoCache(cfg.Limits.PisanoCacheSize),
+		maxZeckendorfIndex: cfg.Limits.MaxZeckendorfIndex,
+		middlewareOpts: middlewareOptions{
+			requestID:    cfg.Middleware.RequestID,
+			accessLog:    cfg.Middleware.AccessLog,
+			responseTime: cfg.Middleware.ResponseTime,
+			metrics:      cfg.Middleware.Metrics,
+		},
+		metrics:           newHTTPMetrics(),
+		logger:            logger,
+		checkTimeout:      cfg.CheckTimeout,
+		maxPersistenceLag: cfg.MaxPersistenceLag,
 	}
 
+	s.addDefaultChecks()
 	s.routes()
-	return s
+	return s, nil
+}
+
+// newStateStore -
+// This function creates the state store selected by the configuration
+func newStateStore(cfg Config, logger *logging.Logger) (fibonacci.StateStore, error) {
+	logger.Component("server").Info("persisting state", "store", cfg.Store)
+
+	switch cfg.Store {
+	case fibonacci.StoreMemory:
+		return fibonacci.NewMemoryStore(), nil
+	case fibonacci.StoreFile:
+		return fibonacci.NewFileStore(cfg.StateDir)
+	case fibonacci.StoreWAL:
+		opts := cfg.WAL
+		opts.Logger = logger
+		return fibonacci.NewWALStore(cfg.StateDir, opts)
+	}
+
+	return fibonacci.NewRedisStore(servInit.NewRedisClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})), nil
 }
 
 // GetRouter -
@@ -82,3 +168,33 @@ func InitializeServer() *Server {
 func (s *Server) GetRouter() *httprouter.Router {
 	return s.router
 }
+
+// Shutdown -
+// This method writes the latest state of the sequence and of every named
+// sequence to the store synchronously, then closes the store. It is meant to
+// be called once requests have drained, changes made afterwards are not
+// persisted. The first error is returned, every step is attempted regardless.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var first error
+	record := func(what string, err error) {
+		if nil == err {
+			return
+		}
+		s.logger.Component("server").Error("shutdown step failed", "step", what, "error", err)
+		if nil == first {
+			first = err
+		}
+	}
+
+	if nil != s.sequences {
+		record("flush named sequences", s.sequences.Flush(ctx))
+	}
+	if nil != s.fibSequence {
+		record("flush sequence", s.fibSequence.Flush(ctx))
+	}
+	if nil != s.store {
+		record("close state store", s.store.Close())
+	}
+
+	return first
+}
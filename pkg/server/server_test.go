@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 
@@ -12,14 +15,15 @@ import (
 type mockServerInitializer struct {
 	rdb    *redis.Client
 	router *httprouter.Router
+	err    error
 }
 
 func (msi mockServerInitializer) NewRedisClient(opt *redis.Options) *redis.Client {
 	return msi.rdb
 }
 
-func (msi mockServerInitializer) InitializeFibonacci(rdb fibonacci.RedisClient) *fibonacci.Fibonacci {
-	return &fibonacci.Fibonacci{}
+func (msi mockServerInitializer) InitializeFibonacci(store fibonacci.StateStore, opts fibonacci.Options) (*fibonacci.Fibonacci, error) {
+	return &fibonacci.Fibonacci{}, msi.err
 }
 
 func (msi mockServerInitializer) NewRouter() *httprouter.Router {
@@ -37,29 +41,85 @@ func TestInitializeServer(t *testing.T) {
 	}
 
 	tests := []struct {
-		name string
-		want *Server
+		name     string
+		initMock mockServerInitializer
+		want     *Server
+		wantErr  bool
 	}{
 		{
-			name: "happy path",
+			name:     "happy path",
+			initMock: mockServerInit,
 			want: &Server{
 				fibSequence: &fibonacci.Fibonacci{},
 				router:      mockServerInit.router,
-				rdb:         mockServerInit.rdb,
+				store:       fibonacci.NewRedisStore(mockServerInit.rdb),
+				maxIndex:    defaultMaxIndex,
+				maxRange:    defaultMaxRange,
+				maxBatch:    defaultMaxBatch,
+				resets:      newResetLog(defaultResetHistory),
+				sequences: fibonacci.NewRegistry(
+					fibonacci.NewRedisStore(mockServerInit.rdb), fibonacci.Options{}, defaultMaxSequences,
+				),
+				maxModulus: defaultMaxModulus,
+				pisano:     fibonacci.NewPisanoCache(defaultPisanoCacheSize),
+
+				maxZeckendorfIndex: defaultMaxZeckendorfIndex,
+				middlewareOpts: middlewareOptions{
+					requestID: true, accessLog: true, responseTime: true, metrics: true,
+				},
+				metrics:           newHTTPMetrics(),
+				checkTimeout:      defaultCheckTimeout,
+				maxPersistenceLag: defaultMaxPersistenceLag,
 			},
 		},
+		{
+			name: "corrupt state",
+			initMock: mockServerInitializer{
+				rdb:    mockServerInit.rdb,
+				router: httprouter.New(),
+				err:    fibonacci.ErrCorruptState,
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 
-		servInit = mockServerInit
+		servInit = tt.initMock
 		t.Run(tt.name, func(t *testing.T) {
-			if got := InitializeServer(); !reflect.DeepEqual(got, tt.want) {
+			got, err := InitializeServer(DefaultConfig(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InitializeServer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// Checkers are funcs, which never compare equal, so only their
+			// names are compared
+			if nil != got {
+				if names, want := checkNames(got.liveness), []string{"sequence"}; !reflect.DeepEqual(names, want) {
+					t.Errorf("InitializeServer() liveness checks = %v, want %v", names, want)
+				}
+				if names, want := checkNames(got.readiness), []string{"store", "persistence"}; !reflect.DeepEqual(names, want) {
+					t.Errorf("InitializeServer() readiness checks = %v, want %v", names, want)
+				}
+				got.liveness, got.readiness = nil, nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("InitializeServer() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func checkNames(checks []healthCheck) []string {
+	names := make([]string, len(checks))
+	for i, hc := range checks {
+		names[i] = hc.name
+	}
+
+	return names
+}
+
 func TestServer_GetRouter(t *testing.T) {
 	type fields struct {
 		router *httprouter.Router
@@ -90,3 +150,127 @@ func TestServer_GetRouter(t *testing.T) {
 		})
 	}
 }
+
+// recordingInitializer -
+// Creates redis clients as servInitializer does, recording their options
+type recordingInitializer struct {
+	servInitializer
+	opts *redis.Options
+}
+
+func (ri *recordingInitializer) NewRedisClient(opt *redis.Options) *redis.Client {
+	ri.opts = opt
+	return ri.servInitializer.NewRedisClient(opt)
+}
+
+func Test_newStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server-state")
+	if nil != err {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name  string
+		store fibonacci.StoreKind
+		want  reflect.Type
+	}{
+		{name: "redis", store: fibonacci.StoreRedis, want: reflect.TypeOf(&fibonacci.RedisStore{})},
+		{name: "memory", store: fibonacci.StoreMemory, want: reflect.TypeOf(&fibonacci.MemoryStore{})},
+		{name: "file", store: fibonacci.StoreFile, want: reflect.TypeOf(&fibonacci.FileStore{})},
+		{name: "wal", store: fibonacci.StoreWAL, want: reflect.TypeOf(&fibonacci.WALStore{})},
+	}
+	for _, tt := range tests {
+		servInit = servInitializer{}
+		cfg := DefaultConfig()
+		cfg.Store = tt.store
+		cfg.StateDir = dir
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newStateStore(cfg, nil)
+			if nil != err {
+				t.Fatalf("newStateStore() error = %v", err)
+			}
+			defer got.Close()
+
+			if reflect.TypeOf(got) != tt.want {
+				t.Errorf("newStateStore() = %T, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newStateStore_redis(t *testing.T) {
+	recorder := &recordingInitializer{}
+	servInit = recorder
+	defer func() { servInit = servInitializer{} }()
+
+	cfg := DefaultConfig()
+	cfg.Redis = RedisConfig{Addr: "cache:6380", Password: "hunter2", DB: 3}
+
+	store, err := newStateStore(cfg, nil)
+	if nil != err {
+		t.Fatalf("newStateStore() error = %v", err)
+	}
+	defer store.Close()
+
+	got := recorder.opts
+	if nil == got || "cache:6380" != got.Addr || "hunter2" != got.Password || 3 != got.DB {
+		t.Errorf("Incorrect redis options, wanted: cache:6380, hunter2 and DB 3 but got: %+v", got)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	store := fibonacci.NewMemoryStore()
+	fib, err := fibonacci.InitializeFibonacci(store, fibonacci.Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+	sequences := fibonacci.NewRegistry(store, fibonacci.Options{}, defaultMaxSequences)
+
+	fib.GetNextN(7)
+	lucas, err := sequences.Create("lucas", fibonacci.LucasSpec)
+	if nil != err {
+		t.Fatalf("Registry.Create() error = %v", err)
+	}
+	lucas.GetNextN(3)
+
+	server := &Server{fibSequence: fib, store: store, sequences: sequences}
+	if err := server.Shutdown(context.Background()); nil != err {
+		t.Fatalf("Server.Shutdown() error = %v", err)
+	}
+
+	for key, want := range map[string]uint64{fibonacci.DefaultStateKey: 7, "sequence:lucas": 3} {
+		st, err := store.Load(context.Background(), key)
+		if nil != err {
+			t.Fatalf("Load() of %v error = %v", key, err)
+		}
+		if want != st.Index {
+			t.Errorf("Persisted index of %v = %v, want %v", key, st.Index, want)
+		}
+	}
+}
+
+// readOnlyStore -
+// A state store that can still be read but no longer written to, as when the
+// connection drops after the state is loaded
+type readOnlyStore struct {
+	fibonacci.StateStore
+}
+
+func (ros readOnlyStore) CompareAndSwap(ctx context.Context, key string, st fibonacci.State) (bool, error) {
+	return false, errUnreachable
+}
+
+func TestServer_Shutdown_storeDown(t *testing.T) {
+	store := readOnlyStore{fibonacci.NewMemoryStore()}
+	fib, err := fibonacci.InitializeFibonacci(store, fibonacci.Options{})
+	if nil != err {
+		t.Fatalf("InitializeFibonacci() error = %v", err)
+	}
+
+	server := &Server{fibSequence: fib, store: store}
+	if err := server.Shutdown(context.Background()); errUnreachable != err {
+		t.Errorf("Server.Shutdown() error = %v, want %v", err, errUnreachable)
+	}
+}
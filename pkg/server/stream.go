@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+)
+
+const (
+	// Terms written between flushes of a streamed range
+	streamFlushEvery = 256
+
+	// Size of the buffer a streamed range is written through
+	streamBufferSize = 32 * 1024
+)
+
+// rangeEncoder -
+// Writes the terms of a range in one output format
+type rangeEncoder interface {
+	contentType() string
+	begin(w io.Writer) error
+	term(w io.Writer, first bool, index uint64, n fibonacci.Number) error
+	end(w io.Writer) error
+}
+
+// jsonRangeEncoder -
+// Writes a range as a single JSON array
+type jsonRangeEncoder struct{}
+
+func (jsonRangeEncoder) contentType() string { return "application/json" }
+
+func (jsonRangeEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+func (jsonRangeEncoder) term(w io.Writer, first bool, index uint64, n fibonacci.Number) error {
+	sep := ", "
+	if first {
+		sep = ""
+	}
+
+	_, err := fmt.Fprintf(w, `%s{"index": %d, "value": %s}`, sep, index, jsonNumber(n))
+	return err
+}
+
+func (jsonRangeEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// ndjsonRangeEncoder -
+// Writes a range as newline delimited JSON, one object per term
+type ndjsonRangeEncoder struct{}
+
+func (ndjsonRangeEncoder) contentType() string { return "application/x-ndjson" }
+
+func (ndjsonRangeEncoder) begin(w io.Writer) error { return nil }
+
+func (ndjsonRangeEncoder) term(w io.Writer, first bool, index uint64, n fibonacci.Number) error {
+	_, err := fmt.Fprintf(w, "{\"index\": %d, \"value\": %s}\n", index, jsonNumber(n))
+	return err
+}
+
+func (ndjsonRangeEncoder) end(w io.Writer) error { return nil }
+
+// csvRangeEncoder -
+// Writes a range as CSV with an index,value header
+type csvRangeEncoder struct{}
+
+func (csvRangeEncoder) contentType() string { return "text/csv" }
+
+func (csvRangeEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, "index,value\n")
+	return err
+}
+
+func (csvRangeEncoder) term(w io.Writer, first bool, index uint64, n fibonacci.Number) error {
+	_, err := fmt.Fprintf(w, "%d,%s\n", index, n)
+	return err
+}
+
+func (csvRangeEncoder) end(w io.Writer) error { return nil }
+
+// negotiateRangeEncoder -
+// This function picks the encoder for the first media type of the Accept
+// header that is supported, JSON when the header is empty or accepts anything.
+// False is returned when no listed media type is supported.
+func negotiateRangeEncoder(accept string) (rangeEncoder, bool) {
+	if 0 == len(strings.TrimSpace(accept)) {
+		return jsonRangeEncoder{}, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if nil != err {
+			continue
+		}
+
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return jsonRangeEncoder{}, true
+		case "application/x-ndjson", "application/ndjson":
+			return ndjsonRangeEncoder{}, true
+		case "text/csv", "text/*":
+			return csvRangeEncoder{}, true
+		}
+	}
+
+	return nil, false
+}
+
+// streamRange -
+// This function writes count terms starting at from through the encoder,
+// flushing every streamFlushEvery terms so the response is sent in chunks and
+// memory use does not grow with the range. It stops early once the client
+// goes away.
+func streamRange(w http.ResponseWriter, r *http.Request, enc rangeEncoder, from, count uint64) error {
+	flusher, _ := w.(http.Flusher)
+	buf := bufio.NewWriterSize(w, streamBufferSize)
+
+	flush := func() error {
+		if err := buf.Flush(); nil != err {
+			return err
+		}
+		if nil != flusher {
+			flusher.Flush()
+		}
+		return r.Context().Err()
+	}
+
+	if err := enc.begin(buf); nil != err {
+		return err
+	}
+
+	it := fibonacci.NewIterator(from)
+	for i := uint64(0); i < count; i++ {
+		index, n := it.Next()
+		if err := enc.term(buf, 0 == i, index, n); nil != err {
+			return err
+		}
+
+		if 0 == (i+1)%streamFlushEvery {
+			if err := flush(); nil != err {
+				return err
+			}
+		}
+	}
+
+	if err := enc.end(buf); nil != err {
+		return err
+	}
+
+	return buf.Flush()
+}
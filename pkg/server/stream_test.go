@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// countingRecorder -
+// Records how many times a streamed response was flushed
+type countingRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (cr *countingRecorder) Flush() {
+	cr.flushes++
+	cr.ResponseRecorder.Flush()
+}
+
+func Test_negotiateRangeEncoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   rangeEncoder
+		wantOk bool
+	}{
+		{name: "empty", accept: "", want: jsonRangeEncoder{}, wantOk: true},
+		{name: "anything", accept: "*/*", want: jsonRangeEncoder{}, wantOk: true},
+		{name: "json", accept: "application/json", want: jsonRangeEncoder{}, wantOk: true},
+		{name: "ndjson", accept: "application/x-ndjson", want: ndjsonRangeEncoder{}, wantOk: true},
+		{name: "csv with parameters", accept: "text/csv; charset=utf-8", want: csvRangeEncoder{}, wantOk: true},
+		{name: "first supported", accept: "application/xml, text/csv, application/json", want: csvRangeEncoder{}, wantOk: true},
+		{name: "unsupported", accept: "application/xml", want: nil, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := negotiateRangeEncoder(tt.accept)
+			if ok != tt.wantOk {
+				t.Errorf("negotiateRangeEncoder() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("negotiateRangeEncoder() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_streamRange(t *testing.T) {
+	t.Run("chunked", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/fibonacci", nil)
+		rw := &countingRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+		if err := streamRange(rw, req, jsonRangeEncoder{}, 0, 3*streamFlushEvery); nil != err {
+			t.Fatalf("streamRange() error = %v", err)
+		}
+
+		if 3 != rw.flushes {
+			t.Errorf("Incorrect number of flushes, wanted: %v but got: %v", 3, rw.flushes)
+		}
+
+		var terms []struct {
+			Index uint64 `json:"index"`
+		}
+		if err := json.Unmarshal(rw.Body.Bytes(), &terms); nil != err {
+			t.Fatalf("Streamed range is not valid JSON: %v", err)
+		}
+		if 3*streamFlushEvery != len(terms) || 3*streamFlushEvery-1 != terms[len(terms)-1].Index {
+			t.Errorf("Incorrect terms streamed, got %v ending at %v", len(terms), terms[len(terms)-1].Index)
+		}
+	})
+
+	t.Run("client gone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/fibonacci", nil).WithContext(ctx)
+		rw := &countingRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+		if err := streamRange(rw, req, ndjsonRangeEncoder{}, 0, 10*streamFlushEvery); context.Canceled != err {
+			t.Errorf("streamRange() error = %v, want %v", err, context.Canceled)
+		}
+		if 1 != rw.flushes {
+			t.Errorf("Streaming continued after the client went away, flushes: %v", rw.flushes)
+		}
+	})
+}
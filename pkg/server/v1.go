@@ -0,0 +1,378 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The /v1 routes serve the same operations as the unversioned routes, every
+// successful response is an envelope and every failure an RFC 7807 problem
+
+// checkValue -
+// The value of /v1/fibonacci/check/:x
+type checkValue struct {
+	Number    fibonacci.Number `json:"number"`
+	Fibonacci bool             `json:"fibonacci"`
+}
+
+// zeckendorfValue -
+// The value of /v1/fibonacci/zeckendorf/:x
+type zeckendorfValue struct {
+	Number fibonacci.Number `json:"number"`
+	Terms  []termValue      `json:"terms"`
+}
+
+// pisanoValue -
+// The value of /v1/pisano/:m
+type pisanoValue struct {
+	Modulus uint64 `json:"modulus"`
+	Period  uint64 `json:"period"`
+}
+
+// healthValue -
+// The value of /v1/health
+type healthValue struct {
+	Status string `json:"status"`
+}
+
+// handleV1Current -
+// This function returns the current term of the sequence, see handleCurrent
+func (s *Server) handleV1Current() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveV1Current(w, r, defaultSequenceName, defaultCursor{s})
+	}
+}
+
+// serveV1Current -
+// This function writes the current term of the named sequence
+func (s *Server) serveV1Current(w http.ResponseWriter, r *http.Request, name string, c cursor) {
+	s.writeEnvelope(w, http.StatusOK, termEnvelope(name, c.GetCurrent()))
+}
+
+// handleV1Next -
+// This function progresses the sequence and returns the next term, or with a
+// count query parameter the terms passed through, see handleNext
+func (s *Server) handleV1Next() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveV1Next(w, r, defaultSequenceName, defaultCursor{s})
+	}
+}
+
+// serveV1Next -
+// This function progresses the named sequence and writes the terms passed
+// through. The index of a batch is that of its last term.
+func (s *Server) serveV1Next(w http.ResponseWriter, r *http.Request, name string, c cursor) {
+	count, e := s.parseCount(r.URL.Query())
+	if nil != e {
+		writeProblem(w, r, e)
+		return
+	}
+
+	terms, e := advance(w, c, count)
+	if nil != e {
+		writeProblem(w, r, e)
+		return
+	}
+
+	if 0 == count {
+		s.writeEnvelope(w, http.StatusOK, termEnvelope(name, terms[0]))
+		return
+	}
+
+	index := terms[len(terms)-1].Index
+	s.writeEnvelope(w, http.StatusOK, envelope{Value: termValues(terms), Index: &index, Sequence: name})
+}
+
+// handleV1Previous -
+// This function returns the previous term of the sequence, see
+// handlePrevious
+func (s *Server) handleV1Previous() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveV1Previous(w, r, defaultSequenceName, defaultCursor{s})
+	}
+}
+
+// serveV1Previous -
+// This function writes the previous term of the named sequence
+func (s *Server) serveV1Previous(w http.ResponseWriter, r *http.Request, name string, c cursor) {
+	s.writeEnvelope(w, http.StatusOK, termEnvelope(name, c.GetPrevious()))
+}
+
+// handleV1Back -
+// This function rewinds the sequence by one, see handleBack
+func (s *Server) handleV1Back() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := fibSeq.Back(s)
+		if nil != err {
+			writeProblem(w, r, newAPIError(http.StatusConflict, err.Error()))
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusOK, termEnvelope(defaultSequenceName, current))
+	}
+}
+
+// handleV1Position -
+// This function moves the sequence to the position given in the body, see
+// handlePosition
+func (s *Server) handleV1Position() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, e := s.seekPosition(w, r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusOK, termEnvelope(defaultSequenceName, current))
+	}
+}
+
+// handleV1Reset -
+// This function moves the sequence back to F(0) once confirmed, see
+// handleReset. The value is the record of the reset.
+func (s *Server) handleV1Reset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec, e := s.confirmReset(r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		index := uint64(0)
+		s.writeEnvelope(w, http.StatusOK, envelope{Value: rec, Index: &index, Sequence: defaultSequenceName})
+	}
+}
+
+// handleV1ResetHistory -
+// This function returns the resets recorded since the server started, newest
+// first
+func (s *Server) handleV1ResetHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeEnvelope(w, http.StatusOK, envelope{Value: s.resets.history(), Sequence: defaultSequenceName})
+	}
+}
+
+// withV1Sequence -
+// This function adapts a serve function of a single sequence to the named
+// sequence given in the path
+func (s *Server) withV1Sequence(serve func(http.ResponseWriter, *http.Request, string, cursor)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+		seq, err := s.sequences.Get(name)
+		if nil != err {
+			writeProblem(w, r, s.sequenceError(r, err))
+			return
+		}
+
+		serve(w, r, name, seq)
+	}
+}
+
+// handleV1CreateSequence -
+// This function starts a new named sequence, see handleCreateSequence
+func (s *Server) handleV1CreateSequence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, seq, e := s.createSequence(w, r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusCreated, termEnvelope(name, seq.GetCurrent()))
+	}
+}
+
+// handleV1DeleteSequence -
+// This function removes a named sequence and its persisted state
+func (s *Server) handleV1DeleteSequence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+		if err := s.sequences.Delete(name); nil != err {
+			writeProblem(w, r, s.sequenceError(r, err))
+			return
+		}
+
+		s.requestLogger(r).Info("sequence deleted", "sequence", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleV1Index -
+// This function returns F(n) for the index given in the path, see
+// handleIndex
+func (s *Server) handleV1Index() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, e := s.parseIndex(r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusOK, termEnvelope(defaultSequenceName, fibonacci.Term{
+			Index: n, Value: fibonacci.Nth(n),
+		}))
+	}
+}
+
+// handleV1Range -
+// This function streams a range of the sequence, see handleRange. As JSON the
+// terms are streamed as the value of an envelope, NDJSON and CSV are the same
+// as for the unversioned route.
+func (s *Server) handleV1Range() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, ok := negotiateRangeEncoder(r.Header.Get("Accept"))
+		if !ok {
+			writeProblem(w, r, errNotAcceptable)
+			return
+		}
+		if _, isJSON := enc.(jsonRangeEncoder); isJSON {
+			enc = envelopeRangeEncoder{timestamp: s.clock().UTC()}
+		}
+
+		from, count, e := s.parseRange(r.URL.Query())
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", enc.contentType())
+		w.WriteHeader(http.StatusOK)
+		if err := streamRange(w, r, enc, from, count); nil != err {
+			s.requestLogger(r).Warn("stopped streaming range", "from", from, "error", err)
+		}
+	}
+}
+
+// envelopeRangeEncoder -
+// Writes a range as a JSON array within an envelope, encoded as compactly as
+// the envelope itself
+type envelopeRangeEncoder struct {
+	jsonRangeEncoder
+	timestamp time.Time
+}
+
+func (enc envelopeRangeEncoder) term(w io.Writer, first bool, index uint64, n fibonacci.Number) error {
+	sep := ","
+	if first {
+		sep = ""
+	}
+
+	_, err := fmt.Fprintf(w, `%s{"index":%d,"value":%s}`, sep, index, jsonNumber(n))
+	return err
+}
+
+func (enc envelopeRangeEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, `{"value":[`)
+	return err
+}
+
+func (enc envelopeRangeEncoder) end(w io.Writer) error {
+	timestamp, _ := enc.timestamp.MarshalJSON()
+	_, err := fmt.Fprintf(w, `],"sequence":%q,"timestamp":%s}`, defaultSequenceName, timestamp)
+	return err
+}
+
+// handleV1Query -
+// This function answers a number theory query about the integer given in the
+// path, see handleQuery. The index query answers with the number as the value
+// and its index.
+func (s *Server) handleV1Query() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, v, e := s.parseQuery(r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		number := bigNumber(v)
+
+		env := envelope{Sequence: defaultSequenceName}
+		switch query {
+		case "check":
+			env.Value = checkValue{Number: number, Fibonacci: fibonacci.IsFibonacci(v)}
+		case "index":
+			n, ok := fibonacci.IndexOf(v)
+			if !ok {
+				writeProblem(w, r, errNotFibonacci)
+				return
+			}
+			env = termEnvelope(defaultSequenceName, fibonacci.Term{Index: n, Value: number})
+		case "zeckendorf":
+			env.Value = zeckendorfValue{Number: number, Terms: termValues(fibonacci.Zeckendorf(v))}
+		}
+
+		s.writeEnvelope(w, http.StatusOK, env)
+	}
+}
+
+// handleV1Pisano -
+// This function returns the Pisano period of the modulus given in the path,
+// see handlePisano
+func (s *Server) handleV1Pisano() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, period, e := s.pisanoPeriod(r)
+		if nil != e {
+			writeProblem(w, r, e)
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusOK, envelope{
+			Value: pisanoValue{Modulus: m, Period: period}, Sequence: defaultSequenceName,
+		})
+	}
+}
+
+// handleV1Health -
+// This function summarizes /readyz, see handleHealth. A server that is not
+// ready is answered with a 503 problem listing the checks.
+func (s *Server) handleV1Health() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.readinessReport(r.Context())
+		if !report.ok() {
+			writeProblem(w, r, newAPIError(
+				http.StatusServiceUnavailable, "server is not ready", member{name: "checks", value: report.Checks},
+			))
+			return
+		}
+
+		s.writeEnvelope(w, http.StatusOK, envelope{Value: healthValue{Status: "healthy"}, Sequence: defaultSequenceName})
+	}
+}
+
+// handleNotFound -
+// This function answers a request for a route that does not exist
+func handleNotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, newAPIError(http.StatusNotFound, "no such route"))
+	}
+}
+
+// handleMethodNotAllowed -
+// This function answers a request for a route that exists with another
+// method, httprouter has already set the Allow header
+func handleMethodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, newAPIError(
+			http.StatusMethodNotAllowed, fmt.Sprintf("method %v is not allowed", r.Method),
+		))
+	}
+}
+
+// deprecated -
+// This function marks an unversioned route as deprecated, pointing to the
+// same path under /v1 as its successor
+func deprecated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, "/v1"+r.URL.Path))
+
+		h.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvo-dev/fibonacci-backend/pkg/fibonacci"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestServer_v1(t *testing.T) {
+	store := fibonacci.NewMemoryStore()
+	server := &Server{
+		router:             httprouter.New(),
+		maxIndex:           1000,
+		maxRange:           10,
+		maxBatch:           10,
+		resets:             newResetLog(defaultResetHistory),
+		sequences:          fibonacci.NewRegistry(store, fibonacci.Options{}, 2),
+		maxModulus:         1000,
+		pisano:             fibonacci.NewPisanoCache(4),
+		maxZeckendorfIndex: 1000,
+		now:                func() time.Time { return time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	server.routes()
+	defer server.sequences.Close()
+
+	const stamp = `"timestamp":"2021-01-02T03:04:05Z"`
+
+	type wants struct {
+		contentType string
+		payload     string
+		statusCode  int
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		mock   mockFibSequence
+		wants  wants
+	}{
+		{
+			name:   "current",
+			method: http.MethodGet,
+			path:   "/v1/current",
+			mock:   mockFibSequence{current: fibonacci.Term{Index: 12, Value: fibonacci.NewNumber(144)}},
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":144,"index":12,"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "next batch",
+			method: http.MethodGet,
+			path:   "/v1/next?count=2",
+			mock: mockFibSequence{batch: []fibonacci.Term{
+				{Index: 1, Value: fibonacci.NewNumber(1)},
+				{Index: 2, Value: fibonacci.NewNumber(1)},
+			}},
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":[{"index":1,"value":1},{"index":2,"value":1}],"index":2,"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "next overflow",
+			method: http.MethodGet,
+			path:   "/v1/next",
+			mock:   mockFibSequence{nextErr: fibonacci.ErrOverflow, policy: fibonacci.OverflowReject},
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"` + fibonacci.ErrOverflow.Error() + `","instance":"/v1/next",` +
+					`"overflow_policy":"reject","status":409,"title":"Conflict","type":"about:blank"}`,
+				statusCode: http.StatusConflict,
+			},
+		},
+		{
+			name:   "back at start",
+			method: http.MethodPost,
+			path:   "/v1/back",
+			mock:   mockFibSequence{backErr: errors.New("already at the start")},
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"already at the start","instance":"/v1/back",` +
+					`"status":409,"title":"Conflict","type":"about:blank"}`,
+				statusCode: http.StatusConflict,
+			},
+		},
+		{
+			name:   "position",
+			method: http.MethodPut,
+			path:   "/v1/sequence/position",
+			body:   `{"value": "354224848179261915075"}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":"354224848179261915075","index":100,"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "index above limit",
+			method: http.MethodGet,
+			path:   "/v1/fibonacci/1001",
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"index exceeds the maximum served","instance":"/v1/fibonacci/1001",` +
+					`"max_index":1000,"status":422,"title":"Unprocessable Entity","type":"about:blank"}`,
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:   "range",
+			method: http.MethodGet,
+			path:   "/v1/fibonacci?from=10&count=2",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":[{"index":10,"value":55},{"index":11,"value":89}],"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "check",
+			method: http.MethodGet,
+			path:   "/v1/fibonacci/check/145",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":{"number":145,"fibonacci":false},"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "zeckendorf",
+			method: http.MethodGet,
+			path:   "/v1/fibonacci/zeckendorf/4",
+			wants: wants{
+				contentType: "application/json",
+				payload: `{"value":{"number":4,"terms":[{"index":4,"value":3},{"index":2,"value":1}]},` +
+					`"sequence":"fibonacci",` + stamp + `}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "pisano",
+			method: http.MethodGet,
+			path:   "/v1/pisano/10",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":{"modulus":10,"period":60},"sequence":"fibonacci",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "create sequence",
+			method: http.MethodPost,
+			path:   "/v1/sequences/lucas",
+			body:   `{"preset": "lucas"}`,
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":2,"index":0,"sequence":"lucas",` + stamp + `}`,
+				statusCode:  http.StatusCreated,
+			},
+		},
+		{
+			name:   "named next",
+			method: http.MethodGet,
+			path:   "/v1/sequences/lucas/next",
+			wants: wants{
+				contentType: "application/json",
+				payload:     `{"value":1,"index":1,"sequence":"lucas",` + stamp + `}`,
+				statusCode:  http.StatusOK,
+			},
+		},
+		{
+			name:   "named missing",
+			method: http.MethodGet,
+			path:   "/v1/sequences/alpha/current",
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"sequence does not exist","instance":"/v1/sequences/alpha/current",` +
+					`"status":404,"title":"Not Found","type":"about:blank"}`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "unknown route",
+			method: http.MethodGet,
+			path:   "/v1/unknown",
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"no such route","instance":"/v1/unknown",` +
+					`"status":404,"title":"Not Found","type":"about:blank"}`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodDelete,
+			path:   "/v1/current",
+			wants: wants{
+				contentType: "application/problem+json",
+				payload: `{"detail":"method DELETE is not allowed","instance":"/v1/current",` +
+					`"status":405,"title":"Method Not Allowed","type":"about:blank"}`,
+				statusCode: http.StatusMethodNotAllowed,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fibSeq = tt.mock
+			req := httptest.NewRequest(tt.method, "http://0.0.0.0:8080"+tt.path, strings.NewReader(tt.body))
+			rw := httptest.NewRecorder()
+
+			server.GetRouter().ServeHTTP(rw, req)
+			resp := rw.Result()
+			payload, _ := ioutil.ReadAll(resp.Body)
+
+			if !reflect.DeepEqual(tt.wants.statusCode, resp.StatusCode) {
+				t.Errorf(
+					"Incorrect status code written, wanted: %v but got: %v",
+					tt.wants.statusCode, resp.StatusCode,
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.contentType, resp.Header.Get("Content-Type")) {
+				t.Errorf(
+					"Incorrect content type, wanted: %v but got: %v",
+					tt.wants.contentType, resp.Header.Get("Content-Type"),
+				)
+			}
+
+			if !reflect.DeepEqual(tt.wants.payload, string(payload)) {
+				t.Errorf(
+					"Incorrect payload received, wanted: %s but got: %s",
+					tt.wants.payload, string(payload),
+				)
+			}
+
+			if 0 != len(resp.Header.Get("Deprecation")) {
+				t.Errorf("Versioned route marked as deprecated")
+			}
+		})
+	}
+}
+
+func Test_deprecated(t *testing.T) {
+	h := deprecated(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://0.0.0.0:8080/fibonacci/12?x=y", nil)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if got := rw.Header().Get("Deprecation"); "true" != got {
+		t.Errorf("Incorrect Deprecation header, wanted: true but got: %v", got)
+	}
+	if got, want := rw.Header().Get("Link"), `</v1/fibonacci/12>; rel="successor-version"`; want != got {
+		t.Errorf("Incorrect Link header, wanted: %v but got: %v", want, got)
+	}
+}